@@ -0,0 +1,288 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package annotations provides typed access to the annotations cobraman
+// reads from cobra commands and their flags, so callers do not have to
+// hard-code the "man-*" keys.
+package annotations
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Annotation keys on a cobra.Command.
+const (
+	// FilesKey holds the content of the FILES section.
+	FilesKey = "man-files-section"
+
+	// BugsKey holds the content of the BUGS section.
+	BugsKey = "man-bugs-section"
+
+	// EnvironmentKey holds the content of the ENVIRONMENT section.
+	EnvironmentKey = "man-environment-section"
+
+	// DiagnosticsKey holds the content of the DIAGNOSTICS section.
+	DiagnosticsKey = "man-diagnostics-section"
+
+	// ExamplesKey holds the content of the EXAMPLES section, replacing
+	// cmd.Example.
+	ExamplesKey = "man-examples-section"
+
+	// ExampleLanguageKey holds the code fence language of the examples.
+	ExampleLanguageKey = "man-example-language"
+
+	// SeeAlsoKey holds a comma separated list of external man pages.
+	SeeAlsoKey = "man-see-also"
+
+	// NameDescriptionKey holds the description used on the NAME line.
+	NameDescriptionKey = "man-name-description"
+
+	// SynopsisKey holds the usage forms set with SetSynopsis, one per line.
+	SynopsisKey = "man-synopsis"
+
+	// StructuredExamplesKey holds the examples set with
+	// SetStructuredExamples as JSON.
+	StructuredExamplesKey = "man-examples"
+
+	// WarningKey holds a warning shown at the top of the description.
+	WarningKey = "man-warning-section"
+
+	// AliasesKey holds a comma separated list of alternate names of the
+	// command, added to cmd.Aliases for alias pages.
+	AliasesKey = "man-aliases"
+
+	// SectionPrefix followed by a name holds the content of an additional
+	// section with that name, e.g. "man-section-CAVEATS".
+	SectionPrefix = "man-section-"
+)
+
+// Annotation keys on a pflag.Flag.
+const (
+	// ArgHintsKey holds the name used for the argument of a flag.
+	ArgHintsKey = "man-arg-hints"
+
+	// FlagGroupKey holds the name of the group a flag is listed under.
+	FlagGroupKey = "man-flag-group"
+)
+
+// SetFiles sets the content of the FILES section of cmd.
+func SetFiles(cmd *cobra.Command, text string) {
+	set(cmd, FilesKey, text)
+}
+
+// Files returns the content of the FILES section set on cmd.
+func Files(cmd *cobra.Command) string {
+	return cmd.Annotations[FilesKey]
+}
+
+// SetBugs sets the content of the BUGS section of cmd.
+func SetBugs(cmd *cobra.Command, text string) {
+	set(cmd, BugsKey, text)
+}
+
+// Bugs returns the content of the BUGS section set on cmd.
+func Bugs(cmd *cobra.Command) string {
+	return cmd.Annotations[BugsKey]
+}
+
+// SetEnvironment sets the content of the ENVIRONMENT section of cmd.
+func SetEnvironment(cmd *cobra.Command, text string) {
+	set(cmd, EnvironmentKey, text)
+}
+
+// Environment returns the content of the ENVIRONMENT section set on cmd.
+func Environment(cmd *cobra.Command) string {
+	return cmd.Annotations[EnvironmentKey]
+}
+
+// SetDiagnostics sets the content of the DIAGNOSTICS section of cmd.
+func SetDiagnostics(cmd *cobra.Command, text string) {
+	set(cmd, DiagnosticsKey, text)
+}
+
+// Diagnostics returns the content of the DIAGNOSTICS section set on cmd.
+func Diagnostics(cmd *cobra.Command) string {
+	return cmd.Annotations[DiagnosticsKey]
+}
+
+// SetExamples sets the content of the EXAMPLES section of cmd, replacing
+// cmd.Example in the documentation.
+func SetExamples(cmd *cobra.Command, text string) {
+	set(cmd, ExamplesKey, text)
+}
+
+// Examples returns the content of the EXAMPLES section set on cmd.
+func Examples(cmd *cobra.Command) string {
+	return cmd.Annotations[ExamplesKey]
+}
+
+// Example is a single entry of the EXAMPLES section.  The Command and its
+// Output are rendered as literal text that will not be reflowed.
+type Example struct {
+	Description string `json:"description,omitempty"`
+	Command     string `json:"command"`
+	Output      string `json:"output,omitempty"`
+}
+
+// SetStructuredExamples sets the structured examples of cmd, listed in the
+// EXAMPLES section after cmd.Example or the text set with SetExamples.
+func SetStructuredExamples(cmd *cobra.Command, examples ...Example) {
+	data, _ := json.Marshal(examples) // can not fail for a slice of Example
+	set(cmd, StructuredExamplesKey, string(data))
+}
+
+// StructuredExamples returns the structured examples set on cmd.  It
+// returns an error if the annotation is not valid JSON.
+func StructuredExamples(cmd *cobra.Command) ([]Example, error) {
+	data, exists := cmd.Annotations[StructuredExamplesKey]
+	if !exists {
+		return nil, nil
+	}
+	var examples []Example
+	if err := json.Unmarshal([]byte(data), &examples); err != nil {
+		return nil, err
+	}
+	return examples, nil
+}
+
+// SetSynopsis replaces the generated SYNOPSIS of cmd with one line per usage
+// form.  A form lists what follows the command path, like "SRC DST" or
+// "--stdin DST", a form starting with the full command path is accepted too.
+func SetSynopsis(cmd *cobra.Command, forms ...string) {
+	set(cmd, SynopsisKey, strings.Join(forms, "\n"))
+}
+
+// Synopsis returns the usage forms set on cmd, without the empty ones.
+func Synopsis(cmd *cobra.Command) []string {
+	var forms []string
+	for _, form := range strings.Split(cmd.Annotations[SynopsisKey], "\n") {
+		if form = strings.TrimSpace(form); form != "" {
+			forms = append(forms, form)
+		}
+	}
+	return forms
+}
+
+// SetExampleLanguage sets the code fence language of the examples of cmd.
+func SetExampleLanguage(cmd *cobra.Command, language string) {
+	set(cmd, ExampleLanguageKey, language)
+}
+
+// ExampleLanguage returns the code fence language of the examples set on cmd.
+func ExampleLanguage(cmd *cobra.Command) string {
+	return cmd.Annotations[ExampleLanguageKey]
+}
+
+// SetNameDescription sets the description used on the NAME line of cmd.
+func SetNameDescription(cmd *cobra.Command, text string) {
+	set(cmd, NameDescriptionKey, text)
+}
+
+// NameDescription returns the description for the NAME line set on cmd.
+func NameDescription(cmd *cobra.Command) string {
+	return cmd.Annotations[NameDescriptionKey]
+}
+
+// SetWarning sets a warning shown at the top of the description of cmd.
+func SetWarning(cmd *cobra.Command, text string) {
+	set(cmd, WarningKey, text)
+}
+
+// Warning returns the warning set on cmd.
+func Warning(cmd *cobra.Command) string {
+	return cmd.Annotations[WarningKey]
+}
+
+// SetSection adds a section called name with the content text to cmd.
+func SetSection(cmd *cobra.Command, name string, text string) {
+	set(cmd, SectionPrefix+name, text)
+}
+
+// Section returns the content of the section called name set on cmd.
+func Section(cmd *cobra.Command, name string) string {
+	return cmd.Annotations[SectionPrefix+name]
+}
+
+// AddSeeAlso adds references to other man pages, like "crontab(5)", to the
+// SEE ALSO section of cmd.
+func AddSeeAlso(cmd *cobra.Command, refs ...string) {
+	set(cmd, SeeAlsoKey, strings.Join(append(SeeAlso(cmd), refs...), ","))
+}
+
+// SeeAlso returns the references to other man pages set on cmd.
+func SeeAlso(cmd *cobra.Command) []string {
+	var refs []string
+	for _, ref := range strings.Split(cmd.Annotations[SeeAlsoKey], ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// AddAliases adds alternate names of cmd, on top of cmd.Aliases, that get
+// an alias page pointing to the page of cmd.
+func AddAliases(cmd *cobra.Command, names ...string) {
+	set(cmd, AliasesKey, strings.Join(append(Aliases(cmd), names...), ","))
+}
+
+// Aliases returns the alternate names set on cmd.
+func Aliases(cmd *cobra.Command) []string {
+	var names []string
+	for _, name := range strings.Split(cmd.Annotations[AliasesKey], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SetArgHint sets the name used for the argument of the flag called name
+// on cmd.  It returns an error if cmd has no such flag.
+func SetArgHint(cmd *cobra.Command, name string, hint string) error {
+	return cmd.Flags().SetAnnotation(name, ArgHintsKey, []string{hint})
+}
+
+// ArgHint returns the argument name set on the flag called name on cmd.
+func ArgHint(cmd *cobra.Command, name string) string {
+	return flagAnnotation(cmd, name, ArgHintsKey)
+}
+
+// SetFlagGroup lists the flag called name on cmd under group in the
+// OPTIONS section.  It returns an error if cmd has no such flag.
+func SetFlagGroup(cmd *cobra.Command, name string, group string) error {
+	return cmd.Flags().SetAnnotation(name, FlagGroupKey, []string{group})
+}
+
+// FlagGroup returns the group set on the flag called name on cmd.
+func FlagGroup(cmd *cobra.Command, name string) string {
+	return flagAnnotation(cmd, name, FlagGroupKey)
+}
+
+func set(cmd *cobra.Command, key string, value string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[key] = value
+}
+
+func flagAnnotation(cmd *cobra.Command, name string, key string) string {
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil || len(flag.Annotations[key]) == 0 {
+		return ""
+	}
+	return flag.Annotations[key][0]
+}
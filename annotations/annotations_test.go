@@ -0,0 +1,107 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSections(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	assert.Equal(t, "", Files(cmd))
+
+	SetFiles(cmd, "files")
+	SetBugs(cmd, "bugs")
+	SetEnvironment(cmd, "environment")
+	SetDiagnostics(cmd, "diagnostics")
+	SetExamples(cmd, "examples")
+	SetNameDescription(cmd, "name")
+	SetWarning(cmd, "warning")
+	SetExampleLanguage(cmd, "console")
+	SetSection(cmd, "CAVEATS", "caveats")
+
+	assert.Equal(t, "files", Files(cmd))
+	assert.Equal(t, "bugs", Bugs(cmd))
+	assert.Equal(t, "environment", Environment(cmd))
+	assert.Equal(t, "diagnostics", Diagnostics(cmd))
+	assert.Equal(t, "examples", Examples(cmd))
+	assert.Equal(t, "name", NameDescription(cmd))
+	assert.Equal(t, "warning", Warning(cmd))
+	assert.Equal(t, "console", ExampleLanguage(cmd))
+	assert.Equal(t, "caveats", Section(cmd, "CAVEATS"))
+	assert.Equal(t, "caveats", cmd.Annotations["man-section-CAVEATS"])
+	assert.Equal(t, "files", cmd.Annotations["man-files-section"])
+}
+
+func TestStructuredExamples(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	examples, err := StructuredExamples(cmd)
+	assert.NoError(t, err)
+	assert.Empty(t, examples)
+
+	SetStructuredExamples(cmd, Example{Description: "List things", Command: "foo list"}, Example{Command: "foo show", Output: "a thing"})
+	examples, err = StructuredExamples(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, []Example{{Description: "List things", Command: "foo list"}, {Command: "foo show", Output: "a thing"}}, examples)
+
+	cmd.Annotations[StructuredExamplesKey] = "{"
+	_, err = StructuredExamples(cmd)
+	assert.Error(t, err)
+}
+
+func TestSynopsis(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	assert.Empty(t, Synopsis(cmd))
+
+	SetSynopsis(cmd, "SRC DST", "", " --stdin DST ")
+	assert.Equal(t, []string{"SRC DST", "--stdin DST"}, Synopsis(cmd))
+	assert.Equal(t, "SRC DST\n\n --stdin DST ", cmd.Annotations["man-synopsis"])
+}
+
+func TestSeeAlso(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	assert.Empty(t, SeeAlso(cmd))
+
+	AddSeeAlso(cmd, "crontab(5)")
+	AddSeeAlso(cmd, "cron(8)", "at(1)")
+	assert.Equal(t, []string{"crontab(5)", "cron(8)", "at(1)"}, SeeAlso(cmd))
+	assert.Equal(t, "crontab(5),cron(8),at(1)", cmd.Annotations["man-see-also"])
+}
+
+func TestAliases(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	assert.Empty(t, Aliases(cmd))
+
+	AddAliases(cmd, "bar")
+	AddAliases(cmd, "baz", " qux ")
+	assert.Equal(t, []string{"bar", "baz", "qux"}, Aliases(cmd))
+	assert.Equal(t, "bar,baz, qux ", cmd.Annotations["man-aliases"])
+}
+
+func TestFlagAnnotations(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.Flags().String("file", "", "a file")
+
+	assert.NoError(t, SetArgHint(cmd, "file", "path"))
+	assert.NoError(t, SetFlagGroup(cmd, "file", "Input options"))
+	assert.Equal(t, "path", ArgHint(cmd, "file"))
+	assert.Equal(t, "Input options", FlagGroup(cmd, "file"))
+	assert.Equal(t, []string{"path"}, cmd.Flags().Lookup("file").Annotations["man-arg-hints"])
+
+	assert.Error(t, SetArgHint(cmd, "missing", "path"))
+	assert.Equal(t, "", ArgHint(cmd, "missing"))
+}
@@ -0,0 +1,101 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// GenerateArchive writes the pages GenerateDocs would write for cmd and all
+// of its children to w as a gzip compressed tar archive.  Pages of man page
+// templates are put in the man<section>/ directory, like man1/app.1, as
+// with Options.SectionDirs, so the archive can be unpacked in a man
+// directory.  The files are sorted by name and dated Options.Date so the
+// archive is reproducible.
+func GenerateArchive(cmd *cobra.Command, opts *Options, templateName string, w io.Writer) error {
+	validate(opts, templateName)
+	archiveOpts := *opts
+	archiveOpts.SectionDirs = true
+	files, err := RenderDocs(cmd, &archiveOpts, templateName)
+	if err != nil {
+		return err
+	}
+
+	return writeTarGz(w, files, *opts.Date)
+}
+
+// sortedNames returns the names of files, sorted.
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeTarGz writes files to w as a gzip compressed tar archive, sorted by
+// name and dated date so it is reproducible.
+func writeTarGz(w io.Writer, files map[string][]byte, date time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedNames(files) {
+		hdr := &tar.Header{
+			Name:    filepath.ToSlash(name),
+			Mode:    0o644,
+			Size:    int64(len(files[name])),
+			ModTime: date,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeZip writes files to w as a zip archive, sorted by name and dated
+// date so it is reproducible.
+func writeZip(w io.Writer, files map[string][]byte, date time.Time) error {
+	zw := zip.NewWriter(w)
+	for _, name := range sortedNames(files) {
+		hdr := &zip.FileHeader{
+			Name:     filepath.ToSlash(name),
+			Method:   zip.Deflate,
+			Modified: date,
+		}
+		hdr.SetMode(0o644)
+		f, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,68 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// readArchive returns the files of the tar.gz archive data keyed by name.
+func readArchive(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		assert.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestGenerateArchive(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+	date := time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, GenerateArchive(cmd, &Options{Date: &date, Section: "8"}, "troff", buf))
+	files := readArchive(t, buf.Bytes())
+	assert.Len(t, files, 2)
+	assert.Contains(t, files["man8/foo.8"], `.TH "FOO" "8"`)
+	assert.Contains(t, files["man8/foo-bar.8"], "foo bar")
+
+	again := new(bytes.Buffer)
+	assert.NoError(t, GenerateArchive(cmd, &Options{Date: &date, Section: "8"}, "troff", again))
+	assert.Equal(t, buf.Bytes(), again.Bytes())
+
+	buf.Reset()
+	assert.NoError(t, GenerateArchive(cmd, &Options{Date: &date}, "markdown", buf))
+	files = readArchive(t, buf.Bytes())
+	assert.Contains(t, files, "foo_bar.md")
+
+	assert.Equal(t, ErrMissingCommandName, GenerateArchive(&cobra.Command{}, &Options{}, "troff", buf))
+}
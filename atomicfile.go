@@ -0,0 +1,86 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// atomicFile is a temporary file renamed to path when it is closed.
+type atomicFile struct {
+	afero.File
+	fs   afero.Fs
+	path string
+}
+
+// createTemp creates a temporary file with mode, reduced by the umask, next
+// to path so it can be renamed to it.
+func createTemp(fs afero.Fs, path string, mode os.FileMode) (*atomicFile, error) {
+	dir, base := filepath.Split(path)
+	random := make([]byte, 4)
+	for i := 0; ; i++ {
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, "."+base+"."+hex.EncodeToString(random)+".tmp")
+		f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if os.IsExist(err) && i < 100 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &atomicFile{File: f, fs: fs, path: path}, nil
+	}
+}
+
+// Close flushes the file and renames it to its path.
+func (f *atomicFile) Close() error {
+	if err := f.File.Sync(); err != nil {
+		return joinErrors(err, f.Abort())
+	}
+	if err := f.File.Close(); err != nil {
+		return joinErrors(err, f.fs.Remove(f.Name()))
+	}
+	if err := f.fs.Rename(f.Name(), f.path); err != nil {
+		return joinErrors(err, f.fs.Remove(f.Name()))
+	}
+	return nil
+}
+
+// Abort closes and removes the file, leaving the file at its path as it
+// was.
+func (f *atomicFile) Abort() error {
+	return joinErrors(f.File.Close(), f.fs.Remove(f.Name()))
+}
+
+// closeFile closes f and stores the error in err if it holds none.  Files
+// that can be aborted, like an atomicFile, are aborted instead when err
+// holds an error so no partial file is kept, and the errors of aborting
+// are added to err.
+func closeFile(f io.WriteCloser, err *error) {
+	if a, ok := f.(interface{ Abort() error }); ok && *err != nil {
+		*err = joinErrors(*err, a.Abort())
+		return
+	}
+	if closeErr := f.Close(); *err == nil {
+		*err = closeErr
+	}
+}
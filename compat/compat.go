@@ -0,0 +1,392 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compat provides the functions of github.com/spf13/cobra/doc, with
+// the same signatures, implemented with cobraman so a project can switch to
+// cobraman by changing one import.
+//
+// The man pages and markdown are generated with the cobraman "troff" and
+// "markdown" templates, so they look like the other cobraman pages rather
+// than those of cobra/doc.  The YAML and reStructuredText documents are the
+// ones of cobra/doc, written by the "yaml" and "rest" formats RegisterFormats
+// registers with cobraman.
+package compat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecsammon/cobraman"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// restLinkHandlerKey is the key of Options.CustomData holding the link
+// handler of the "rest" format.
+const restLinkHandlerKey = "compat.restLinkHandler"
+
+var registerOnce sync.Once
+
+// RegisterFormats registers the "yaml" and "rest" formats with cobraman,
+// so they can be used with cobraman.GenerateDocs and the doc tool like the
+// templates.  The Gen functions of the formats register them when first
+// called.
+func RegisterFormats() {
+	registerOnce.Do(func() {
+		cobraman.RegisterGenerator("yaml", "_", "yaml", genYaml)
+		cobraman.RegisterGenerator("rest", "_", "rst", genReST)
+	})
+}
+
+// GenManHeader is the .TH header of the man pages.  Title is ignored, the
+// title is always the command path.  Source and Manual are the left footer
+// and center header of the page.
+type GenManHeader struct {
+	Title   string
+	Section string
+	Date    *time.Time
+	Source  string
+	Manual  string
+}
+
+// GenManTreeOptions is the options of GenManTreeFromOpts.
+type GenManTreeOptions struct {
+	Header           *GenManHeader
+	Path             string
+	CommandSeparator string
+}
+
+// GenManTree generates a man page for cmd and all its descendants in dir.
+func GenManTree(cmd *cobra.Command, header *GenManHeader, dir string) error {
+	return GenManTreeFromOpts(cmd, GenManTreeOptions{
+		Header:           header,
+		Path:             dir,
+		CommandSeparator: "-",
+	})
+}
+
+// GenManTreeFromOpts generates a man page for cmd and all its descendants
+// in opts.Path, named after the command path with its words joined by
+// opts.CommandSeparator, "_" if empty.
+func GenManTreeFromOpts(cmd *cobra.Command, opts GenManTreeOptions) error {
+	manOpts := headerOptions(opts.Header)
+	sep := opts.CommandSeparator
+	if sep == "" {
+		sep = "_"
+	}
+	// The troff template joins the words with "-".
+	if sep != "-" {
+		manOpts.PathFor = func(c *cobra.Command, _ string) string {
+			return strings.ReplaceAll(c.CommandPath(), " ", sep) + "." + manOpts.Section
+		}
+	}
+	return cobraman.GenerateDocs(cmd, manOpts, opts.Path, "troff")
+}
+
+// GenMan writes the man page of cmd to w.  header may be nil.
+func GenMan(cmd *cobra.Command, header *GenManHeader, w io.Writer) error {
+	return cobraman.GenerateOnePage(cmd, headerOptions(header), "troff", w)
+}
+
+// headerOptions returns the cobraman options matching header.
+func headerOptions(header *GenManHeader) *cobraman.Options {
+	if header == nil {
+		return &cobraman.Options{}
+	}
+	return &cobraman.Options{
+		Section:      header.Section,
+		Date:         header.Date,
+		LeftFooter:   header.Source,
+		CenterHeader: header.Manual,
+	}
+}
+
+// GenMarkdown writes the markdown page of cmd to w.
+func GenMarkdown(cmd *cobra.Command, w io.Writer) error {
+	return GenMarkdownCustom(cmd, w, nil)
+}
+
+// GenMarkdownCustom writes the markdown page of cmd to w, with the links to
+// other pages made by linkHandler from their file name.
+func GenMarkdownCustom(cmd *cobra.Command, w io.Writer, linkHandler func(string) string) error {
+	return cobraman.GenerateOnePage(cmd, &cobraman.Options{LinkHandler: linkHandler}, "markdown", w)
+}
+
+// GenMarkdownTree generates a markdown page for cmd and all its descendants
+// in dir.
+func GenMarkdownTree(cmd *cobra.Command, dir string) error {
+	return GenMarkdownTreeCustom(cmd, dir, nil, nil)
+}
+
+// GenMarkdownTreeCustom generates a markdown page for cmd and all its
+// descendants in dir, each starting with what filePrepender returns for its
+// file name and with the links made by linkHandler.
+func GenMarkdownTreeCustom(cmd *cobra.Command, dir string, filePrepender, linkHandler func(string) string) error {
+	return cobraman.GenerateDocs(cmd, &cobraman.Options{
+		FilePrepender: filePrepender,
+		LinkHandler:   linkHandler,
+	}, dir, "markdown")
+}
+
+// GenYaml writes the YAML document of cmd to w.
+func GenYaml(cmd *cobra.Command, w io.Writer) error {
+	return GenYamlCustom(cmd, w, nil)
+}
+
+// GenYamlCustom writes the YAML document of cmd to w.  Like in cobra/doc
+// the document has no links, so linkHandler is not used.
+func GenYamlCustom(cmd *cobra.Command, w io.Writer, linkHandler func(string) string) error {
+	RegisterFormats()
+	return cobraman.GenerateOnePage(cmd, &cobraman.Options{}, "yaml", w)
+}
+
+// GenYamlTree generates a YAML document for cmd and all its descendants in
+// dir.
+func GenYamlTree(cmd *cobra.Command, dir string) error {
+	return GenYamlTreeCustom(cmd, dir, nil, nil)
+}
+
+// GenYamlTreeCustom generates a YAML document for cmd and all its
+// descendants in dir, each starting with what filePrepender returns for its
+// file name.
+func GenYamlTreeCustom(cmd *cobra.Command, dir string, filePrepender, linkHandler func(string) string) error {
+	RegisterFormats()
+	return cobraman.GenerateDocs(cmd, &cobraman.Options{FilePrepender: filePrepender}, dir, "yaml")
+}
+
+// GenReST writes the reStructuredText page of cmd to w.
+func GenReST(cmd *cobra.Command, w io.Writer) error {
+	return GenReSTCustom(cmd, w, nil)
+}
+
+// GenReSTCustom writes the reStructuredText page of cmd to w, with the
+// links to other pages made by linkHandler from the command path and the
+// reference of the page.
+func GenReSTCustom(cmd *cobra.Command, w io.Writer, linkHandler func(string, string) string) error {
+	RegisterFormats()
+	return cobraman.GenerateOnePage(cmd, restOptions(linkHandler), "rest", w)
+}
+
+// GenReSTTree generates a reStructuredText page for cmd and all its
+// descendants in dir.
+func GenReSTTree(cmd *cobra.Command, dir string) error {
+	return GenReSTTreeCustom(cmd, dir, nil, nil)
+}
+
+// GenReSTTreeCustom generates a reStructuredText page for cmd and all its
+// descendants in dir, each starting with what filePrepender returns for its
+// file name and with the links made by linkHandler.
+func GenReSTTreeCustom(cmd *cobra.Command, dir string, filePrepender func(string) string, linkHandler func(string, string) string) error {
+	RegisterFormats()
+	opts := restOptions(linkHandler)
+	opts.FilePrepender = filePrepender
+	return cobraman.GenerateDocs(cmd, opts, dir, "rest")
+}
+
+// restOptions returns the cobraman options of the "rest" format using
+// linkHandler, the link to the .rst file if nil.
+func restOptions(linkHandler func(string, string) string) *cobraman.Options {
+	opts := &cobraman.Options{}
+	if linkHandler != nil {
+		opts.CustomData = map[string]interface{}{restLinkHandlerKey: linkHandler}
+	}
+	return opts
+}
+
+// defaultReSTLinkHandler links to the .rst file of the page.
+func defaultReSTLinkHandler(name, ref string) string {
+	return fmt.Sprintf("`%s <%s.rst>`_", name, ref)
+}
+
+type cmdOption struct {
+	Name         string
+	Shorthand    string `yaml:",omitempty"`
+	DefaultValue string `yaml:"default_value,omitempty"`
+	Usage        string `yaml:",omitempty"`
+}
+
+type cmdDoc struct {
+	Name             string
+	Synopsis         string      `yaml:",omitempty"`
+	Description      string      `yaml:",omitempty"`
+	Usage            string      `yaml:",omitempty"`
+	Options          []cmdOption `yaml:",omitempty"`
+	InheritedOptions []cmdOption `yaml:"inherited_options,omitempty"`
+	Example          string      `yaml:",omitempty"`
+	SeeAlso          []string    `yaml:"see_also,omitempty"`
+}
+
+// genYaml is the generator of the "yaml" format, writing the document
+// cobra/doc does.
+func genYaml(cmd *cobra.Command, opts *cobraman.Options, w io.Writer) error {
+	doc := cmdDoc{
+		Name:        cmd.CommandPath(),
+		Synopsis:    forceMultiLine(cmd.Short),
+		Description: forceMultiLine(cmd.Long),
+		Example:     cmd.Example,
+	}
+	if cmd.Runnable() {
+		doc.Usage = cmd.UseLine()
+	}
+	if flags := cmd.NonInheritedFlags(); flags.HasFlags() {
+		doc.Options = flagOptions(flags)
+	}
+	if flags := cmd.InheritedFlags(); flags.HasFlags() {
+		doc.InheritedOptions = flagOptions(flags)
+	}
+	if parent, children := seeAlso(cmd); parent != nil || len(children) > 0 {
+		if parent != nil {
+			doc.SeeAlso = append(doc.SeeAlso, parent.CommandPath()+" - "+parent.Short)
+		}
+		for _, child := range children {
+			doc.SeeAlso = append(doc.SeeAlso, child.CommandPath()+" - "+child.Short)
+		}
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// flagOptions returns the YAML entries of flags.
+func flagOptions(flags *pflag.FlagSet) []cmdOption {
+	var options []cmdOption
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden || flag.Deprecated != "" {
+			return
+		}
+		options = append(options, cmdOption{
+			Name:         flag.Name,
+			Shorthand:    flag.Shorthand,
+			DefaultValue: forceMultiLine(flag.DefValue),
+			Usage:        forceMultiLine(flag.Usage),
+		})
+	})
+	return options
+}
+
+// forceMultiLine makes yaml.v3 write long strings as literal blocks.
+func forceMultiLine(s string) string {
+	if len(s) > 60 && !strings.Contains(s, "\n") {
+		s += "\n"
+	}
+	return s
+}
+
+// genReST is the generator of the "rest" format, writing the page cobra/doc
+// does.
+func genReST(cmd *cobra.Command, opts *cobraman.Options, w io.Writer) error {
+	linkHandler := defaultReSTLinkHandler
+	if handler, ok := opts.CustomData[restLinkHandlerKey].(func(string, string) string); ok {
+		linkHandler = handler
+	}
+
+	var buf bytes.Buffer
+	name := cmd.CommandPath()
+	long := cmd.Long
+	if long == "" {
+		long = cmd.Short
+	}
+
+	buf.WriteString(".. _" + strings.ReplaceAll(name, " ", "_") + ":\n\n")
+	buf.WriteString(name + "\n" + strings.Repeat("-", len(name)) + "\n\n")
+	buf.WriteString(cmd.Short + "\n\n")
+	buf.WriteString("Synopsis\n~~~~~~~~\n\n\n" + long + "\n\n")
+	if cmd.Runnable() {
+		fmt.Fprintf(&buf, "::\n\n  %s\n\n", cmd.UseLine())
+	}
+	if cmd.Example != "" {
+		fmt.Fprintf(&buf, "Examples\n~~~~~~~~\n\n::\n\n%s\n\n", indent(cmd.Example, "  "))
+	}
+	if flags := cmd.NonInheritedFlags(); flags.HasAvailableFlags() {
+		buf.WriteString("Options\n~~~~~~~\n\n::\n\n" + flags.FlagUsages() + "\n")
+	}
+	if flags := cmd.InheritedFlags(); flags.HasAvailableFlags() {
+		buf.WriteString("Options inherited from parent commands\n~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~\n\n::\n\n" + flags.FlagUsages() + "\n")
+	}
+	writeReSTSeeAlso(&buf, cmd, linkHandler)
+	if !autoGenDisabled(cmd) && !opts.OmitDate {
+		buf.WriteString("*Auto generated by github.com/alecsammon/cobraman on " + opts.Date.Format("2-Jan-2006") + "*\n")
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeReSTSeeAlso writes the SEE ALSO section of the reST page of cmd to
+// buf, if it has a parent or children.
+func writeReSTSeeAlso(buf *bytes.Buffer, cmd *cobra.Command, linkHandler func(string, string) string) {
+	parent, children := seeAlso(cmd)
+	if parent == nil && len(children) == 0 {
+		return
+	}
+	buf.WriteString("SEE ALSO\n~~~~~~~~\n\n")
+	if parent != nil {
+		pname := parent.CommandPath()
+		fmt.Fprintf(buf, "* %s \t - %s\n", linkHandler(pname, strings.ReplaceAll(pname, " ", "_")), parent.Short)
+	}
+	for _, child := range children {
+		cname := child.CommandPath()
+		fmt.Fprintf(buf, "* %s \t - %s\n", linkHandler(cname, strings.ReplaceAll(cname, " ", "_")), child.Short)
+	}
+	buf.WriteString("\n")
+}
+
+// indent indents every non-empty line of s with prefix.
+func indent(s string, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// seeAlso returns the parent of cmd, if any, and its documented children
+// sorted by name.
+func seeAlso(cmd *cobra.Command) (*cobra.Command, []*cobra.Command) {
+	var children []*cobra.Command
+	for _, child := range cmd.Commands() {
+		if child.IsAvailableCommand() && !child.IsAdditionalHelpTopicCommand() {
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name() < children[j].Name()
+	})
+	if !cmd.HasParent() {
+		return nil, children
+	}
+	return cmd.Parent(), children
+}
+
+// autoGenDisabled reports whether cmd or one of its parents has
+// DisableAutoGenTag set.
+func autoGenDisabled(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.DisableAutoGenTag {
+			return true
+		}
+	}
+	return false
+}
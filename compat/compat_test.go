@@ -0,0 +1,112 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCommands() *cobra.Command {
+	cmd := &cobra.Command{Use: "foo", Short: "foo things", DisableAutoGenTag: true}
+	cmd.PersistentFlags().Bool("verbose", false, "say more")
+	bar := &cobra.Command{Use: "bar [name]", Short: "bar things", Example: "foo bar baz", Run: func(cmd *cobra.Command, args []string) {}}
+	bar.Flags().StringP("color", "c", "red", "the color")
+	cmd.AddCommand(bar)
+	return cmd
+}
+
+func TestGenMan(t *testing.T) {
+	date := time.Date(2018, 2, 3, 0, 0, 0, 0, time.UTC)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, GenMan(testCommands(), &GenManHeader{Section: "8", Date: &date, Source: "Foo 1.0", Manual: "Foo Manual"}, buf))
+	assert.Regexp(t, `\.TH "FOO" "8" "Feb 2018" "Foo 1\.0" "Foo Manual"`, buf.String())
+}
+
+func TestGenManTreeFromOpts(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, GenManTreeFromOpts(testCommands(), GenManTreeOptions{Path: dir}))
+	assert.FileExists(t, filepath.Join(dir, "foo.1"))
+	assert.FileExists(t, filepath.Join(dir, "foo_bar.1"))
+
+	dir = t.TempDir()
+	assert.NoError(t, GenManTree(testCommands(), nil, dir))
+	assert.FileExists(t, filepath.Join(dir, "foo-bar.1"))
+}
+
+func TestGenMarkdownTreeCustom(t *testing.T) {
+	dir := t.TempDir()
+	prepender := func(filename string) string { return "---\ntitle: " + filepath.Base(filename) + "\n---\n" }
+	linkHandler := func(filename string) string { return "/docs/" + filename }
+	assert.NoError(t, GenMarkdownTreeCustom(testCommands(), dir, prepender, linkHandler))
+
+	data, err := os.ReadFile(filepath.Join(dir, "foo_bar.md"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "---\ntitle: foo_bar.md\n---\n")
+	assert.Contains(t, string(data), "(/docs/foo.md)")
+}
+
+func TestGenYaml(t *testing.T) {
+	cmd := testCommands()
+	bar, _, _ := cmd.Find([]string{"bar"})
+	buf := new(bytes.Buffer)
+	assert.NoError(t, GenYaml(bar, buf))
+	assert.Equal(t, `name: foo bar
+synopsis: bar things
+usage: foo bar [name] [flags]
+options:
+    - name: color
+      shorthand: c
+      default_value: red
+      usage: the color
+inherited_options:
+    - name: verbose
+      default_value: "false"
+      usage: say more
+example: foo bar baz
+see_also:
+    - foo - foo things
+`, buf.String())
+
+	dir := t.TempDir()
+	assert.NoError(t, GenYamlTree(cmd, dir))
+	assert.FileExists(t, filepath.Join(dir, "foo.yaml"))
+	assert.FileExists(t, filepath.Join(dir, "foo_bar.yaml"))
+}
+
+func TestGenReST(t *testing.T) {
+	cmd := testCommands()
+	bar, _, _ := cmd.Find([]string{"bar"})
+	buf := new(bytes.Buffer)
+	assert.NoError(t, GenReSTCustom(bar, buf, func(name, ref string) string { return ":ref:`" + ref + "`" }))
+	out := buf.String()
+	assert.Contains(t, out, ".. _foo_bar:\n\nfoo bar\n-------\n\nbar things\n\n")
+	assert.Contains(t, out, "::\n\n  foo bar [name] [flags]\n\n")
+	assert.Contains(t, out, "Examples\n~~~~~~~~\n\n::\n\n  foo bar baz\n\n")
+	assert.Contains(t, out, "  -c, --color string   the color")
+	assert.Contains(t, out, "* :ref:`foo` \t - foo things\n")
+	assert.NotContains(t, out, "Auto generated")
+
+	dir := t.TempDir()
+	assert.NoError(t, GenReSTTree(cmd, dir))
+	data, err := os.ReadFile(filepath.Join(dir, "foo.rst"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "* `foo bar <foo_bar.rst>`_ \t - bar things\n")
+}
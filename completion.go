@@ -0,0 +1,86 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// shellCompletion is the completion script cobra generates for a shell.
+// %s in its paths is replaced by the name of the application.
+type shellCompletion struct {
+	shell string
+
+	// file is the name the completion subcommand gives the script.
+	file string
+
+	// installPath is where the install subcommand puts the script,
+	// relative to the prefix.  Empty for shells without a standard place.
+	installPath string
+
+	generate func(cmd *cobra.Command, w io.Writer) error
+}
+
+// shellCompletions are the completion scripts of the shells cobra supports.
+var shellCompletions = []shellCompletion{
+	{"bash", "%s.bash", "share/bash-completion/completions/%s", func(cmd *cobra.Command, w io.Writer) error {
+		return cmd.GenBashCompletionV2(w, true)
+	}},
+	{"zsh", "_%s", "share/zsh/site-functions/_%s", func(cmd *cobra.Command, w io.Writer) error {
+		return cmd.GenZshCompletion(w)
+	}},
+	{"fish", "%s.fish", "share/fish/vendor_completions.d/%s.fish", func(cmd *cobra.Command, w io.Writer) error {
+		return cmd.GenFishCompletion(w, true)
+	}},
+	{"powershell", "%s.ps1", "", func(cmd *cobra.Command, w io.Writer) error {
+		return cmd.GenPowerShellCompletionWithDesc(w)
+	}},
+}
+
+// shellNames returns the shells of shellCompletions, and "all".
+func shellNames() []string {
+	names := make([]string, 0, len(shellCompletions)+1)
+	for _, completion := range shellCompletions {
+		names = append(names, completion.shell)
+	}
+	return append(names, "all")
+}
+
+// completion writes the completion script of the application for shell, or
+// for every shell if it is "all", to the directory given with --directory.
+func (dg *DocGenTool) completion(myCmd *cobra.Command, shell string) error {
+	opts := &Options{
+		FileMode:     0o644,
+		DryRun:       dg.dryRun,
+		DryRunOutput: myCmd.OutOrStdout(),
+	}
+	for _, completion := range shellCompletions {
+		if shell != "all" && shell != completion.shell {
+			continue
+		}
+		generate := completion.generate
+		path := filepath.Join(dg.installDirectory, fmt.Sprintf(completion.file, dg.appCmd.Name()))
+		err := createFile(path, dg.appCmd.CommandPath(), opts, func(w io.Writer) error {
+			return generate(dg.appCmd, w)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/cobra"
+)
+
+// CommandCoverage is how completely a command is documented, as reported
+// by Coverage.
+type CommandCoverage struct {
+	// Command is the path of the command, like "app sub".
+	Command string
+
+	// Long reports whether the command has a Long description.
+	Long bool
+
+	// Examples reports whether the command has examples, in Example or
+	// set with annotations.SetExamples or annotations.SetStructuredExamples.
+	Examples bool
+
+	// Arguments reports whether the arguments of the command are
+	// documented, in Use or with annotations.SetSynopsis.  Commands taking no
+	// arguments with cobra.NoArgs always have it.
+	Arguments bool
+
+	// Environment reports whether the page of the command has an
+	// ENVIRONMENT section, from Options.Environment, Options.EnvVars or
+	// annotations.SetEnvironment.
+	Environment bool
+}
+
+// coverageChecks is the number of checks in a CommandCoverage.
+const coverageChecks = 4
+
+// Covered returns how many of the checks of c pass.
+func (c CommandCoverage) Covered() int {
+	covered := 0
+	for _, ok := range []bool{c.Long, c.Examples, c.Arguments, c.Environment} {
+		if ok {
+			covered++
+		}
+	}
+	return covered
+}
+
+// Coverage checks how completely the commands GenerateDocs documents for
+// cmd are, sorted by command path, and returns the percentage of the checks
+// that pass over all of them.
+func Coverage(cmd *cobra.Command, opts *Options) ([]CommandCoverage, float64, error) {
+	start := cmd
+	if opts.Only != "" {
+		var err error
+		if start, err = findCommand(cmd, opts.Only); err != nil {
+			return nil, 0, err
+		}
+	}
+	pages := pageCommands(start, opts, 1)
+
+	report := make([]CommandCoverage, 0, len(pages))
+	covered := 0
+	for _, c := range pages {
+		cov := commandCoverage(c, opts)
+		covered += cov.Covered()
+		report = append(report, cov)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Command < report[j].Command })
+	if len(report) == 0 {
+		return report, 100, nil
+	}
+	return report, 100 * float64(covered) / float64(coverageChecks*len(report)), nil
+}
+
+// commandCoverage checks how completely cmd is documented.
+func commandCoverage(cmd *cobra.Command, opts *Options) CommandCoverage {
+	_, structured := cmd.Annotations[annotations.StructuredExamplesKey]
+	_, synopsis := cmd.Annotations[annotations.SynopsisKey]
+	return CommandCoverage{
+		Command:     cmd.CommandPath(),
+		Long:        strings.TrimSpace(cmd.Long) != "",
+		Examples:    cmd.Example != "" || annotations.Examples(cmd) != "" || structured,
+		Arguments:   opts.commands.get(cmd).noArgs || len(strings.Fields(cmd.Use)) > 1 || synopsis,
+		Environment: opts.Environment != "" || annotations.Environment(cmd) != "" || len(genEnvVars(cmd, opts.EnvVars)) > 0,
+	}
+}
+
+// coverage writes the Coverage of the application to the output of myCmd
+// as a table, with the Options of the first generator added with
+// AddDocGenerator.  It fails when the percentage is below --min-coverage.
+func (dg *DocGenTool) coverage(myCmd *cobra.Command) error {
+	opts := &Options{}
+	if len(dg.registered) > 0 {
+		opts = dg.generators[dg.registered[0]]
+	}
+	covOpts := *dg.flagOptions(dg.fileOptions(opts), myCmd)
+	covOpts.commands = dg.commands
+	report, percent, err := Coverage(dg.appCmd, &covOpts)
+	if err != nil {
+		return err
+	}
+
+	mark := map[bool]string{true: "yes", false: "no"}
+	w := tabwriter.NewWriter(myCmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "COMMAND\tLONG\tEXAMPLES\tARGS\tENV\tSCORE"); err != nil {
+		return err
+	}
+	for _, c := range report {
+		row := []string{c.Command, mark[c.Long], mark[c.Examples], mark[c.Arguments], mark[c.Environment]}
+		if _, err := fmt.Fprintf(w, "%s\t%d/%d\n", strings.Join(row, "\t"), c.Covered(), coverageChecks); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(myCmd.OutOrStdout(), "coverage: %.1f%% of %d commands\n", percent, len(report)); err != nil {
+		return err
+	}
+
+	if percent < dg.minCoverage {
+		return fmt.Errorf("coverage %.1f%% is below the minimum of %.1f%%", percent, dg.minCoverage)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"testing"
+
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverage(t *testing.T) {
+	cmd := &cobra.Command{Use: "cov", Long: "Covered.", Example: "cov run", Args: cobra.NoArgs}
+	annotations.SetEnvironment(cmd, "COV_HOME is the home.")
+	full := &cobra.Command{Use: "full <file>", Long: "Full docs.", Run: func(cmd *cobra.Command, args []string) {}}
+	full.Flags().String("config", "", "config file")
+	annotations.SetStructuredExamples(full, Example{Command: "cov full file"})
+	bare := &cobra.Command{Use: "bare", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(full, bare)
+
+	report, percent, err := Coverage(cmd, &Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []CommandCoverage{
+		{Command: "cov", Long: true, Examples: true, Arguments: true, Environment: true},
+		{Command: "cov bare"},
+		{Command: "cov full", Long: true, Examples: true, Arguments: true},
+	}, report)
+	assert.InDelta(t, 100*7/12.0, percent, 0.001)
+
+	report, percent, err = Coverage(cmd, &Options{EnvVars: []EnvVar{{Name: "COV_CONFIG", Flag: "config"}}, Only: "full"})
+	assert.NoError(t, err)
+	assert.Equal(t, []CommandCoverage{{Command: "cov full", Long: true, Examples: true, Arguments: true, Environment: true}}, report)
+	assert.Equal(t, 100.0, percent)
+	assert.Equal(t, 4, report[0].Covered())
+
+	_, _, err = Coverage(cmd, &Options{Only: "missing"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// DiffDocs renders the files GenerateDocs would write for cmd into
+// directory, like RenderDocs, compares them with the files in directory in
+// Options.Fs, or the operating system file system if it is nil, and writes
+// a unified diff of the differences to w.  Generated files in directory
+// that would not be written anymore show up as removed, unless Options.Only
+// or Options.MaxDepth is set.  It reports whether there are differences,
+// for checks that the committed docs are up to date.  Compressed pages are
+// compared uncompressed.
+func DiffDocs(cmd *cobra.Command, opts *Options, directory string, templateName string, w io.Writer) (bool, error) {
+	files, err := RenderDocs(cmd, opts, templateName)
+	if err != nil {
+		return false, err
+	}
+	fs := opts.Fs
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	if directory == "" {
+		directory = "."
+	}
+
+	differ, err := diffFiles(fs, directory, files, w)
+	if err != nil || opts.Only != "" || opts.MaxDepth != 0 {
+		return differ, err
+	}
+	removed, err := diffRemoved(fs, directory, files, w)
+	return differ || removed, err
+}
+
+// diffFiles writes the diff of the files, keyed by their name in directory,
+// with the files in directory to w.  It reports whether there are
+// differences.
+func diffFiles(fs afero.Fs, directory string, files map[string][]byte, w io.Writer) (bool, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	differ := false
+	for _, name := range names {
+		path := filepath.Join(directory, name)
+		old, err := afero.ReadFile(fs, path)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if err == nil && bytes.Equal(old, files[name]) {
+			continue
+		}
+		differ = true
+		from := path
+		if err != nil {
+			from = os.DevNull
+		}
+		if err := writeDiff(w, from, path, old, files[name]); err != nil {
+			return false, err
+		}
+	}
+	return differ, nil
+}
+
+// diffRemoved writes the diff removing the generated files in directory
+// that are not among files to w.  It reports whether there are any.
+func diffRemoved(fs afero.Fs, directory string, files map[string][]byte, w io.Writer) (bool, error) {
+	stale, err := removedFiles(fs, directory, files)
+	if err != nil {
+		return false, err
+	}
+	for _, path := range stale {
+		old, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return false, err
+		}
+		if err := writeDiff(w, path, os.DevNull, old, nil); err != nil {
+			return false, err
+		}
+	}
+	return len(stale) > 0, nil
+}
+
+// removedFiles returns the paths of the generated files in directory that
+// are not among files.
+func removedFiles(fs afero.Fs, directory string, files map[string][]byte) ([]string, error) {
+	var stale []string
+	err := afero.Walk(fs, directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		name, relErr := filepath.Rel(directory, path)
+		if info.IsDir() || relErr != nil {
+			return nil
+		}
+		if _, ok := files[name]; !ok && isGenerated(fs, path) {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	return stale, err
+}
+
+// writeDiff writes the unified diff between the content a of the file from
+// and b of the file to to w.  Compressed content is uncompressed first.
+func writeDiff(w io.Writer, from string, to string, a []byte, b []byte) error {
+	text := func(name string, data []byte) string {
+		if strings.HasSuffix(name, ".gz") && len(data) > 0 {
+			if plain, err := gunzip(data); err == nil {
+				return string(plain)
+			}
+		}
+		return string(data)
+	}
+	return difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+		A:        difflib.SplitLines(text(from, a)),
+		B:        difflib.SplitLines(text(to, b)),
+		FromFile: from,
+		ToFile:   to,
+		Context:  3,
+	})
+}
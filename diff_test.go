@@ -0,0 +1,68 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDocs(t *testing.T) {
+	cmd := &cobra.Command{Use: "drift", Short: "Drift away"}
+	sub := &cobra.Command{Use: "sub", Short: "Sub command", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(sub)
+	fs := afero.NewMemMapFs()
+	date := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	opts := Options{Fs: fs, Date: &date}
+
+	buf := new(bytes.Buffer)
+	differ, err := DiffDocs(cmd, &opts, "docs", "troff", buf)
+	assert.NoError(t, err)
+	assert.True(t, differ)
+	assert.Contains(t, buf.String(), "--- /dev/null\n+++ docs/drift-sub.1\n")
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "docs", "troff"))
+	buf.Reset()
+	differ, err = DiffDocs(cmd, &opts, "docs", "troff", buf)
+	assert.NoError(t, err)
+	assert.False(t, differ)
+	assert.Empty(t, buf.String())
+
+	sub.Short = "Changed sub command"
+	cmd.AddCommand(&cobra.Command{Use: "new", Short: "New command", Run: func(cmd *cobra.Command, args []string) {}})
+	cmd.RemoveCommand(sub)
+	cmd.AddCommand(&cobra.Command{Use: "other", Short: "Other command", Run: func(cmd *cobra.Command, args []string) {}})
+	assert.NoError(t, afero.WriteFile(fs, "docs/notes.txt", []byte("hand written"), 0o644))
+	buf.Reset()
+	differ, err = DiffDocs(cmd, &opts, "docs", "troff", buf)
+	assert.NoError(t, err)
+	assert.True(t, differ)
+	assert.Contains(t, buf.String(), "--- docs/drift.1\n+++ docs/drift.1\n")
+	assert.Contains(t, buf.String(), "+.BR drift\\-new (1)\n")
+	assert.Contains(t, buf.String(), "--- docs/drift-sub.1\n+++ /dev/null\n")
+	assert.NotContains(t, buf.String(), "notes.txt")
+
+	buf.Reset()
+	differ, err = DiffDocs(cmd, &Options{Fs: fs, Date: &date, Gzip: true, Only: "new"}, "docs", "troff", buf)
+	assert.NoError(t, err)
+	assert.True(t, differ)
+	assert.Contains(t, buf.String(), "+++ docs/drift-new.1.gz\n")
+	assert.Contains(t, buf.String(), "+.TH \"DRIFT\\-NEW\"")
+	assert.NotContains(t, buf.String(), "drift-sub.1")
+}
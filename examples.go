@@ -0,0 +1,28 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/cobra"
+)
+
+// Example is a single entry of the EXAMPLES section, set with
+// annotations.SetStructuredExamples.
+type Example = annotations.Example
+
+// getExamples decodes the structured examples stored on cmd.
+func getExamples(cmd *cobra.Command) ([]Example, error) {
+	return annotations.StructuredExamples(cmd)
+}
@@ -0,0 +1,77 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructuredExamples(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+
+	examples, err := getExamples(cmd)
+	assert.NoError(t, err)
+	assert.Nil(t, examples)
+
+	annotations.SetStructuredExamples(cmd, Example{Description: "List things", Command: "foo list"}, Example{Command: "foo show", Output: "a thing"})
+	examples, err = getExamples(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, []Example{{Description: "List things", Command: "foo list"}, {Command: "foo show", Output: "a thing"}}, examples)
+
+	cmd.Annotations["man-examples"] = "not json"
+	_, err = getExamples(cmd)
+	assert.Error(t, err)
+	assert.Error(t, GenerateOnePage(cmd, &Options{}, "troff", new(bytes.Buffer)))
+}
+
+func TestStructuredExamplesSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	annotations.SetStructuredExamples(cmd, Example{Description: "Show the config", Command: "foo --show-config", Output: ".hidden: true"})
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH EXAMPLES\n.PP\nShow the config\n.PP\n.EX\nfoo \\\\-\\\\-show\\\\-config\n\\\\&.hidden: true\n.EE\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh EXAMPLES\n.Pp\nShow the config\n.Bd -literal -offset indent\nfoo", buf.String())
+
+	buf.Reset()
+	cmd.Example = "Free form text"
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "### Examples\n\nFree form text\n\nShow the config\n\n```shell\nfoo --show-config\n.hidden: true\n```\n", buf.String())
+}
+
+func TestExampleLanguage(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	annotations.SetStructuredExamples(cmd, Example{Command: "foo --show-config"})
+	opts := Options{ExampleLanguage: "console"}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "```console\nfoo --show-config\n```\n")
+
+	cmd.Annotations["man-example-language"] = "bash"
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "```bash\nfoo --show-config\n```\n")
+}
@@ -0,0 +1,113 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"errors"
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ErrNoIndexTemplate is returned when an index is asked for with a template
+// that has no index template registered.
+var ErrNoIndexTemplate = errors.New("the template has no index template registered")
+
+type indexStruct struct {
+	Date             *time.Time
+	Section          string
+	CommandPath      string
+	ShortDescription string
+
+	Commands []indexEntry
+
+	CustomData map[string]interface{}
+
+	headingOffset int
+}
+
+// Heading returns the markdown marker for a heading of the given level
+// moved down by Options.HeadingOffset.
+func (s indexStruct) Heading(level int) string {
+	return heading(level + s.headingOffset)
+}
+
+type indexEntry struct {
+	CommandPath string
+	Short       string
+	FileName    string
+	Link        string
+	Depth       int
+}
+
+// RegisterIndexTemplate adds a template for the index page to the already
+// registered template name.  The index page lists the whole command tree
+// and is written by GenerateDocs when Options.IndexFile is set.
+func RegisterIndexTemplate(name string, templateString string) {
+	t, ok := templateMap[name]
+	if !ok {
+		panic("the given template has not been registered: " + name)
+	}
+	t.index = template.Must(template.New(name + "-index").Funcs(templateFuncs).Parse(templateString))
+	templateMap[name] = t
+}
+
+// GenerateIndex writes an index page for cmd and all of its documented
+// children to w using the index template of templateName.
+func GenerateIndex(cmd *cobra.Command, opts *Options, templateName string, w io.Writer) error {
+	validate(opts, templateName)
+	if err := loadTemplates(opts, templateName); err != nil {
+		return err
+	}
+	setProvenance(cmd, opts, templateName)
+
+	t := opts.templateOf(templateName)
+	if t.index == nil {
+		return ErrNoIndexTemplate
+	}
+
+	values := indexStruct{
+		Date:             opts.Date,
+		Section:          opts.Section,
+		CommandPath:      cmd.CommandPath(),
+		ShortDescription: cmd.Short,
+		Commands:         genIndexEntries(cmd, opts, 0),
+		CustomData:       opts.CustomData,
+		headingOffset:    opts.HeadingOffset,
+	}
+	if opts.OmitDate {
+		values.Date = nil
+	}
+
+	return executeTemplate(t.index, values, t.extension, opts, w)
+}
+
+func genIndexEntries(cmd *cobra.Command, opts *Options, depth int) []indexEntry {
+	entries := []indexEntry{{
+		CommandPath: cmd.CommandPath(),
+		Short:       cmd.Short,
+		FileName:    fileName(cmd.CommandPath(), opts),
+		Link:        link(cmd.CommandPath(), cmd.Root().CommandPath(), opts),
+		Depth:       depth,
+	}}
+	for _, c := range cmd.Commands() {
+		if !isDocumented(c, opts) {
+			continue
+		}
+		entries = append(entries, genIndexEntries(c, opts, depth+1)...)
+	}
+	return entries
+}
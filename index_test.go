@@ -0,0 +1,59 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateIndex(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Short: "does foo"}
+	cmd2 := &cobra.Command{Use: "bar", Short: "does bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd3 := &cobra.Command{Use: "baz", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd4 := &cobra.Command{Use: "hidden", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.AddCommand(cmd3)
+	cmd.AddCommand(cmd2, cmd4)
+	opts := Options{}
+
+	assert.NoError(t, GenerateIndex(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# foo\n\ndoes foo\n\n## Commands\n\n", buf.String())
+	assert.Contains(t, buf.String(), "* [foo](foo.md) - does foo\n  * [foo bar](foo_bar.md) - does bar\n    * [foo bar baz](foo_bar_baz.md)\n")
+	assert.NotContains(t, buf.String(), "hidden")
+
+	assert.Equal(t, ErrNoIndexTemplate, GenerateIndex(cmd, &opts, "troff", buf))
+}
+
+func TestGenerateDocsIndexFile(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	opts := Options{IndexFile: "index.md"}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "", "markdown"))
+	checkForFile(t, "foo.md")
+	checkForFile(t, "index.md")
+
+	opts = Options{}
+	assert.NoError(t, GenerateDocs(cmd, &opts, "", "markdown"))
+	checkForFile(t, "foo.md")
+	checkFileNotExist(t, "index.md")
+}
+
+func TestRegisterIndexTemplate(t *testing.T) {
+	assert.Panics(t, func() { RegisterIndexTemplate("no exist", "index") })
+}
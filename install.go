@@ -0,0 +1,179 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// installManifest is where the install subcommand records the files it
+// wrote, relative to the prefix, with %s replaced by the name of the
+// application.
+const installManifest = "share/doc/%s/install-manifest.json"
+
+// ErrOutsideInstallRoot is returned by the uninstall subcommand when the
+// install manifest lists a file outside of --destdir and --prefix.
+var ErrOutsideInstallRoot = errors.New("file outside of the install root")
+
+// install writes the gzip compressed man pages of the first man page
+// template added with AddDocGenerator, or of troff if there is none, to
+// share/man/man<section>/ and the bash, zsh and fish completion scripts to
+// their standard directories under --destdir and --prefix.  The files are
+// recorded in a Manifest for uninstall.  The pages of other man page
+// templates would have the same names, so they are not installed.
+func (dg *DocGenTool) install(myCmd *cobra.Command) error {
+	root := filepath.Join(dg.destDir, dg.prefix)
+	templateName := dg.manTemplate()
+	m := &manifest{Manifest: Manifest{Generator: generatedMarker, Template: "install", Files: []ManifestEntry{}}, directory: root}
+
+	installOpts := *dg.flagOptions(dg.fileOptions(dg.templateOptions(templateName)), myCmd)
+	installOpts.commands = dg.commands
+	installOpts.manifest = m
+	installOpts.ManPathLayout = true
+	installOpts.Gzip = true
+	// Only the pages belong in share/man, and other applications may
+	// have generated pages there.
+	installOpts.SingleFile = ""
+	installOpts.IndexFile = ""
+	installOpts.TreeDiagramFile = ""
+	installOpts.ManifestFile = ""
+	installOpts.Prune = false
+	if installOpts.FileMode == 0 {
+		installOpts.FileMode = 0o644
+	}
+	if err := GenerateDocs(dg.appCmd, &installOpts, root, templateName); err != nil {
+		return err
+	}
+
+	name := dg.appCmd.Name()
+	opts := &Options{
+		Fs:           dg.templateOptions(templateName).Fs,
+		FileMode:     0o644,
+		DryRun:       dg.dryRun,
+		DryRunOutput: myCmd.OutOrStdout(),
+		manifest:     m,
+	}
+	for _, completion := range shellCompletions {
+		if completion.installPath == "" {
+			continue
+		}
+		generate := completion.generate
+		path := filepath.Join(root, filepath.FromSlash(fmt.Sprintf(completion.installPath, name)))
+		err := createFile(path, dg.appCmd.CommandPath(), opts, func(w io.Writer) error {
+			return generate(dg.appCmd, w)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	opts.manifest = nil
+	return createFile(filepath.Join(root, filepath.FromSlash(fmt.Sprintf(installManifest, name))), dg.appCmd.CommandPath(), opts, m.write)
+}
+
+// uninstall removes the files listed in the Manifest install wrote under
+// --destdir and --prefix, and the manifest itself.  Files that are already
+// gone are skipped, and nothing is removed if the manifest lists a file
+// outside of the install root.
+func (dg *DocGenTool) uninstall(myCmd *cobra.Command) error {
+	root := filepath.Join(dg.destDir, dg.prefix)
+	fs := dg.templateOptions(dg.manTemplate()).Fs
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	manifestPath := filepath.Join(root, filepath.FromSlash(fmt.Sprintf(installManifest, dg.appCmd.Name())))
+	data, err := afero.ReadFile(fs, manifestPath)
+	if err != nil {
+		return fmt.Errorf("no install manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%s: %w", manifestPath, err)
+	}
+
+	paths := make([]string, 0, len(m.Files)+1)
+	for _, entry := range m.Files {
+		path := filepath.Join(root, filepath.FromSlash(entry.File))
+		if !inDir(root, path) {
+			return fmt.Errorf("%s: %w: %s", manifestPath, ErrOutsideInstallRoot, entry.File)
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range append(paths, manifestPath) {
+		if dg.dryRun {
+			if _, err := fmt.Fprintf(myCmd.OutOrStdout(), "would remove %s\n", path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// inDir reports whether path is dir or a path below it.
+func inDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// clean removes the files generated with the templates added with
+// AddDocGenerator from their directories, the ones listed in their
+// manifest and their pages, leaving the other files alone.
+func (dg *DocGenTool) clean(myCmd *cobra.Command) error {
+	if len(dg.registered) == 0 {
+		return ErrNoGenerator
+	}
+	for _, templateName := range dg.registered {
+		opts := *dg.flagOptions(dg.fileOptions(dg.generators[templateName]), myCmd)
+		validate(&opts, templateName)
+		// Nothing is written, so every page of the template is stale.
+		if err := newPruner(dg.directory(myCmd, templateName), templateName, &opts).prune(&opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manTemplate returns the first man page template added with
+// AddDocGenerator, or troff if there is none.
+func (dg *DocGenTool) manTemplate() string {
+	for _, templateName := range dg.registered {
+		probe := Options{}
+		validate(&probe, templateName)
+		if probe.manTemplate {
+			return templateName
+		}
+	}
+	return "troff"
+}
+
+// templateOptions returns the Options given to AddDocGenerator for
+// templateName, or the default ones.
+func (dg *DocGenTool) templateOptions(templateName string) *Options {
+	if opts := dg.generators[templateName]; opts != nil {
+		return opts
+	}
+	return &Options{}
+}
@@ -0,0 +1,86 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Logger gets what GenerateDocs does, as a message and key value pairs.
+// The *slog.Logger of Go 1.21 satisfies it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+}
+
+// logger returns Options.Logger, or a logger discarding everything.
+func logger(opts *Options) Logger {
+	if opts.Logger == nil {
+		return discardLogger{}
+	}
+	return opts.Logger
+}
+
+// discardLogger is a Logger discarding everything.
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string, args ...interface{}) {}
+
+func (discardLogger) Info(msg string, args ...interface{}) {}
+
+// textLogger is the Logger of the --verbose flag of the doc tool.  It
+// writes a line of key=value pairs for each message, like the text handler
+// of log/slog.
+type textLogger struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func (l *textLogger) Debug(msg string, args ...interface{}) {
+	l.log("DEBUG", msg, args)
+}
+
+func (l *textLogger) Info(msg string, args ...interface{}) {
+	l.log("INFO", msg, args)
+}
+
+func (l *textLogger) log(level string, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString("level=" + level + " msg=" + logValue(msg))
+	for i := 0; i+1 < len(args); i += 2 {
+		b.WriteString(" " + fmt.Sprint(args[i]) + "=" + logValue(fmt.Sprint(args[i+1])))
+	}
+	b.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, b.String())
+}
+
+// logValue returns value quoted when it is empty or holds spaces, quotes,
+// equal signs or characters that are not printable.
+func logValue(value string) string {
+	needsQuotes := value == "" || strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0
+	if needsQuotes {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -16,15 +16,25 @@
 package cobraman
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
-	"reflect"
-	"runtime"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -32,8 +42,97 @@ import (
 // ErrMissingCommandName is returned with no command is provided.
 var ErrMissingCommandName = errors.New("you need a command name to have a man page")
 
-// Options is used configure how GenerateManPages will
-// do its job.
+// ErrIncompleteDocs is returned when Options.Strict is set and commands or
+// flags are not documented.
+var ErrIncompleteDocs = errors.New("incomplete documentation")
+
+// ErrNotGenerated is returned when Options.ProtectFiles is set and a file
+// that would be overwritten was not generated.
+var ErrNotGenerated = errors.New("refusing to overwrite a file that was not generated")
+
+// ErrUnknownCommand is returned when a command path, like Options.Only,
+// names a command that does not exist.
+var ErrUnknownCommand = errors.New("unknown command")
+
+// PageError is the failure to generate the page of a command, returned
+// joined with the others by GenerateDocs when Options.ContinueOnError is
+// set.
+type PageError struct {
+	// Command is the path of the command, like "app sub".
+	Command string
+
+	// Err is why the page could not be generated.
+	Err error
+}
+
+func (e *PageError) Error() string {
+	return e.Command + ": " + e.Err.Error()
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// joinedError is several errors in one, like the errors.Join of Go 1.20.
+// errors.Is and errors.As look through all of them.
+type joinedError struct {
+	errs []error
+}
+
+// joinErrors returns the errors of errs that are not nil joined in one, the
+// error itself if there is only one, or nil if there are none.
+func joinErrors(errs ...error) error {
+	joined := &joinedError{}
+	for _, err := range errs {
+		if err != nil {
+			joined.errs = append(joined.errs, err)
+		}
+	}
+	switch len(joined.errs) {
+	case 0:
+		return nil
+	case 1:
+		return joined.errs[0]
+	default:
+		return joined
+	}
+}
+
+// Error returns the messages of the errors, one per line.
+func (e *joinedError) Error() string {
+	messages := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e *joinedError) Unwrap() []error {
+	return e.errs
+}
+
+func (e *joinedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *joinedError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Options is used configure how GenerateDocs and the other generators do
+// their job.  NewOptions builds them with the With functions.  The fields
+// apply to all templates unless their doc names the man page templates,
+// troff and mdoc, or markdown.
 type Options struct {
 	// What section to generate the pages 4 (1 is the default if not set)
 	Section string
@@ -43,9 +142,16 @@ type Options struct {
 	CenterFooter string
 
 	// If you just want to set the date used in the center footer use Date
-	// Will default to Now
+	// Will default to the SOURCE_DATE_EPOCH environment variable, for
+	// reproducible builds, or else to Now
 	Date *time.Time
 
+	// OmitDate leaves the date out of the generated pages so regenerating
+	// them without changes to the application gives the same output.  The
+	// center footer is then empty unless CenterFooter is set and mdoc pages
+	// get an empty .Dd.
+	OmitDate bool
+
 	// LeftFooter used across all pages
 	LeftFooter string
 
@@ -55,75 +161,1015 @@ type Options struct {
 	// Files if set with content will create a FILES section for all
 	// pages.  If you want this section only for a single command add
 	// it as an annotation: cmd.Annotations["man-files-section"]
-	// The field will be sanitized for troff output. However, if
-	// it starts with a '.' we assume it is valid troff and pass it through.
+	// The field will be sanitized for troff output.  Put raw troff
+	// between lines holding .\"RAW to pass it through.
 	Files string
 
+	// ConfigFiles lists the configuration files the application looks for,
+	// in order of precedence.  They are described in the FILES section of
+	// all pages after the content of Files.
+	ConfigFiles []string
+
 	// Bugs if set with content will create a BUGS section for all
 	// pages.  If you want this section only for a single command add
 	// it as an annotation: cmd.Annotations["man-bugs-section"]
-	// The field will be sanitized for troff output. However, if
-	// it starts with a '.' we assume it is valid troff and pass it through.
+	// The field will be sanitized for troff output.  Put raw troff
+	// between lines holding .\"RAW to pass it through.
 	Bugs string
 
 	// Environment if set with content will create a ENVIRONMENT section for all
 	// pages.  If you want this section only for a single command add
 	// it as an annotation: cmd.Annotations["man-environment-section"]
-	// The field will be sanitized for troff output. However, if
-	// it starts with a '.' we assume it is valid troff and pass it through.
+	// The field will be sanitized for troff output.  Put raw troff
+	// between lines holding .\"RAW to pass it through.
 	Environment string
 
+	// Diagnostics if set with content will create a DIAGNOSTICS section for
+	// all pages documenting warning and error messages.  If you want this
+	// section only for a single command add it as an annotation:
+	// cmd.Annotations["man-diagnostics-section"]
+	// The field will be sanitized for troff output.  Put raw troff
+	// between lines holding .\"RAW to pass it through.
+	Diagnostics string
+
+	// CustomSectionsAfter names the standard section, like "OPTIONS", "FILES"
+	// or "SEE ALSO", after which the sections added with annotations of the
+	// form cmd.Annotations["man-section-<NAME>"] are placed.  Defaults to
+	// "EXAMPLES" which puts them right before AUTHOR.
+	CustomSectionsAfter string
+
+	// EnvVars if set will list these environment variables in the ENVIRONMENT
+	// section.  Variables tied to a flag are only listed on the pages of the
+	// commands that have that flag.  See the viperman package to build this
+	// list from a viper instance.
+	EnvVars []EnvVar
+
 	// Author if set will create a Author section with this content.
 	Author string
 
+	// SeeAlso adds references to other man pages (e.g. "crontab(5)") to the
+	// SEE ALSO section of all pages.  They are listed after the generated
+	// parent, sibling and child references.  If you want these references
+	// only for a single command add them as a comma separated annotation:
+	// cmd.Annotations["man-see-also"]
+	SeeAlso []string
+
+	// SeeAlsoExcludeSiblings stops sibling commands from being listed in
+	// the SEE ALSO section.
+	SeeAlsoExcludeSiblings bool
+
+	// SeeAlsoExcludeChildren stops sub-commands from being listed in the
+	// SEE ALSO section.
+	SeeAlsoExcludeChildren bool
+
+	// SeeAlsoParentOnly limits the generated SEE ALSO references to the
+	// direct parent of the command.  References added with SeeAlso are
+	// still included.
+	SeeAlsoParentOnly bool
+
+	// IncludeDeprecated documents deprecated commands and flags instead of
+	// skipping them.  Deprecated flags are listed in a separate DEPRECATED
+	// OPTIONS subsection and the pages of deprecated commands get a notice
+	// with the deprecation message.
+	IncludeDeprecated bool
+
+	// IncludeHidden documents hidden commands and flags instead of
+	// skipping them, for internal docs built from the same tool as the
+	// public ones.  Cobra's hidden shell completion commands are still
+	// skipped.
+	IncludeHidden bool
+
+	// Provenance stamps every page, index and single file with a comment
+	// naming the cobraman version, the name and version of the application
+	// and the template and section used, to trace which toolchain produced
+	// a shipped page.  Only man page and markdown templates get it.
+	Provenance bool
+
+	// Strict makes GenerateDocs fail with ErrIncompleteDocs, before writing
+	// anything, when a documented command has no Short or Long description
+	// or one of its flags has no usage, so doc regressions are caught at
+	// build time.
+	Strict bool
+
+	// OmitZeroDefaults only documents flag defaults that carry meaning.
+	// Defaults like false, 0 or an empty string are left out while defaults
+	// of flags that take no argument, like a bool defaulting to true, are
+	// added.
+	OmitZeroDefaults bool
+
+	// TreeDiagramFile if set makes GenerateDocs also write a markdown page
+	// with this file name holding a Mermaid flowchart of the command tree.
+	TreeDiagramFile string
+
+	// HeadingOffset moves all markdown headings down by this many levels so
+	// the pages can be included in a larger document.  With 1 a page starts
+	// at ### instead of ##.
+	HeadingOffset int
+
+	// ExampleLanguage is the language of the code fences around structured
+	// examples in markdown, for syntax highlighting.  Defaults to "shell".
+	// If you want another language for a single command add it as an
+	// annotation: cmd.Annotations["man-example-language"]
+	ExampleLanguage string
+
+	// NestedDirs places the pages in directories following the command
+	// tree, "app remote add" goes to remote/add.md next to remote.md,
+	// instead of using the flat file names man pages need.  Meant for
+	// templates like markdown.
+	NestedDirs bool
+
+	// FlagAnchors adds an anchor to every flag so other documents can link
+	// to its description, in templates that support it like markdown.
+	FlagAnchors bool
+
+	// FlagSlug returns the anchor ID of the flag called name.  Defaults to
+	// "flag-" followed by the name (e.g. "flag-output").
+	FlagSlug func(name string) string
+
+	// SlugFunc turns a space separated command path, or a flag name, into
+	// the identifier used for file names, links and anchors so they can
+	// follow the URL conventions of a docs site.  The file extension is
+	// added after it.  With NestedDirs it is called for every part of the
+	// path.  By default the parts are joined with the separator of the
+	// template, "-" for man pages and "_" for markdown.
+	SlugFunc func(str string) string
+
+	// SectionDirs puts the pages of man page templates in a man<section>/
+	// directory, like man1/app.1, so the output directory can be used in
+	// MANPATH or copied into a package as it is.  Index, tree diagram and
+	// manifest files stay in the output directory.
+	SectionDirs bool
+
+	// ManPathLayout puts the pages of man page templates in
+	// share/man/man<section>/, the layout make install, Homebrew and nfpm
+	// expect, so the output directory can be used as an install prefix.
+	ManPathLayout bool
+
+	// Gzip compresses the pages of man page templates with gzip and adds
+	// .gz to their file names, as most distributions install them.
+	Gzip bool
+
+	// AliasPages makes man page templates write a page for every alias of a
+	// command, from cmd.Aliases and annotations.AddAliases, that only
+	// includes the page of the command with ".so man1/app-sub.1", so man
+	// finds the command under all its names.
+	AliasPages bool
+
+	// Filter reports whether cmd is documented.  Commands it returns false
+	// for, and their children, get no page and are not referenced from
+	// other pages, like experimental or internal commands that are not
+	// hidden in the application itself.  It is not called for the root
+	// command.
+	Filter func(cmd *cobra.Command) bool
+
+	// Only makes GenerateDocs write only the pages of the command with this
+	// path, like "app remote" or "remote", and of its children, for large
+	// applications where only part of the docs changed.  The index and tree
+	// diagram still cover the whole application.  Prune is skipped when
+	// Only or MaxDepth is set.
+	Only string
+
+	// MaxDepth limits the levels of commands GenerateDocs writes pages for,
+	// counting the root command, or the Only command, as 1.  0 means no
+	// limit.
+	MaxDepth int
+
+	// PathFor returns the path of the page of cmd, relative to the directory
+	// given to GenerateDocs, for naming schemes the other options do not
+	// cover.  format is the name of the template.  The default path is used
+	// when it returns "".  Links between pages still use the default names,
+	// use LinkHandler to change them too.
+	PathFor func(cmd *cobra.Command, format string) string
+
+	// FilePrefix and FileSuffix are put around the name of every page file,
+	// before the extension, like "vendor-" for vendor-app-sub.1.
+	FilePrefix string
+	FileSuffix string
+
+	// FileExtension replaces the extension of the page files, like "3" or
+	// "markdown".  Defaults to the section for man page templates and "md"
+	// for markdown.  It does not change the section of the pages.
+	FileExtension string
+
+	// TemplateFile if set is a file holding a template replacing the
+	// registered page template, read at generation time so templates can
+	// be changed without recompiling.  The separator and extension of the
+	// registered template are kept.
+	TemplateFile string
+
+	// TemplateDir if set is a directory holding templates replacing the
+	// registered ones of the same name: <name>.tmpl for the pages,
+	// <name>-index.tmpl for the index and <name>-single.tmpl for the single
+	// file.  Missing files keep the registered templates and TemplateFile
+	// wins over <name>.tmpl.
+	TemplateDir string
+
+	// TemplateFS if set is a file system, like an embed.FS, holding
+	// templates named as in TemplateDir replacing the registered ones, so
+	// they can be built into the application.  The files of TemplateDir and
+	// TemplateFile win over the ones in it.
+	TemplateFS fs.FS
+
+	// PageHeader is put at the top of every page, like badges or a link to
+	// the project homepage, in templates that support it like markdown.
+	PageHeader string
+
+	// PageHeaderFunc returns the header for the page of cmd.  It replaces
+	// PageHeader when set.
+	PageHeaderFunc func(cmd *cobra.Command) string
+
+	// AdmonitionStyle sets how notices like the deprecation of a command or
+	// the "man-warning-section" annotation are rendered in markdown.  Use
+	// AdmonitionGFM for GitHub alerts or AdmonitionDocusaurus for Docusaurus
+	// admonitions, they are bold paragraphs by default.
+	AdmonitionStyle string
+
+	// FilePrepender returns text written at the top of the file with the
+	// given name before the generated page, like front matter for a static
+	// site generator.  It works like the filePrepender of
+	// cobra/doc.GenMarkdownTreeCustom.
+	FilePrepender func(filename string) string
+
+	// PreRender is called with the data of the page of cmd before it is
+	// rendered with a template, and can change it.  Returning an error
+	// stops the generation.  With Options.Jobs it is called concurrently.
+	PreRender func(cmd *cobra.Command, ctx *DocContext) error
+
+	// PostRender is called with the content of the page file of cmd at
+	// path, after Options.FilePrepender and before compression, and returns
+	// the content written instead, like with legal boilerplate added.
+	// Returning an error stops the generation.  With Options.Jobs it is
+	// called concurrently.
+	PostRender func(cmd *cobra.Command, path string, content []byte) ([]byte, error)
+
+	// CreateFile opens the file at path, joined to the directory given to
+	// GenerateDocs, for writing a page.  It lets pages be written to memory,
+	// a zip file or test fixtures.  Defaults to creating the file, and the
+	// directories it is in, in the file system.
+	CreateFile func(path string) (io.WriteCloser, error)
+
+	// Logger if set gets the files GenerateDocs writes at the info level,
+	// and the commands it skips with the reason at the debug level.  A
+	// *slog.Logger can be given.
+	Logger Logger
+
+	// Progress if set is called by GenerateDocs after each file it writes
+	// with the number of files written so far, the number it will write and
+	// the name of the file, to show progress for large applications.
+	Progress func(done int, total int, file string)
+
+	// Jobs is the number of pages GenerateDocs generates concurrently, for
+	// applications with many commands.  0 or 1 generates them one after
+	// the other.  With more the files are written, logged and reported to
+	// Progress in no particular order, CreateFile must be safe for
+	// concurrent use and the commands must not change meanwhile.
+	Jobs int
+
+	// ContinueOnError makes GenerateDocs go on with the other pages when
+	// the page of a command fails, like on a bad annotation or template
+	// error, and return the failures of all the pages, each a *PageError,
+	// joined in one error; use errors.As to find them.  The index and
+	// manifest are still written, but Prune is skipped so the pages that
+	// failed are not removed.
+	ContinueOnError bool
+
+	// DryRun makes GenerateDocs render every page without writing it and
+	// report the files it would write, with their size, to DryRunOutput.
+	DryRun bool
+
+	// DryRunOutput is where DryRun reports the files.  Defaults to
+	// os.Stdout.
+	DryRunOutput io.Writer
+
+	// FileMode is the mode of the files GenerateDocs writes, like 0o644.
+	// Defaults to 0o666 reduced by the umask, as os.Create does.
+	FileMode os.FileMode
+
+	// DirMode is the mode of the directories GenerateDocs creates for the
+	// files, reduced by the umask.  Defaults to 0o755.
+	DirMode os.FileMode
+
+	// WriteIfChanged makes GenerateDocs leave the files whose content did
+	// not change untouched, keeping their modification time so make based
+	// pipelines do not rebuild everything after each run.  It is ignored
+	// when CreateFile is set.
+	WriteIfChanged bool
+
+	// ProtectFiles makes GenerateDocs refuse to overwrite files without the
+	// "auto-generated by" comment of the templates, like hand-written man
+	// pages in the same directory, and return ErrNotGenerated instead.  It
+	// is ignored when CreateFile is set.
+	ProtectFiles bool
+
+	// Prune makes GenerateDocs remove the files of the output directory it
+	// generated before but not this time, like the pages of renamed or
+	// removed commands.  Such files are listed in the previous ManifestFile,
+	// if it was written with the same template, or are pages with the file
+	// extension of the template holding the "auto-generated by" comment of
+	// the templates.  Subdirectories are only searched when the template
+	// writes pages into them, like with NestedDirs, so other formats can
+	// share the directory.  With DryRun they are only reported.  It is
+	// ignored when CreateFile is set.
+	Prune bool
+
+	// ManifestFile if set makes GenerateDocs write a JSON Manifest with this
+	// name, listing every file it generated with its SHA-256 and the path
+	// of the command it documents.
+	ManifestFile string
+
+	// Fs is the file system GenerateDocs writes to when CreateFile is not
+	// set, like an in-memory or overlay file system for tests and build
+	// pipelines.  Defaults to the operating system file system.
+	Fs afero.Fs
+
+	// LinkHandler turns the file name of a page into the link used to
+	// refer to it from other pages, in templates that support it like
+	// markdown.  It works like the linkHandler of
+	// cobra/doc.GenMarkdownTreeCustom.
+	LinkHandler func(filename string) string
+
+	// SingleFile if set makes GenerateDocs write the documentation of the
+	// whole command tree to one file with this name, with nested headings
+	// and a table of contents, instead of one file per command.  Only
+	// templates with a single file template, like markdown, support this.
+	SingleFile string
+
+	// IndexFile if set makes GenerateDocs also write an index page with this
+	// file name listing the whole command tree.  Only templates with an
+	// index template, like markdown, support this.
+	IndexFile string
+
+	// FlagTable renders the options as a table with a column for the flag,
+	// shorthand, type, default and description in templates that support
+	// it, like markdown.  Tables are a GitHub Flavored Markdown extension,
+	// with DialectCommonMark the options stay a list.
+	FlagTable bool
+
+	// MarkdownDialect is the flavor of markdown to write, DialectGFM (the
+	// default) or DialectCommonMark.  Strict CommonMark leaves out the GFM
+	// extensions like tables and alerts for renderers that do not support
+	// them.
+	MarkdownDialect string
+
+	// ParseMarkdown treats the descriptions, examples and section contents
+	// of commands as markdown.  Paragraphs, emphasis, lists, code and
+	// headings are rendered into the troff and mdoc man pages instead of
+	// showing up as written.  Emphasis and code in flag usages are
+	// rendered too, and left unescaped in markdown pages.
+	ParseMarkdown bool
+
+	// MarkdownLint cleans up markdown output so it passes the common
+	// markdownlint rules: pages start with a level 1 heading, lines are
+	// wrapped at MarkdownLineLength, bare URLs become autolinks and blank
+	// lines are added or removed where the rules ask for them.
+	MarkdownLint bool
+
+	// MarkdownLineLength is the line length MarkdownLint wraps at.
+	// Defaults to 80.
+	MarkdownLineLength int
+
+	// UTF8 writes non-ASCII characters to troff and mdoc pages as they are,
+	// for groff -Kutf8 and mandoc.  By default they are written as groff
+	// special characters like \(em or \[uXXXX] escapes so the pages also
+	// work with troff implementations that only know ASCII.
+	UTF8 bool
+
+	// Hyphenate lets groff hyphenate words in troff pages, which are
+	// written with hyphenation turned off (.nh) by default.
+	Hyphenate bool
+
+	// Justify adjusts the text of troff pages to both margins (.ad b)
+	// instead of leaving the right margin ragged (.ad l).
+	Justify bool
+
+	// WrapLines breaks the long lines of generated troff, mdoc and markdown
+	// pages at spaces so they are easier to diff and review.  Code blocks,
+	// tables and macro lines are not wrapped.
+	WrapLines bool
+
+	// LineWidth is the width WrapLines wraps at.  Defaults to 78.
+	LineWidth int
+
 	// Private fields
 
 	// fileCmdSeparator defines what character to use to separate the
 	// sub commands in the man page file name.  The '-' char is the default.
 	fileCmdSeparator string
 
-	// fileSuffix is the file extension to use for file name.  Defaults to the section
+	// manTemplate is set for templates writing man pages.
+	manTemplate bool
+
+	// sectionDir is the directory SectionDirs or ManPathLayout put the
+	// pages in, empty for templates that are not man pages.
+	sectionDir string
+
+	// fileExtension is the file extension to use for file name.  Defaults to the section
 	// for man templates and .md for the MarkdownTemplate template.
-	fileSuffix string
+	fileExtension string
+
+	// manifest collects the files written while ManifestFile is set.
+	manifest *manifest
+
+	// written are the files written while Prune is set.
+	written map[string]bool
+
+	// progress counts the files written while Progress is set.
+	progress *progress
+
+	// mu guards manifest, written, progress and the output of DryRun while
+	// Jobs write files concurrently.
+	mu *sync.Mutex
+
+	// commands caches the data of the commands that does not depend on the
+	// template, shared by the formats the doc tool generates.
+	commands *commandCache
+
+	// templates are the templates loaded from TemplateFile and TemplateDir.
+	templates *loadedTemplates
+
+	// ctx is the context given to GenerateDocsContext or
+	// GenerateOnePageContext.
+	ctx context.Context
+
+	// provenance is the comment Provenance stamps the files with.
+	provenance string
 
 	// CustomData allows passing custom data into the template
 	CustomData map[string]interface{}
 }
 
+// Styles for Options.AdmonitionStyle.
+const (
+	// AdmonitionGFM renders notices as GitHub alerts (> [!WARNING]).
+	AdmonitionGFM = "gfm"
+
+	// AdmonitionDocusaurus renders notices as Docusaurus admonitions
+	// (:::warning).
+	AdmonitionDocusaurus = "docusaurus"
+)
+
+// Dialects for Options.MarkdownDialect.
+const (
+	// DialectGFM is GitHub Flavored Markdown.
+	DialectGFM = "gfm"
+
+	// DialectCommonMark is strict CommonMark without extensions.
+	DialectCommonMark = "commonmark"
+)
+
+// EnvVar describes an environment variable read by the application.
+type EnvVar struct {
+	// Name of the environment variable (e.g. "APP_CONFIG")
+	Name string
+
+	// Flag is the name of the flag the variable maps to, if any
+	Flag string
+
+	// Description of the variable.  Defaults to the usage of Flag.
+	Description string
+}
+
+// GenerateDocsContext is GenerateDocs with a context.  It is passed to the
+// Render method of a Generator, and the pages not generated yet fail with
+// its error once it is done.
+func GenerateDocsContext(ctx context.Context, cmd *cobra.Command, opts *Options, directory string, templateName string) error {
+	opts.ctx = ctx
+	defer func() { opts.ctx = nil }()
+	return GenerateDocs(cmd, opts, directory, templateName)
+}
+
 // GenerateDocs - build man pages for the passed in cobra.Command
 // and all of its children.
-func GenerateDocs(cmd *cobra.Command, opts *Options, directory string, templateName string) (err error) {
+func GenerateDocs(cmd *cobra.Command, opts *Options, directory string, templateName string) error {
 	// Set defaults
 	validate(opts, templateName)
 	if directory == "" {
 		directory = "."
 	}
+	if err := loadTemplates(opts, templateName); err != nil {
+		return err
+	}
+	setProvenance(cmd, opts, templateName)
+
+	if opts.Progress != nil {
+		opts.progress = &progress{}
+		defer func() { opts.progress = nil }()
+	}
+	opts.mu = &sync.Mutex{}
+	defer func() { opts.mu = nil }()
+	if opts.commands == nil {
+		opts.commands = newCommandCache()
+		defer func() { opts.commands = nil }()
+	}
+
+	stale := newPrunerFor(directory, templateName, opts)
+	defer func() { opts.written = nil }()
+
+	// With ContinueOnError the pages that failed are returned, the rest of
+	// the files are still written.
+	err := writeDocs(cmd, opts, directory, templateName)
+	if err != nil && !isPageErrors(opts, err) {
+		return err
+	}
+	if stale == nil || err != nil {
+		return err
+	}
+	return stale.prune(opts)
+}
+
+// newPrunerFor returns the pruner removing the files GenerateDocs does not
+// write anymore, or nil when Options.Prune is not set.  Pages outside of
+// Only or MaxDepth are not stale, so nothing is pruned with them either.
+func newPrunerFor(directory string, templateName string, opts *Options) *pruner {
+	if !opts.Prune || opts.CreateFile != nil || opts.Only != "" || opts.MaxDepth != 0 {
+		return nil
+	}
+	stale := newPruner(directory, templateName, opts)
+	opts.written = stale.written
+	return stale
+}
 
+// writeDocs writes the files of GenerateDocs and the manifest listing them.
+func writeDocs(cmd *cobra.Command, opts *Options, directory string, templateName string) error {
+	if opts.ManifestFile == "" {
+		return generateAllDocs(cmd, opts, directory, templateName)
+	}
+	m := &manifest{Manifest: Manifest{Generator: generatedMarker, Template: templateName, Files: []ManifestEntry{}}, directory: directory}
+	opts.manifest = m
+	err := generateAllDocs(cmd, opts, directory, templateName)
+	opts.manifest = nil
+	if err != nil && !isPageErrors(opts, err) {
+		return err
+	}
+	if opts.Jobs > 1 {
+		m.sort()
+	}
+	if manifestErr := createFile(filepath.Join(directory, opts.ManifestFile), cmd.CommandPath(), opts, m.write); manifestErr != nil {
+		return manifestErr
+	}
+	return err
+}
+
+// docPages returns the commands GenerateDocs writes a page for, checked
+// for Options.Strict.
+func docPages(cmd *cobra.Command, opts *Options) ([]*cobra.Command, error) {
+	var pages []*cobra.Command
+	if opts.SingleFile == "" || opts.Strict {
+		start := cmd
+		if opts.Only != "" {
+			var err error
+			if start, err = findCommand(cmd, opts.Only); err != nil {
+				return nil, err
+			}
+		}
+		pages = pageCommands(start, opts, 1)
+	}
+	if opts.Strict {
+		if err := checkComplete(pages, opts); err != nil {
+			return nil, err
+		}
+		if opts.SingleFile != "" {
+			pages = nil
+		}
+	}
+	return pages, nil
+}
+
+// generateAllDocs writes the files of GenerateDocs other than the
+// manifest.
+func generateAllDocs(cmd *cobra.Command, opts *Options, directory string, templateName string) error {
+	pages, err := docPages(cmd, opts)
+	if err != nil {
+		return err
+	}
+	if opts.progress != nil {
+		opts.progress.total = countFiles(pages, opts)
+	}
+
+	if opts.TreeDiagramFile != "" {
+		err := createFile(filepath.Join(directory, opts.TreeDiagramFile), cmd.CommandPath(), opts, func(w io.Writer) error {
+			return GenerateTreeDiagram(cmd, opts, w)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.SingleFile != "" {
+		return createFile(filepath.Join(directory, opts.SingleFile), cmd.CommandPath(), opts, func(w io.Writer) error {
+			return GenerateSingleFile(cmd, opts, templateName, w)
+		})
+	}
+
+	pageErrs := generatePages(pages, opts, directory, templateName)
+	if pageErrs != nil && !opts.ContinueOnError {
+		return pageErrs
+	}
+
+	if opts.IndexFile != "" {
+		err := createFile(filepath.Join(directory, opts.IndexFile), cmd.CommandPath(), opts, func(w io.Writer) error {
+			return GenerateIndex(cmd, opts, templateName, w)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return pageErrs
+}
+
+// isPageErrors reports whether err holds only failed pages that
+// Options.ContinueOnError went on after.
+func isPageErrors(opts *Options, err error) bool {
+	var pageErr *PageError
+	return opts.ContinueOnError && errors.As(err, &pageErr)
+}
+
+// RenderDocs renders the pages GenerateDocs would write for cmd and all of
+// its children and returns them keyed by file name, without touching the
+// file system.  Options.CreateFile and Options.Fs are not used.
+func RenderDocs(cmd *cobra.Command, opts *Options, templateName string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	renderOpts := *opts
+	renderOpts.DryRun = false
+	var mu sync.Mutex
+	renderOpts.CreateFile = func(path string) (io.WriteCloser, error) {
+		return &memoryFile{path: path, files: files, mu: &mu}, nil
+	}
+	if err := GenerateDocs(cmd, &renderOpts, "", templateName); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// memoryFile is a file of RenderDocs.  Its content is added to files when
+// it is closed.
+type memoryFile struct {
+	bytes.Buffer
+	path  string
+	files map[string][]byte
+	mu    *sync.Mutex
+}
+
+func (f *memoryFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.files[f.path] = f.Bytes()
+	return nil
+}
+
+// pageCommands returns cmd, at the given depth, and its children down to
+// Options.MaxDepth that get a page, children first.
+func pageCommands(cmd *cobra.Command, opts *Options, depth int) []*cobra.Command {
+	var pages []*cobra.Command
 	for _, c := range cmd.Commands() {
-		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+		reason := skipReason(c, opts)
+		if reason == "" && opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+			reason = "below MaxDepth"
+		}
+		if reason != "" {
+			logger(opts).Debug("skipping command", "command", c.CommandPath(), "reason", reason)
 			continue
 		}
-		if err := GenerateDocs(c, opts, directory, templateName); err != nil {
-			return err
+		pages = append(pages, pageCommands(c, opts, depth+1)...)
+	}
+	return append(pages, cmd)
+}
+
+// countFiles returns the number of files GenerateDocs writes for pages.
+func countFiles(pages []*cobra.Command, opts *Options) int {
+	total := len(pages)
+	if opts.AliasPages && opts.manTemplate {
+		for _, c := range pages {
+			total += len(c.Aliases) + len(annotations.Aliases(c))
 		}
 	}
+	for _, name := range []string{opts.TreeDiagramFile, opts.SingleFile, opts.IndexFile, opts.ManifestFile} {
+		if name != "" {
+			total++
+		}
+	}
+	if opts.SingleFile != "" && opts.IndexFile != "" {
+		total--
+	}
+	return total
+}
 
-	// Generate file name and open the file
-	basename := strings.ReplaceAll(cmd.CommandPath(), " ", opts.fileCmdSeparator)
-	if basename == "" {
+// generatePage writes the page of cmd, and its alias pages.
+func generatePage(cmd *cobra.Command, opts *Options, directory string, templateName string) error {
+	if cmd.CommandPath() == "" {
 		return ErrMissingCommandName
 	}
-	filename := filepath.Join(directory, basename+"."+opts.fileSuffix)
-	f, err := os.Create(filename) //nolint:gosec // the file is constructed safely
+	filename := filepath.Join(directory, pageFilename(cmd, opts, templateName))
+	generate := func(w io.Writer) error {
+		if opts.FilePrepender != nil {
+			if _, err := io.WriteString(w, opts.FilePrepender(filename)); err != nil {
+				return err
+			}
+		}
+		return generateOnePage(cmd, opts, templateName, w)
+	}
+	if opts.PostRender != nil {
+		generate = postRendered(cmd, filename, opts, generate)
+	}
+	if err := createPage(filename, cmd.CommandPath(), opts, generate); err != nil {
+		return err
+	}
+	if !opts.AliasPages || !opts.manTemplate {
+		return nil
+	}
+	return generateAliasPages(cmd, opts, directory, filename)
+}
+
+// postRendered returns generate with its output passed through
+// Options.PostRender.
+func postRendered(cmd *cobra.Command, filename string, opts *Options, generate func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		buf := new(bytes.Buffer)
+		if err := generate(buf); err != nil {
+			return err
+		}
+		content, err := opts.PostRender(cmd, filename, buf.Bytes())
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}
+}
+
+// pageFilename returns the name of the file of the page of cmd, relative to
+// the output directory.
+func pageFilename(cmd *cobra.Command, opts *Options, templateName string) string {
+	if opts.PathFor != nil {
+		if p := opts.PathFor(cmd, templateName); p != "" {
+			return p
+		}
+	}
+	return filepath.Join(opts.sectionDir, fileName(cmd.CommandPath(), opts))
+}
+
+// createPage creates the page filename with createFile, compressed when
+// Options.Gzip is set.
+func createPage(filename string, cmdPath string, opts *Options, generate func(w io.Writer) error) error {
+	if opts.Gzip && opts.manTemplate {
+		return createFile(filename+".gz", cmdPath, opts, gzipped(generate))
+	}
+	return createFile(filename, cmdPath, opts, generate)
+}
+
+// generateAliasPages writes a page for every alias of cmd that includes
+// the page of cmd, written to page, with .so like coreutils does for
+// alternate names.
+func generateAliasPages(cmd *cobra.Command, opts *Options, directory string, page string) error {
+	target := path.Join("man"+opts.Section, filepath.Base(page))
+	stub := ".so " + target + "\n.\\\" This file " + generatedMarker + "\n"
+	parent := ""
+	if cmd.HasParent() {
+		parent = cmd.Parent().CommandPath() + " "
+	}
+	for _, alias := range append(cmd.Aliases, annotations.Aliases(cmd)...) {
+		filename := filepath.Join(directory, opts.sectionDir, fileName(parent+alias, opts))
+		err := createPage(filename, cmd.CommandPath(), opts, func(w io.Writer) error {
+			_, err := io.WriteString(w, stub)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gzipped returns generate changed to write gzip compressed output.  The
+// gzip header holds no name or time so the output is reproducible.
+func gzipped(generate func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		if err := generate(gz); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+}
+
+// findCommand returns the command below root with the space separated
+// cmdPath.  The path may start with the name of root.
+func findCommand(root *cobra.Command, cmdPath string) (*cobra.Command, error) {
+	args := strings.Fields(cmdPath)
+	if len(args) > 0 && args[0] == root.Name() {
+		args = args[1:]
+	}
+	cmd, rest, err := root.Find(args)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("%w %q for %q", ErrUnknownCommand, rest[0], cmd.CommandPath())
+	}
+	return cmd, err
+}
+
+// fileName returns the name of the file documenting the command with the
+// space separated cmdPath.
+func fileName(cmdPath string, opts *Options) string {
+	if opts.NestedDirs {
+		parts := strings.Fields(cmdPath)
+		if len(parts) > 1 {
+			parts = parts[1:]
+		}
+		if opts.SlugFunc != nil {
+			for i, part := range parts {
+				parts[i] = opts.SlugFunc(part)
+			}
+		}
+		last := len(parts) - 1
+		parts[last] = opts.FilePrefix + parts[last] + opts.FileSuffix + "." + opts.fileExtension
+		return path.Join(parts...)
+	}
+	name := strings.ReplaceAll(cmdPath, " ", opts.fileCmdSeparator)
+	if opts.SlugFunc != nil {
+		name = opts.SlugFunc(cmdPath)
+	}
+	return opts.FilePrefix + name + opts.FileSuffix + "." + opts.fileExtension
+}
+
+// createFile creates filename with Options.CreateFile, or in the file
+// system, and fills it with generate.  The file is added to the manifest
+// as generated from the command with cmdPath and reported to
+// Options.Progress.
+func createFile(filename string, cmdPath string, opts *Options, generate func(w io.Writer) error) error {
+	if err := writeFile(filename, cmdPath, opts, generate); err != nil {
+		return err
+	}
+	if opts.progress != nil {
+		defer lock(opts)()
+		opts.progress.done++
+		opts.Progress(opts.progress.done, opts.progress.total, filename)
+	}
+	return nil
+}
+
+// writeFile does the work of createFile.
+func writeFile(filename string, cmdPath string, opts *Options, generate func(w io.Writer) error) (err error) {
+	if opts.manifest != nil {
+		generate = opts.manifest.record(filename, cmdPath, generate)
+	}
+	if opts.written != nil {
+		unlock := lock(opts)
+		opts.written[filename] = true
+		unlock()
+	}
+	if opts.DryRun {
+		return dryRunFile(filename, opts, generate)
+	}
+	logger(opts).Info("writing file", "file", filename, "command", cmdPath)
+	create := opts.CreateFile
+	if create == nil {
+		if opts.ProtectFiles {
+			if err := checkGenerated(filename, opts.Fs); err != nil {
+				return err
+			}
+		}
+		if opts.WriteIfChanged {
+			return writeIfChanged(filename, opts, generate)
+		}
+		create = fsCreateFile(opts)
+	}
+	f, err := create(filename)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err = f.Close()
-	}()
+	defer func() { closeFile(f, &err) }()
+
+	return generate(f)
+}
+
+// checkGenerated returns ErrNotGenerated if filename exists in fs, or the
+// operating system file system if fs is nil, and is not a generated file.
+func checkGenerated(filename string, fs afero.Fs) error {
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	if ok, err := afero.Exists(fs, filename); err != nil || !ok {
+		return err
+	}
+	if !isGenerated(fs, filename) {
+		return fmt.Errorf("%w: %s", ErrNotGenerated, filename)
+	}
+	return nil
+}
+
+// dryRunFile renders filename with generate and reports its name and size
+// to Options.DryRunOutput instead of writing it.
+func dryRunFile(filename string, opts *Options, generate func(w io.Writer) error) error {
+	buf := new(bytes.Buffer)
+	if err := generate(buf); err != nil {
+		return err
+	}
+	defer lock(opts)()
+	_, err := fmt.Fprintf(dryRunOutput(opts), "would write %s (%d bytes)\n", filename, buf.Len())
+	return err
+}
 
-	// Generate the documentation
-	return GenerateOnePage(cmd, opts, templateName, f)
+// progress counts the files GenerateDocs writes for Options.Progress.
+type progress struct {
+	done  int
+	total int
+}
+
+// dryRunOutput returns where Options.DryRun reports the files.
+func dryRunOutput(opts *Options) io.Writer {
+	if opts.DryRunOutput == nil {
+		return os.Stdout
+	}
+	return opts.DryRunOutput
+}
+
+// writeIfChanged renders filename with generate and writes it to
+// Options.Fs only if the file does not exist or its content is different.
+func writeIfChanged(filename string, opts *Options, generate func(w io.Writer) error) (err error) {
+	buf := new(bytes.Buffer)
+	if err := generate(buf); err != nil {
+		return err
+	}
+	fs := opts.Fs
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	if old, err := afero.ReadFile(fs, filename); err == nil && bytes.Equal(old, buf.Bytes()) {
+		logger(opts).Debug("file unchanged", "file", filename)
+		return nil
+	}
+	f, err := fsCreateFile(opts)(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { closeFile(f, &err) }()
+	_, err = f.Write(buf.Bytes())
+	return err
+}
+
+// fsCreateFile returns a function creating a file, and the directories it
+// is in, in Options.Fs or in the operating system file system if it is nil.
+// They get Options.FileMode and Options.DirMode.  The file is written to a
+// temporary file renamed into place when it is closed, so an interrupted
+// run never leaves a truncated page behind.
+func fsCreateFile(opts *Options) func(filename string) (io.WriteCloser, error) {
+	fs := opts.Fs
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+	fileMode, dirMode := opts.FileMode, opts.DirMode
+	if fileMode == 0 {
+		fileMode = 0o666
+	}
+	if dirMode == 0 {
+		dirMode = 0o755
+	}
+	return func(filename string) (io.WriteCloser, error) {
+		if err := fs.MkdirAll(filepath.Dir(filename), dirMode); err != nil {
+			return nil, err
+		}
+		f, err := createTemp(fs, filename, fileMode)
+		if err != nil {
+			return nil, err
+		}
+		// The mode given to OpenFile is reduced by the umask.
+		if opts.FileMode != 0 {
+			if err := fs.Chmod(f.Name(), opts.FileMode); err != nil {
+				return nil, joinErrors(err, f.Abort())
+			}
+		}
+		return f, nil
+	}
+}
+
+// isDocumented reports whether cmd gets its own page and is referenced
+// from the pages of related commands.
+func isDocumented(cmd *cobra.Command, opts *Options) bool {
+	return skipReason(cmd, opts) == ""
+}
+
+// skipReason returns why cmd gets no page, or "" if it is documented.
+//
+//nolint:cyclop // one case per reason reads best
+func skipReason(cmd *cobra.Command, opts *Options) string {
+	switch {
+	case cmd.IsAdditionalHelpTopicCommand():
+		return "help topic"
+	case opts.Filter != nil && !opts.Filter(cmd):
+		return "filtered"
+	case cmd.IsAvailableCommand():
+		return ""
+	case cmd.Hidden && (!opts.IncludeHidden || cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd):
+		return "hidden"
+	case cmd.Deprecated != "" && !opts.IncludeDeprecated:
+		return "deprecated"
+	case (cmd.Hidden || cmd.Deprecated != "") && (cmd.Runnable() || cmd.HasAvailableSubCommands()):
+		return ""
+	default:
+		return "not available"
+	}
 }
 
 func validate(opts *Options, templateName string) {
@@ -131,22 +1177,44 @@ func validate(opts *Options, templateName string) {
 		opts.Section = "1"
 	}
 	if opts.Date == nil {
-		now := time.Now()
-		opts.Date = &now
+		opts.Date = defaultDate()
 	}
 
-	sep, ext, t := getTemplate(templateName)
-	if t == nil {
+	if _, ok := templateMap[templateName]; !ok {
 		panic("template could not be found: " + templateName)
 	}
+	sep, ext, _ := getTemplate(templateName)
 	opts.fileCmdSeparator = sep
-	opts.fileSuffix = ext
-	if ext == "use_section" {
-		opts.fileSuffix = opts.Section
+	opts.fileExtension = ext
+	opts.manTemplate = ext == "use_section"
+	opts.sectionDir = ""
+	if opts.manTemplate {
+		opts.fileExtension = opts.Section
+		switch {
+		case opts.ManPathLayout:
+			opts.sectionDir = path.Join("share", "man", "man"+opts.Section)
+		case opts.SectionDirs:
+			opts.sectionDir = "man" + opts.Section
+		}
 	}
+	if opts.FileExtension != "" {
+		opts.fileExtension = strings.TrimPrefix(opts.FileExtension, ".")
+	}
+}
+
+// defaultDate returns the date of the pages when Options.Date is not set,
+// the time in SOURCE_DATE_EPOCH for reproducible builds or else now.
+func defaultDate() *time.Time {
+	date := time.Now()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		date = time.Unix(epoch, 0).UTC()
+	}
+	return &date
 }
 
-type manStruct struct {
+// DocContext is the data the templates use to document a command, which
+// Options.PreRender can change before the page is rendered.
+type DocContext struct {
 	Date             *time.Time
 	Section          string
 	CenterFooter     string
@@ -155,77 +1223,273 @@ type manStruct struct {
 	UseLine          string
 	CommandPath      string
 	ShortDescription string
+	NameDescription  string
 	Description      string
+	Deprecated       string
+	Aliases          []string
+	Warning          string
 	NoArgs           bool
 
-	AllFlags          []manFlag
-	InheritedFlags    []manFlag
-	NonInheritedFlags []manFlag
-	SeeAlsos          []seeAlso
+	AllFlags          []DocFlag
+	InheritedFlags    []DocFlag
+	NonInheritedFlags []DocFlag
+	DeprecatedFlags   []DocFlag
+	FlagGroups        []FlagGroup
+	FlagTable         bool
+	MarkdownDialect   string
+	ParseMarkdown     bool
+	Hyphenate         bool
+	Justify           bool
+	SynopsisFlags     []SynopsisItem
+	SynopsisForms     []string
+	SeeAlsos          []SeeAlsoEntry
 	SubCommands       []*cobra.Command
 
 	Author      string
 	Environment string
+	EnvVars     []EnvVar
 	Files       string
+	ConfigFiles []string
+	Diagnostics string
 	Bugs        string
 	Examples    string
 
+	StructuredExamples []Example
+	ExampleLanguage    string
+
+	CustomSections      []CustomSection
+	CustomSectionsAfter string
+
 	CobraCmd *cobra.Command
 
 	CustomData map[string]interface{}
+
+	PageHeader string
+	SingleFile bool
+	Anchor     string
+
+	opts          *Options
+	headingOffset int
+}
+
+// Link returns the link to the page of the command with the space separated
+// cmdPath for use in templates.  When all commands are documented in a single
+// file it links to the heading of the command instead.
+func (m DocContext) Link(cmdPath string) string {
+	if m.SingleFile {
+		return "#" + commandAnchor(cmdPath, m.opts)
+	}
+	return link(cmdPath, m.CommandPath, m.opts)
+}
+
+// Admonition renders a markdown notice with the given title and text in the
+// style set with Options.AdmonitionStyle.
+func (m DocContext) Admonition(title string, text string) string {
+	switch m.opts.AdmonitionStyle {
+	case AdmonitionGFM:
+		if m.MarkdownDialect == DialectCommonMark {
+			return "> **" + title + ":** " + strings.ReplaceAll(text, "\n", "\n> ")
+		}
+		return "> [!WARNING]\n> **" + title + ":** " + strings.ReplaceAll(text, "\n", "\n> ")
+	case AdmonitionDocusaurus:
+		return ":::warning[" + title + "]\n\n" + text + "\n\n:::"
+	default:
+		return "**" + title + ":** " + text
+	}
+}
+
+// ToTroff converts str for a troff page, rendering it as markdown with
+// Options.ParseMarkdown.
+func (m DocContext) ToTroff(str string) string {
+	if m.opts.ParseMarkdown {
+		return markdownToTroff(str)
+	}
+	return simpleToTroff(str)
+}
+
+// ToMdoc converts str for an mdoc page, rendering it as markdown with
+// Options.ParseMarkdown.
+func (m DocContext) ToMdoc(str string) string {
+	if m.opts.ParseMarkdown {
+		return markdownToMdoc(str)
+	}
+	return simpleToMdoc(str)
+}
+
+// ToMarkdown separates the paragraphs of str like simpleToMarkdown and moves
+// its headings down a level, so a "## Heading" in a description becomes a
+// subsection of the page like "### Synopsis".
+func (m DocContext) ToMarkdown(str string) string {
+	return shiftHeadings(simpleToMarkdown(str), 1+m.headingOffset)
+}
+
+// Heading returns the markdown marker for a heading of the given level,
+// moved down by Options.HeadingOffset and for commands nested in a single
+// file.
+func (m DocContext) Heading(level int) string {
+	return heading(level + m.headingOffset)
+}
+
+// heading returns the markdown marker for a heading of the given level.
+func heading(level int) string {
+	if level < 1 {
+		level = 1
+	}
+	if level > maxHeadingLevel {
+		level = maxHeadingLevel
+	}
+	return strings.Repeat("#", level)
 }
 
-type manFlag struct {
+// link returns the link to the page of cmdPath from the page of fromPath.
+func link(cmdPath string, fromPath string, opts *Options) string {
+	filename := fileName(cmdPath, opts)
+	if opts.NestedDirs {
+		if rel, err := filepath.Rel(path.Dir(fileName(fromPath, opts)), filename); err == nil {
+			filename = filepath.ToSlash(rel)
+		}
+	}
+	if opts.LinkHandler != nil {
+		return opts.LinkHandler(filename)
+	}
+	return filename
+}
+
+// DocFlag is a flag as the templates document it, in the flag lists of
+// DocContext.
+type DocFlag struct {
 	Shorthand   string
 	Name        string
 	NoOptDefVal string
 	DefValue    string
 	Usage       string
 	ArgHint     string
+	Type        string
+	Placeholder string
+	Default     string
+	Deprecated  string
+	Required    bool
+	Group       string
+	Anchor      string
+
+	OptionalValue bool
+
+	// Markdown tells that Usage is markdown, set with Options.ParseMarkdown.
+	Markdown bool
+
+	ExclusiveWith []string
+	RequiredWith  []string
 }
 
-type seeAlso struct {
-	CmdPath   string
-	Section   string
-	IsParent  bool
-	IsChild   bool
-	IsSibling bool
+// FlagGroup is a group of flags documented under its own heading, set with
+// the annotations.SetFlagGroup annotation.
+type FlagGroup struct {
+	Name  string
+	Flags []DocFlag
 }
 
-// GenerateOnePage will generate one documentation page and output the result to w
-// TODO: document use of this function in README.
-//
-//nolint:funlen,gocognit,cyclop // method is readable
+// SeeAlsoEntry is a reference of the SEE ALSO section, to a command of the
+// application or, when IsExternal or IsURL is set, another page.
+type SeeAlsoEntry struct {
+	CmdPath    string
+	Section    string
+	IsParent   bool
+	IsChild    bool
+	IsSibling  bool
+	IsExternal bool
+	IsURL      bool
+}
+
+// GenerateOnePage will generate one documentation page and output the result to w,
+// like standard output for a quick preview.
 func GenerateOnePage(cmd *cobra.Command, opts *Options, templateName string, w io.Writer) error {
 	// Set defaults - these would already be set unless GenerateOnePage called directly
 	validate(opts, templateName)
+	if err := loadTemplates(opts, templateName); err != nil {
+		return err
+	}
+	setProvenance(cmd, opts, templateName)
+	return generateOnePage(cmd, opts, templateName, w)
+}
+
+// GenerateOnePageContext is GenerateOnePage with a context, passed to the
+// Render method of a Generator.
+func GenerateOnePageContext(ctx context.Context, cmd *cobra.Command, opts *Options, templateName string, w io.Writer) error {
+	opts.ctx = ctx
+	defer func() { opts.ctx = nil }()
+	return GenerateOnePage(cmd, opts, templateName, w)
+}
+
+// generateOnePage is GenerateOnePage with opts already validated, so the
+// pages can be generated concurrently.
+func generateOnePage(cmd *cobra.Command, opts *Options, templateName string, w io.Writer) error {
+	ctx := opts.renderContext()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Get template and generate the documentation page
+	t := opts.templateOf(templateName)
+	if t.generator != nil {
+		return t.generator.Render(ctx, cmd, opts, w)
+	}
+
+	values, err := genManStruct(cmd, opts)
+	if err != nil {
+		return err
+	}
+	return executeTemplate(t.template, values, t.extension, opts, w)
+}
+
+// renderContext returns the context the pages are generated with, the one
+// given to GenerateDocsContext or GenerateOnePageContext if any.
+func (opts *Options) renderContext() context.Context {
+	if opts.ctx == nil {
+		return context.Background()
+	}
+	return opts.ctx
+}
 
-	values := manStruct{}
+// genManStruct collects the data the templates use to document cmd.
+//
+//nolint:funlen,gocognit,cyclop // method is readable
+func genManStruct(cmd *cobra.Command, opts *Options) (DocContext, error) {
+	values := DocContext{opts: opts, headingOffset: opts.HeadingOffset}
+	if opts.MarkdownLint {
+		// Pages start with a level 1 heading.
+		values.headingOffset--
+	}
 
 	// Header fields
 	values.LeftFooter = opts.LeftFooter
 	values.CenterHeader = opts.CenterHeader
 	values.Section = opts.Section
 	values.Date = opts.Date
+	if opts.OmitDate {
+		values.Date = nil
+	}
 	values.CenterFooter = opts.CenterFooter
-	if opts.CenterFooter == "" {
+	if opts.CenterFooter == "" && values.Date != nil {
 		// TODO: should this be part of template instead?
 		values.CenterFooter = values.Date.Format("Jan 2006")
 	}
 
 	values.CobraCmd = cmd
 	values.ShortDescription = cmd.Short
+	values.NameDescription = cmd.Short
+	if nameDescription := cmd.Annotations[annotations.NameDescriptionKey]; nameDescription != "" {
+		values.NameDescription = nameDescription
+	}
 	values.UseLine = cmd.UseLine()
 	values.CommandPath = cmd.CommandPath()
 
-	// Use reflection to see if cobra.NoArgs was set
-	argFuncName := runtime.FuncForPC(reflect.ValueOf(cmd.Args).Pointer()).Name()
-	values.NoArgs = strings.HasSuffix(argFuncName, "cobra.NoArgs")
+	ctx := opts.commands.get(cmd)
+	values.NoArgs = ctx.noArgs
 
 	if cmd.HasSubCommands() {
 		subCmdArr := make([]*cobra.Command, 0, len(cmd.Commands()))
 		for _, c := range cmd.Commands() {
-			if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			if !isDocumented(c, opts) {
 				continue
 			}
 			subCmdArr = append(subCmdArr, c)
@@ -239,14 +1503,31 @@ func GenerateOnePage(cmd *cobra.Command, opts *Options, templateName string, w i
 		description = cmd.Short
 	}
 	values.Description = description
+	values.Deprecated = cmd.Deprecated
+	values.Aliases = cmd.Aliases
+	values.Warning = cmd.Annotations[annotations.WarningKey]
 
 	// Flag arrays
-	values.AllFlags = genFlagArray(cmd.Flags())
-	values.InheritedFlags = genFlagArray(cmd.InheritedFlags())
-	values.NonInheritedFlags = genFlagArray(cmd.NonInheritedFlags())
+	values.AllFlags = genFlagArray(cmd.Flags(), opts)
+	values.InheritedFlags = genFlagArray(cmd.InheritedFlags(), opts)
+	values.NonInheritedFlags = genFlagArray(cmd.NonInheritedFlags(), opts)
+	values.FlagGroups = genFlagGroups(values.AllFlags)
+	values.MarkdownDialect = opts.MarkdownDialect
+	if values.MarkdownDialect == "" {
+		values.MarkdownDialect = DialectGFM
+	}
+	values.FlagTable = opts.FlagTable && values.MarkdownDialect != DialectCommonMark
+	values.ParseMarkdown = opts.ParseMarkdown
+	values.Hyphenate = opts.Hyphenate
+	values.Justify = opts.Justify
+	values.SynopsisFlags = genSynopsisFlags(values.AllFlags)
+	values.SynopsisForms = ctx.synopsisForms
+	if opts.IncludeDeprecated {
+		values.DeprecatedFlags = genDeprecatedFlagArray(cmd.Flags(), opts)
+	}
 
 	// ENVIRONMENT section
-	altEnvironmentSection := cmd.Annotations["man-environment-section"]
+	altEnvironmentSection := cmd.Annotations[annotations.EnvironmentKey]
 	if opts.Environment != "" || altEnvironmentSection != "" {
 		if altEnvironmentSection != "" {
 			values.Environment = altEnvironmentSection
@@ -255,8 +1536,10 @@ func GenerateOnePage(cmd *cobra.Command, opts *Options, templateName string, w i
 		}
 	}
 
+	values.EnvVars = genEnvVars(cmd, opts.EnvVars)
+
 	// FILES section
-	altFilesSection := cmd.Annotations["man-files-section"]
+	altFilesSection := cmd.Annotations[annotations.FilesKey]
 	if opts.Files != "" || altFilesSection != "" {
 		if altFilesSection != "" {
 			values.Files = altFilesSection
@@ -265,8 +1548,20 @@ func GenerateOnePage(cmd *cobra.Command, opts *Options, templateName string, w i
 		}
 	}
 
+	values.ConfigFiles = opts.ConfigFiles
+
+	// DIAGNOSTICS section
+	altDiagnosticsSection := cmd.Annotations[annotations.DiagnosticsKey]
+	if opts.Diagnostics != "" || altDiagnosticsSection != "" {
+		if altDiagnosticsSection != "" {
+			values.Diagnostics = altDiagnosticsSection
+		} else {
+			values.Diagnostics = opts.Diagnostics
+		}
+	}
+
 	// BUGS section
-	altBugsSection := cmd.Annotations["man-bugs-section"]
+	altBugsSection := cmd.Annotations[annotations.BugsKey]
 	if opts.Bugs != "" || altBugsSection != "" {
 		if altBugsSection != "" {
 			values.Bugs = altBugsSection
@@ -276,7 +1571,7 @@ func GenerateOnePage(cmd *cobra.Command, opts *Options, templateName string, w i
 	}
 
 	// EXAMPLES section
-	altExampleSection := cmd.Annotations["man-examples-section"]
+	altExampleSection := cmd.Annotations[annotations.ExamplesKey]
 	if cmd.Example != "" || altExampleSection != "" {
 		if altExampleSection != "" {
 			values.Examples = altExampleSection
@@ -284,81 +1579,265 @@ func GenerateOnePage(cmd *cobra.Command, opts *Options, templateName string, w i
 			values.Examples = cmd.Example
 		}
 	}
+	if ctx.examplesErr != nil {
+		return values, ctx.examplesErr
+	}
+	values.StructuredExamples = ctx.examples
+	values.ExampleLanguage = opts.ExampleLanguage
+	if values.ExampleLanguage == "" {
+		values.ExampleLanguage = "shell"
+	}
+	if language := cmd.Annotations[annotations.ExampleLanguageKey]; language != "" {
+		values.ExampleLanguage = language
+	}
+
+	// Custom sections
+	values.CustomSections = ctx.customSections
+	values.CustomSectionsAfter = strings.ToUpper(opts.CustomSectionsAfter)
+	if values.CustomSectionsAfter == "" {
+		values.CustomSectionsAfter = "EXAMPLES"
+	}
 
 	// AUTHOR section
 	values.Author = opts.Author
 
 	// SEE ALSO section
-	values.SeeAlsos = generateSeeAlsos(cmd, values.Section)
+	values.SeeAlsos = generateSeeAlsos(cmd, opts, values.Section)
+	values.SeeAlsos = append(values.SeeAlsos, externalSeeAlsos(opts.SeeAlso)...)
+	if refs := cmd.Annotations[annotations.SeeAlsoKey]; refs != "" {
+		values.SeeAlsos = append(values.SeeAlsos, externalSeeAlsos(strings.Split(refs, ","))...)
+	}
 
 	// Custom Data
 	values.CustomData = opts.CustomData
 
-	// Get template and generate the documentation page
-	_, _, t := getTemplate(templateName)
+	// Page header
+	values.PageHeader = opts.PageHeader
+	if opts.PageHeaderFunc != nil {
+		values.PageHeader = opts.PageHeaderFunc(cmd)
+	}
 
-	err := t.Execute(w, values)
-	if err != nil {
-		return err
+	if opts.PreRender != nil {
+		if err := opts.PreRender(cmd, &values); err != nil {
+			return values, err
+		}
 	}
-	return nil
+	return values, nil
 }
 
-func genFlagArray(flags *pflag.FlagSet) []manFlag {
-	flagArray := make([]manFlag, 0, 15)
+// genEnvVars selects the environment variables that apply to cmd, filling in
+// missing descriptions from the usage of their flag.
+func genEnvVars(cmd *cobra.Command, envVars []EnvVar) []EnvVar {
+	vars := make([]EnvVar, 0, len(envVars))
+	for _, env := range envVars {
+		if env.Flag != "" {
+			flag := cmd.Flags().Lookup(env.Flag)
+			if flag == nil {
+				continue
+			}
+			if env.Description == "" {
+				env.Description = flag.Usage
+			}
+		}
+		vars = append(vars, env)
+	}
+
+	return vars
+}
+
+func genFlagArray(flags *pflag.FlagSet, opts *Options) []DocFlag {
+	flagArray := make([]DocFlag, 0, 15)
 	flags.VisitAll(
 		func(flag *pflag.Flag) {
-			if len(flag.Deprecated) > 0 || flag.Hidden {
+			if len(flag.Deprecated) > 0 || (flag.Hidden && !opts.IncludeHidden) {
 				return
 			}
-			thisFlag := manFlag{
-				Name:        flag.Name,
-				NoOptDefVal: flag.NoOptDefVal,
-				DefValue:    flag.DefValue,
-				Usage:       flag.Usage,
-			}
-			if flag.ShorthandDeprecated == "" {
-				thisFlag.Shorthand = flag.Shorthand
+			flagArray = append(flagArray, newManFlag(flag, opts))
+		},
+	)
+
+	return flagArray
+}
+
+// Annotations set on flags by cobra's MarkFlagsMutuallyExclusive and
+// MarkFlagsRequiredTogether.  Each entry is a space separated list of the
+// flag names in one group.
+const (
+	mutuallyExclusiveAnnotation = "cobra_annotation_mutually_exclusive"
+	requiredTogetherAnnotation  = "cobra_annotation_required_if_others_set"
+)
+
+// flagGroupPeers returns the names of the other flags that share a flag
+// group of the given kind with flag.
+func flagGroupPeers(flag *pflag.Flag, annotation string) []string {
+	var peers []string
+	for _, group := range flag.Annotations[annotation] {
+		for _, name := range strings.Fields(group) {
+			if name == flag.Name || contains(peers, name) {
+				continue
 			}
-			hintArr, exists := flag.Annotations["man-arg-hints"]
-			if exists && len(hintArr) > 0 {
-				thisFlag.ArgHint = hintArr[0]
+			peers = append(peers, name)
+		}
+	}
+
+	return peers
+}
+
+// isZeroDefault reports whether a flag default is the zero value of its
+// type and so not worth documenting.  This follows what pflag does for
+// its own usage output.
+func isZeroDefault(value string) bool {
+	switch value {
+	case "", "false", "<nil>", "0", "0s", "[]", "map[]":
+		return true
+	default:
+		return false
+	}
+}
+
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// genFlagGroups splits flags by their "man-flag-group" annotation.  Flags
+// without a group come first in a group with an empty name, the named groups
+// follow in the order they are first seen.
+func genFlagGroups(flags []DocFlag) []FlagGroup {
+	groups := []FlagGroup{{}}
+	index := map[string]int{"": 0}
+	for _, flag := range flags {
+		i, exists := index[flag.Group]
+		if !exists {
+			i = len(groups)
+			index[flag.Group] = i
+			groups = append(groups, FlagGroup{Name: flag.Group})
+		}
+		groups[i].Flags = append(groups[i].Flags, flag)
+	}
+	if len(groups[0].Flags) == 0 {
+		groups = groups[1:]
+	}
+
+	return groups
+}
+
+func genDeprecatedFlagArray(flags *pflag.FlagSet, opts *Options) []DocFlag {
+	flagArray := make([]DocFlag, 0)
+	flags.VisitAll(
+		func(flag *pflag.Flag) {
+			// MarkDeprecated also hides the flag so Hidden is ignored here
+			if len(flag.Deprecated) == 0 {
+				return
 			}
-			flagArray = append(flagArray, thisFlag)
+			flagArray = append(flagArray, newManFlag(flag, opts))
 		},
 	)
 
 	return flagArray
 }
 
-func generateSeeAlsos(cmd *cobra.Command, section string) []seeAlso {
-	seealsos := make([]seeAlso, 0)
+func newManFlag(flag *pflag.Flag, opts *Options) DocFlag {
+	thisFlag := DocFlag{
+		Name:        flag.Name,
+		NoOptDefVal: flag.NoOptDefVal,
+		DefValue:    flag.DefValue,
+		Usage:       flag.Usage,
+		Deprecated:  flag.Deprecated,
+		Markdown:    opts.ParseMarkdown,
+	}
+	if flag.ShorthandDeprecated == "" {
+		thisFlag.Shorthand = flag.Shorthand
+	}
+	thisFlag.ArgHint = flagAnnotation(flag, annotations.ArgHintsKey)
+	if flag.Value != nil {
+		thisFlag.Type = flag.Value.Type()
+	}
+	thisFlag.Placeholder = thisFlag.ArgHint
+	if thisFlag.Placeholder == "" {
+		thisFlag.Placeholder = thisFlag.Type
+	}
+	// A NoOptDefVal on anything else than a bool or count flag makes the
+	// value optional rather than removing it
+	if flag.NoOptDefVal != "" && thisFlag.Type != "bool" && thisFlag.Type != "count" {
+		thisFlag.OptionalValue = true
+	}
+	thisFlag.Default = flagDefault(flag, thisFlag.OptionalValue, opts)
+	thisFlag.Group = flagAnnotation(flag, annotations.FlagGroupKey)
+	thisFlag.Required = flagAnnotation(flag, cobra.BashCompOneRequiredFlag) == "true"
+	if opts.FlagAnchors {
+		thisFlag.Anchor = flagAnchor(flag.Name, opts)
+	}
+	thisFlag.ExclusiveWith = flagGroupPeers(flag, mutuallyExclusiveAnnotation)
+	thisFlag.RequiredWith = flagGroupPeers(flag, requiredTogetherAnnotation)
+
+	return thisFlag
+}
+
+// flagAnnotation returns the first value of the annotation of flag, or ""
+// if it has none.
+func flagAnnotation(flag *pflag.Flag, annotation string) string {
+	if values := flag.Annotations[annotation]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// flagDefault returns the default value shown for flag, whose value is
+// optional if optionalValue is set.
+func flagDefault(flag *pflag.Flag, optionalValue bool, opts *Options) string {
+	switch {
+	case !opts.OmitZeroDefaults:
+		if flag.NoOptDefVal == "" || optionalValue {
+			return flag.DefValue
+		}
+	case !isZeroDefault(flag.DefValue):
+		return flag.DefValue
+	}
+	return ""
+}
+
+// flagAnchor returns the anchor of the flag name for Options.FlagAnchors.
+func flagAnchor(name string, opts *Options) string {
+	switch {
+	case opts.FlagSlug != nil:
+		return opts.FlagSlug(name)
+	case opts.SlugFunc != nil:
+		return "flag-" + opts.SlugFunc(name)
+	default:
+		return "flag-" + name
+	}
+}
+
+func generateSeeAlsos(cmd *cobra.Command, opts *Options, section string) []SeeAlsoEntry {
+	seealsos := make([]SeeAlsoEntry, 0)
 	if cmd.HasParent() {
-		see := seeAlso{
+		see := SeeAlsoEntry{
 			CmdPath:  cmd.Parent().CommandPath(),
 			Section:  section,
 			IsParent: true,
 		}
 		seealsos = append(seealsos, see)
-		siblings := cmd.Parent().Commands()
-		for _, c := range siblings {
-			if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() || c.Name() == cmd.Name() {
-				continue
-			}
-			see := seeAlso{
-				CmdPath:   c.CommandPath(),
-				Section:   section,
-				IsSibling: true,
-			}
-			seealsos = append(seealsos, see)
-		}
+	}
+	if opts.SeeAlsoParentOnly {
+		return seealsos
+	}
+	if cmd.HasParent() && !opts.SeeAlsoExcludeSiblings {
+		seealsos = append(seealsos, siblingSeeAlsos(cmd, opts, section)...)
+	}
+	if opts.SeeAlsoExcludeChildren {
+		return seealsos
 	}
 	children := cmd.Commands()
 	for _, c := range children {
-		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+		if !isDocumented(c, opts) {
 			continue
 		}
-		see := seeAlso{
+		see := SeeAlsoEntry{
 			CmdPath: c.CommandPath(),
 			Section: section,
 			IsChild: true,
@@ -368,3 +1847,67 @@ func generateSeeAlsos(cmd *cobra.Command, section string) []seeAlso {
 
 	return seealsos
 }
+
+// siblingSeeAlsos returns the SEE ALSO entries of the documented siblings
+// of cmd.
+func siblingSeeAlsos(cmd *cobra.Command, opts *Options, section string) []SeeAlsoEntry {
+	var seealsos []SeeAlsoEntry
+	for _, c := range cmd.Parent().Commands() {
+		if !isDocumented(c, opts) || c.Name() == cmd.Name() {
+			continue
+		}
+		see := SeeAlsoEntry{
+			CmdPath:   c.CommandPath(),
+			Section:   section,
+			IsSibling: true,
+		}
+		seealsos = append(seealsos, see)
+	}
+	return seealsos
+}
+
+// CustomSection is a section added with a "man-section-<NAME>" annotation.
+type CustomSection struct {
+	Name    string
+	Content string
+}
+
+// genCustomSections returns the sections added with "man-section-<NAME>"
+// annotations sorted by name.  Underscores in the name are turned into
+// spaces so "man-section-EXIT_STATUS" gives an EXIT STATUS section.
+func genCustomSections(cmd *cobra.Command) []CustomSection {
+	sections := make([]CustomSection, 0)
+	for key, content := range cmd.Annotations {
+		name := strings.TrimPrefix(key, annotations.SectionPrefix)
+		if name == key || name == "" || content == "" {
+			continue
+		}
+		sections = append(sections, CustomSection{Name: strings.ReplaceAll(name, "_", " "), Content: content})
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].Name < sections[j].Name
+	})
+	return sections
+}
+
+var manRefRegex = regexp.MustCompile(`^(.+)\((\w+)\)$`)
+
+// externalSeeAlsos turns references like "crontab(5)" into SeeAlsoEntry values.
+// References without a section are kept as is with an empty Section.
+func externalSeeAlsos(refs []string) []SeeAlsoEntry {
+	seealsos := make([]SeeAlsoEntry, 0, len(refs))
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		see := SeeAlsoEntry{CmdPath: ref, IsExternal: true, IsURL: urlRegex.FindString(ref) == ref}
+		if m := manRefRegex.FindStringSubmatch(ref); m != nil {
+			see.CmdPath = strings.TrimSpace(m[1])
+			see.Section = m[2]
+		}
+		seealsos = append(seealsos, see)
+	}
+
+	return seealsos
+}
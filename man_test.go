@@ -15,10 +15,23 @@ package cobraman
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
@@ -71,13 +84,39 @@ func TestGenerateManPages(t *testing.T) {
 
 }
 
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1514937600")
+	cmd := &cobra.Command{Use: "foo", Long: "A long description."}
+	cmd.Flags().String("zeta", "", "last flag")
+	cmd.Flags().String("alpha", "", "first flag")
+	cmd.Annotations = map[string]string{"man-section-NOTES": "notes", "man-section-CAVEATS": "caveats"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	opts := Options{}
+	validate(&opts, "troff")
+	assert.Equal(t, time.Date(2018, 1, 3, 0, 0, 0, 0, time.UTC), *opts.Date)
+
+	for _, templateName := range []string{"troff", "mdoc", "markdown"} {
+		first, err := RenderDocs(cmd, &Options{}, templateName)
+		assert.NoError(t, err)
+		second, err := RenderDocs(cmd, &Options{}, templateName)
+		assert.NoError(t, err)
+		assert.Equal(t, first, second, templateName)
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "soon")
+	opts = Options{}
+	validate(&opts, "troff")
+	assert.WithinDuration(t, time.Now(), *opts.Date, time.Minute)
+}
+
 func TestSetCobraManOptDefaults(t *testing.T) {
 	opts := Options{}
 
 	validate(&opts, "troff")
 	assert.Equal(t, opts.Section, "1")
 	assert.Equal(t, opts.fileCmdSeparator, "-")
-	assert.Equal(t, opts.fileSuffix, "1")
+	assert.Equal(t, opts.fileExtension, "1")
 
 	delta := time.Now().Sub(*opts.Date)
 	if delta.Seconds() >= 1 {
@@ -88,7 +127,7 @@ func TestSetCobraManOptDefaults(t *testing.T) {
 	validate(&opts, "markdown")
 	assert.Equal(t, opts.Section, "1")
 	assert.Equal(t, opts.fileCmdSeparator, "_")
-	assert.Equal(t, opts.fileSuffix, "md")
+	assert.Equal(t, opts.fileExtension, "md")
 
 	opts = Options{}
 	assert.Panics(t, func() { validate(&opts, "no exist") }, "should have paniced")
@@ -218,6 +257,23 @@ func TestGenerateManPageAltSections(t *testing.T) {
 	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
 	assert.Regexp(t, ".SH FILES\n.PP\nOverride at cmd", buf.String())
 
+	// DIAGNOSTICS
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.NotRegexp(t, ".SH DIAGNOSTICS\n", buf.String()) // No DIAGNOSTICS section if not in opts
+
+	opts = Options{Diagnostics: "Exits 1 on error"}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH DIAGNOSTICS\n.PP\nExits 1 on error\n", buf.String())
+
+	annotations = make(map[string]string)
+	annotations["man-diagnostics-section"] = "Override at cmd level"
+	cmd.Annotations = annotations
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH DIAGNOSTICS\n.PP\nOverride at cmd", buf.String())
+
 	// BUGS
 	buf.Reset()
 	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
@@ -261,6 +317,15 @@ func TestGenerateManPageAltSections(t *testing.T) {
 	buf.Reset()
 	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
 	assert.Regexp(t, ".SH AUTHOR\nWritten by Ray Johnson\n.PP\n.SM Page auto-generated", buf.String()) // No OPTIONS section if not in opts
+
+	opts = Options{Author: "Ray Johnson <ray.johnson@gmail.com>"}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH AUTHOR\nRay Johnson\n.MT ray.johnson@gmail.com\n.ME\n.PP\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh AUTHOR\nRay Johnson\n.Mt ray.johnson@gmail.com\n", buf.String())
 }
 
 func TestBiggerExample(t *testing.T) {
@@ -294,3 +359,1288 @@ func TestBiggerExample(t *testing.T) {
 	checkFileNotExist(t, "bob-hidden.1")
 
 }
+
+func TestSubCommandsSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	opts := Options{}
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.NotRegexp(t, ".SH COMMANDS\n", buf.String()) // No COMMANDS section without sub-commands
+
+	cmd2 := &cobra.Command{Use: "cat", Short: "meow", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd3 := &cobra.Command{Use: "dog", Short: "woof", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2, cmd3)
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH COMMANDS\n.TP\n.fBfoo\\\\-cat.fP\\(1\\)\nmeow\n.TP\n.fBfoo\\\\-dog.fP\\(1\\)\nwoof\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh COMMANDS\n.Bl -tag -width Ds\n.It Xr foo\\\\-cat 1\nmeow\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "### Commands\n\n\\* \\[foo cat\\]\\(foo_cat.md\\) - meow\n", buf.String())
+}
+
+func TestExternalSeeAlso(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2)
+	opts := Options{SeeAlso: []string{"crontab(5)", "systemd.service(5)"}}
+	cmd2.Annotations = map[string]string{"man-see-also": "ls(1), other"}
+
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "troff", buf))
+	assert.Regexp(t, ".SH SEE ALSO\n.BR foo \\(1\\)\n.BR crontab \\(5\\)\n.BR systemd.service \\(5\\)\n.BR ls \\(1\\)\n.BR other\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* \\[foo\\]\\(foo.md\\)\n\\* crontab\\(5\\)\n\\* systemd.service\\(5\\)\n\\* ls\\(1\\)\n\\* other\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Xr foo bar 1 ,\n.Xr crontab 5 ,\n.Xr systemd.service 5\n", buf.String())
+}
+
+func TestEscapeFlagValues(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.Flags().String("name", "\"quoted\"", "first line\n.so /etc/passwd")
+
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "troff", buf))
+	assert.Regexp(t, `first line\n\\&\.so /etc/passwd \(default: \\\(dqquoted\\\(dq\)`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "mdoc", buf))
+	assert.NotRegexp(t, "\n\\.so", buf.String())
+}
+
+func TestUTF8(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Short: "Copy files — fast"}
+	opts := Options{Author: "Slavoj Žižek"}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, `\nCopy files \\\(em fast\n`, buf.String())
+	assert.Regexp(t, `\nSlavoj \\\[u017D\]i\\\[u017E\]ek\n`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "Slavoj Žižek", buf.String())
+
+	opts.UTF8 = true
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, "\nSlavoj Žižek\n", buf.String())
+}
+
+func TestURLSeeAlso(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	opts := Options{SeeAlso: []string{"https://example.com/docs"}, Bugs: "File bugs at https://example.com/bugs."}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH SEE ALSO\n.UR https://example.com/docs\n.UE\n", buf.String())
+	assert.Regexp(t, ".SH BUGS\n.PP\nFile bugs at\n.UR https://example.com/bugs\n.UE .\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh SEE ALSO\n.Lk https://example.com/docs\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* <https://example.com/docs>\n", buf.String())
+}
+
+func TestSeeAlsoContentControl(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd3 := &cobra.Command{Use: "baz", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd4 := &cobra.Command{Use: "kid", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2, cmd3)
+	cmd2.AddCommand(cmd4)
+
+	paths := func(opts *Options) []string {
+		p := make([]string, 0)
+		for _, s := range generateSeeAlsos(cmd2, opts, "1") {
+			p = append(p, s.CmdPath)
+		}
+		return p
+	}
+
+	assert.Equal(t, []string{"foo", "foo baz", "foo bar kid"}, paths(&Options{}))
+	assert.Equal(t, []string{"foo", "foo bar kid"}, paths(&Options{SeeAlsoExcludeSiblings: true}))
+	assert.Equal(t, []string{"foo", "foo baz"}, paths(&Options{SeeAlsoExcludeChildren: true}))
+	assert.Equal(t, []string{"foo"}, paths(&Options{SeeAlsoParentOnly: true}))
+}
+
+func TestIncludeDeprecated(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar", Deprecated: "use baz", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.Flags().String("new", "", "the new way")
+	cmd2.Flags().String("old", "", "the old way")
+	assert.NoError(t, cmd2.Flags().MarkDeprecated("old", "use --new"))
+	cmd.AddCommand(cmd2)
+
+	opts := Options{}
+	assert.Nil(t, GenerateDocs(cmd, &opts, "", "troff"))
+	checkForFile(t, "foo.1")
+	checkFileNotExist(t, "foo-bar.1")
+
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "troff", buf))
+	assert.NotRegexp(t, "DEPRECATED OPTIONS", buf.String())
+
+	opts = Options{IncludeDeprecated: true}
+	assert.Nil(t, GenerateDocs(cmd, &opts, "", "troff"))
+	checkForFile(t, "foo.1")
+	checkForFile(t, "foo-bar.1")
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "troff", buf))
+	assert.Regexp(t, ".SH DESCRIPTION\n.PP\n.fBThis command is deprecated:.fP use baz\n", buf.String())
+	assert.Regexp(t, ".SS DEPRECATED OPTIONS\n.TP\n.fB\\\\-\\\\-old.fP = <string>\nthe old way\n.br\n.fIDeprecated:.fP use \\\\-\\\\-new\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "markdown", buf))
+	assert.Regexp(t, "#### Deprecated Options\n\n\\* --old=\\\\<string\\\\> - the old way \\(deprecated: use --new\\)\n", buf.String())
+}
+
+func TestIncludeHidden(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().String("secret", "", "the secret flag")
+	assert.NoError(t, cmd.Flags().MarkHidden("secret"))
+	cmd.AddCommand(&cobra.Command{Use: "internal", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}})
+	cmd.AddCommand(&cobra.Command{Use: cobra.ShellCompRequestCmd, Hidden: true, Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{}, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo.md"}, sortedKeys(files))
+	assert.NotContains(t, string(files["foo.md"]), "secret")
+
+	files, err = RenderDocs(cmd, &Options{IncludeHidden: true}, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo.md", "foo_internal.md"}, sortedKeys(files))
+	assert.Contains(t, string(files["foo.md"]), "the secret flag")
+	assert.Contains(t, string(files["foo.md"]), "foo_internal.md")
+}
+
+func TestRequiredFlags(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().String("name", "", "the name")
+	cmd.Flags().String("other", "", "optional")
+	assert.NoError(t, cmd.MarkFlagRequired("name"))
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, "SH SYNOPSIS\n.sp\n.+foo .fR.fI\\\\-\\\\-name.fP \\[.fI\\\\-\\\\-other.fP\\]", buf.String())
+	assert.Regexp(t, ".fB\\\\-\\\\-name.fP = <string>\nthe name \\(required\\)\n", buf.String())
+	assert.NotRegexp(t, "optional \\(required\\)", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Nm foo\n.Fl \\\\-name\n.Op Fl \\\\-other\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* --name=\\\\<string\\\\> - the name \\(required\\)\n", buf.String())
+}
+
+func TestFlagGroups(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().String("output", "", "output format")
+	cmd.Flags().String("host", "", "host to connect to")
+	cmd.Flags().Bool("verbose", false, "be loud")
+	assert.NoError(t, cmd.Flags().SetAnnotation("output", "man-flag-group", []string{"Output options"}))
+	assert.NoError(t, cmd.Flags().SetAnnotation("host", "man-flag-group", []string{"Connection options"}))
+
+	groups := genFlagGroups(genFlagArray(cmd.Flags(), &Options{}))
+	assert.Len(t, groups, 3)
+	assert.Equal(t, "", groups[0].Name)
+	assert.Equal(t, "verbose", groups[0].Flags[0].Name)
+	assert.Equal(t, "Connection options", groups[1].Name)
+	assert.Equal(t, "Output options", groups[2].Name)
+
+	opts := Options{}
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH OPTIONS\n.TP\n.+verbose.+\nbe loud\n.SS Connection options\n.TP\n.+host.+\nhost to connect to\n.SS Output options\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "#### Connection options\n\n\\* --host=\\\\<string\\\\> - host to connect to\n", buf.String())
+
+	cmd = &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().String("output", "", "output format")
+	assert.NoError(t, cmd.Flags().SetAnnotation("output", "man-flag-group", []string{"Output options"}))
+	groups = genFlagGroups(genFlagArray(cmd.Flags(), &Options{}))
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "Output options", groups[0].Name)
+}
+
+func TestFlagRelationships(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().Bool("json", false, "json output")
+	cmd.Flags().Bool("yaml", false, "yaml output")
+	cmd.Flags().String("user", "", "user name")
+	cmd.Flags().String("pass", "", "password")
+	cmd.MarkFlagsMutuallyExclusive("json", "yaml")
+	cmd.MarkFlagsRequiredTogether("user", "pass")
+
+	flags := genFlagArray(cmd.Flags(), &Options{})
+	assert.Equal(t, []string{"yaml"}, flags[0].ExclusiveWith)
+	assert.Equal(t, []string{"user"}, flags[1].RequiredWith)
+
+	items := genSynopsisFlags(flags)
+	assert.Len(t, items, 3)
+	assert.Len(t, items[0].Flags, 2)
+
+	opts := Options{}
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, "\\[.fI\\\\-\\\\-json.fP \\| .fI\\\\-\\\\-yaml.fP\\] \\[.fI\\\\-\\\\-pass.fP\\]", buf.String())
+	assert.Regexp(t, "json output \\(mutually exclusive with \\\\-\\\\-yaml\\)\n", buf.String())
+	assert.Regexp(t, "password \\(must be used together with \\\\-\\\\-user\\)\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Op Fl \\\\-json \\| Fl \\\\-yaml\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* --yaml - yaml output \\(mutually exclusive with --json\\)\n", buf.String())
+}
+
+func TestFlagTypePlaceholders(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().Duration("timeout", 0, "how long to wait")
+	cmd.Flags().String("output", "json", "output format")
+	cmd.Flags().StringSlice("tag", nil, "tags to add")
+	cmd.Flags().String("file", "", "input file")
+	assert.NoError(t, cmd.Flags().SetAnnotation("file", "man-arg-hints", []string{"path"}))
+
+	flags := genFlagArray(cmd.Flags(), &Options{})
+	assert.Equal(t, "path", flags[0].Placeholder)
+	assert.Equal(t, "string", flags[1].Placeholder)
+	assert.Equal(t, "json", flags[1].Default)
+	assert.Equal(t, "stringSlice", flags[2].Type)
+	assert.Equal(t, "duration", flags[3].Type)
+
+	opts := Options{}
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".fB\\\\-\\\\-output.fP = <string>\noutput format \\(default: json\\)\n", buf.String())
+	assert.Regexp(t, ".fB\\\\-\\\\-file.fP = <path>\ninput file\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* --timeout=\\\\<duration\\\\> - how long to wait \\(default: 0s\\)\n", buf.String())
+	assert.Regexp(t, "\\* --tag=\\\\<stringSlice\\\\> - tags to add \\(default: \\\\\\[\\\\\\]\\)\n", buf.String())
+}
+
+func TestOmitZeroDefaults(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().Bool("color", true, "use colors")
+	cmd.Flags().Bool("force", false, "force it")
+	cmd.Flags().String("name", "", "the name")
+	cmd.Flags().String("output", "json", "output format")
+
+	flags := genFlagArray(cmd.Flags(), &Options{})
+	assert.Equal(t, []string{"", "", "", "json"}, []string{flags[0].Default, flags[1].Default, flags[2].Default, flags[3].Default})
+
+	opts := Options{OmitZeroDefaults: true}
+	flags = genFlagArray(cmd.Flags(), &opts)
+	assert.Equal(t, []string{"true", "", "", "json"}, []string{flags[0].Default, flags[1].Default, flags[2].Default, flags[3].Default})
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, "use colors \\(default: true\\)\n", buf.String())
+	assert.Regexp(t, "force it\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* --name=\\\\<string\\\\> - the name\n", buf.String())
+	assert.Regexp(t, "\\* --output=\\\\<string\\\\> - output format \\(default: json\\)\n", buf.String())
+}
+
+func TestOptionalFlagValues(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().String("color", "auto", "colorize output")
+	cmd.Flags().Lookup("color").NoOptDefVal = "always"
+	assert.NoError(t, cmd.Flags().SetAnnotation("color", "man-arg-hints", []string{"when"}))
+	cmd.Flags().Bool("force", false, "force it")
+	cmd.Flags().CountP("verbose", "v", "more output")
+
+	flags := genFlagArray(cmd.Flags(), &Options{})
+	assert.True(t, flags[0].OptionalValue)
+	assert.False(t, flags[1].OptionalValue)
+	assert.False(t, flags[2].OptionalValue)
+
+	opts := Options{}
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, "\\[.fI\\\\-\\\\-color.fP\\[=<when>\\]\\]", buf.String())
+	assert.Regexp(t, ".TP\n.fB\\\\-\\\\-color.fP, .fB\\\\-\\\\-color.fP=<when>\ncolorize output \\(default: auto\\) \\(without a value: always\\)\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\* --color, --color=\\\\<when\\\\> - colorize output \\(default: auto\\) \\(without a value: always\\)\n", buf.String())
+	assert.Regexp(t, "\\* --force - force it\n", buf.String())
+}
+
+func TestEnvVarsSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.Flags().String("config", "", "config file to use")
+	opts := Options{EnvVars: []EnvVar{
+		{Name: "FOO_CONFIG", Flag: "config"},
+		{Name: "FOO_OTHER", Flag: "other"},
+		{Name: "FOO_DEBUG", Description: "turns on debugging"},
+	}}
+
+	envVars := genEnvVars(cmd, opts.EnvVars)
+	assert.Len(t, envVars, 2)
+	assert.Equal(t, "config file to use", envVars[0].Description)
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "### Environment\n\n\\* FOO\\\\_CONFIG - config file to use \\(same as --config\\)\n\\* FOO\\\\_DEBUG - turns on debugging\n", buf.String())
+
+	buf.Reset()
+	opts.Environment = "Some text"
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh ENVIRONMENT\nSome text\n.Bl -tag -width Ds\n.It Ev FOO\\\\_CONFIG\n", buf.String())
+}
+
+func TestConfigFilesSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	opts := Options{ConfigFiles: []string{"./.foo.yaml", "/etc/foo/config.yaml"}}
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH FILES\n.PP\nConfiguration is read from the first of these files that exists:\n.IP\n.fI.&./.foo.yaml.fP\n.IP\n.fI/etc/foo/config.yaml.fP\n", buf.String())
+
+	buf.Reset()
+	opts.Files = "Other files"
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "### Files\n\nOther files\n\nConfiguration is read from the first of these files that exists:\n\n\\* ./.foo.yaml\n\\* /etc/foo/config.yaml\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Bl -tag -width Ds\n.It Pa .&./.foo.yaml\n.It Pa /etc/foo/config.yaml\n.El\n", buf.String())
+}
+
+func TestNameDescription(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar", Short: "Going to do all sorts of things to your files"}
+	cmd.Annotations = map[string]string{"man-name-description": "change files"}
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH NAME\nbar - change files\n", buf.String())
+	assert.Regexp(t, ".SH DESCRIPTION\n.PP\nGoing to do all sorts", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Nd change files\n", buf.String())
+}
+
+func TestCustomSections(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar"}
+	cmd.Annotations = map[string]string{
+		"man-section-EXIT_STATUS": "Exits 0 on success",
+		"man-section-CAVEATS":     "Not thread safe",
+	}
+	opts := Options{Bugs: "Some bugs"}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH BUGS\n.PP\nSome bugs\n.SH CAVEATS\n.PP\nNot thread safe\n.SH EXIT STATUS\n.PP\nExits 0 on success\n.SH AUTHOR", buf.String())
+
+	opts.CustomSectionsAfter = "options"
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".SH CAVEATS\n.PP\nNot thread safe\n.SH EXIT STATUS\n.PP\nExits 0 on success\n.SH BUGS", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh CAVEATS\nNot thread safe\n.Sh EXIT STATUS\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "### CAVEATS\n\nNot thread safe\n\n### EXIT STATUS\n\nExits 0 on success\n\n### Bugs", buf.String())
+}
+
+func TestMarkdownFlagTable(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar"}
+	cmd.Flags().StringP("output", "o", "json", "output format, json | yaml")
+	cmd.Flags().Bool("force", false, "force it")
+	opts := Options{FlagTable: true}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "\\| Flag \\| Shorthand \\| Type \\| Default \\| Description \\|\n\\| --- \\| --- \\| --- \\| --- \\| --- \\|\n", buf.String())
+	assert.Contains(t, buf.String(), "| `--force` | | | | force it |\n")
+	assert.Contains(t, buf.String(), "| `--output` | `-o` | string | json | output format, json \\| yaml |\n")
+}
+
+func TestFlagAnchors(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar"}
+	cmd.Flags().StringP("output", "o", "json", "output format")
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.NotContains(t, buf.String(), "<a id=")
+
+	opts = Options{FlagAnchors: true}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "* <a id=\"flag-output\"></a>-o, --output=\\<string\\>")
+
+	opts = Options{FlagAnchors: true, FlagTable: true, FlagSlug: func(name string) string { return "bar-" + name }}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "| <a id=\"bar-output\"></a>`--output` |")
+}
+
+func TestFilePrependerAndLinkHandler(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar", Short: "does bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2)
+	opts := Options{
+		FilePrepender: func(filename string) string { return "---\ntitle: " + filename + "\n---\n" },
+		LinkHandler:   func(filename string) string { return "/docs/" + strings.TrimSuffix(filename, ".md") + "/" },
+	}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "", "markdown"))
+	data, err := os.ReadFile("foo.md")
+	assert.NoError(t, err)
+	assert.Regexp(t, "^---\ntitle: foo.md\n---\n## foo\n", string(data))
+	assert.Contains(t, string(data), "* [foo bar](/docs/foo_bar/) - does bar\n")
+	checkForFile(t, "foo.md")
+
+	data, err = os.ReadFile("foo_bar.md")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "* [foo](/docs/foo/)\n")
+	checkForFile(t, "foo_bar.md")
+}
+
+func TestPageHeader(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar"}
+	opts := Options{PageHeader: "![build](https://example.com/badge.svg)"}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^!\\[build\\]\\(https://example.com/badge.svg\\)\n\n## bar\n", buf.String())
+
+	opts.PageHeaderFunc = func(cmd *cobra.Command) string { return "Version 1.0 of " + cmd.Name() }
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^Version 1.0 of bar\n\n## bar\n", buf.String())
+}
+
+func TestAdmonitions(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar", Deprecated: "use baz instead"}
+	cmd.Annotations = map[string]string{"man-warning-section": "This deletes files"}
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "## bar\n\n\\*\\*Deprecated:\\*\\* use baz instead\n\n\\*\\*Warning:\\*\\* This deletes files\n", buf.String())
+
+	opts = Options{AdmonitionStyle: AdmonitionGFM}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "> [!WARNING]\n> **Deprecated:** use baz instead\n\n> [!WARNING]\n> **Warning:** This deletes files\n")
+
+	opts = Options{AdmonitionStyle: AdmonitionDocusaurus}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), ":::warning[Warning]\n\nThis deletes files\n\n:::\n")
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".PP\n\\\\fBWarning:\\\\fP This deletes files\n", buf.String())
+}
+
+func TestHeadingOffset(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar"}
+	cmd.Flags().String("output", "", "output format")
+	cmd.Flags().String("color", "", "colors")
+	_ = cmd.Flags().SetAnnotation("color", "man-flag-group", []string{"Display"})
+	opts := Options{HeadingOffset: 1}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^### bar\n", buf.String())
+	assert.Contains(t, buf.String(), "\n#### Options\n")
+	assert.Contains(t, buf.String(), "\n##### Display\n")
+
+	opts = Options{HeadingOffset: 5}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "\n###### Display\n")
+
+	opts = Options{HeadingOffset: 1}
+	buf.Reset()
+	assert.NoError(t, GenerateIndex(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^## bar\n\n### Commands\n", buf.String())
+}
+
+func TestMarkdownDialect(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "bar", Deprecated: "use baz"}
+	cmd.Flags().String("output", "json", "output format")
+	opts := Options{FlagTable: true, AdmonitionStyle: AdmonitionGFM, MarkdownDialect: DialectCommonMark}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.NotContains(t, buf.String(), "| Flag |")
+	assert.NotContains(t, buf.String(), "[!WARNING]")
+	assert.Contains(t, buf.String(), "* --output=\\<string\\> - output format (default: json)\n")
+	assert.Contains(t, buf.String(), "> **Deprecated:** use baz\n")
+
+	opts.MarkdownDialect = DialectGFM
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "| Flag |")
+	assert.Contains(t, buf.String(), "> [!WARNING]\n")
+}
+
+func TestNestedDirs(t *testing.T) {
+	dir := t.TempDir()
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "remote"}
+	cmd3 := &cobra.Command{Use: "add", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd4 := &cobra.Command{Use: "remove", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.AddCommand(cmd3, cmd4)
+	cmd.AddCommand(cmd2)
+	opts := Options{NestedDirs: true, IndexFile: "index.md"}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, dir, "markdown"))
+	checkForFile(t, dir+"/foo.md")
+	checkForFile(t, dir+"/remote.md")
+	data, err := os.ReadFile(dir + "/remote/add.md")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "* [foo remote](../remote.md)\n")
+	assert.Contains(t, string(data), "* [foo remote remove](remove.md)\n")
+
+	data, err = os.ReadFile(dir + "/index.md")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "    * [foo remote add](remote/add.md)\n")
+}
+
+func TestSlugFunc(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "Bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.Flags().String("Output", "", "output format")
+	cmd.AddCommand(cmd2)
+	slug := func(str string) string { return strings.ToLower(strings.ReplaceAll(str, " ", "-")) }
+	opts := Options{SlugFunc: slug, FlagAnchors: true}
+
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "* [foo](foo.md)\n")
+	assert.Contains(t, buf.String(), "<a id=\"flag-output\"></a>")
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "* [foo Bar](foo-bar.md)\n")
+
+	buf.Reset()
+	assert.NoError(t, GenerateSingleFile(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "  * [foo Bar](#foo-bar)\n")
+	assert.Contains(t, buf.String(), "<a id=\"foo-bar\"></a>\n\n### foo Bar\n")
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "", "markdown"))
+	checkForFile(t, "foo.md")
+	checkForFile(t, "foo-bar.md")
+}
+
+func TestMarkdownAliases(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "remove", Short: "remove things", Aliases: []string{"rm", "del"}}
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "remove things\n\n**Aliases:** `rm`, `del`\n\n### Synopsis\n")
+
+	cmd.Aliases = nil
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.NotContains(t, buf.String(), "Aliases")
+}
+
+func TestMarkdownShortDescription(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Short: "match *.go files in <dir>"}
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Contains(t, buf.String(), "\nmatch \\*.go files in \\<dir\\>\n")
+
+	buf.Reset()
+	assert.NoError(t, GenerateIndex(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# foo\n\nmatch \\\\\\*.go files in \\\\<dir\\\\>\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateSingleFile(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# foo\n\nmatch \\\\\\*.go files in \\\\<dir\\\\>\n", buf.String())
+}
+
+func TestMarkdownEscapedNames(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "my_tool", Annotations: map[string]string{"man-section-*NOTES*": "some notes"}}
+	sub := &cobra.Command{Use: "*star*", Short: "a star", Run: func(cmd *cobra.Command, args []string) {}}
+	sub.Flags().Bool("all", false, "do them all")
+	assert.NoError(t, annotations.SetFlagGroup(sub, "all", "<Common>"))
+	cmd.AddCommand(sub)
+	annotations.AddSeeAlso(cmd, "under_score(1)")
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	out := buf.String()
+	assert.Regexp(t, "^## my\\\\_tool\n", out)
+	assert.Contains(t, out, "\n### \\*NOTES\\*\n")
+	assert.Contains(t, out, "* [my\\_tool \\*star\\*](")
+	assert.Contains(t, out, "* under\\_score(1)\n")
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(sub, &opts, "markdown", buf))
+	out = buf.String()
+	assert.Regexp(t, "^## my\\\\_tool \\\\\\*star\\\\\\*\n", out)
+	assert.Contains(t, out, "\n#### \\<Common\\>\n")
+	assert.Contains(t, out, "* [my\\_tool](")
+
+	buf.Reset()
+	assert.NoError(t, GenerateIndex(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# my\\\\_tool\n", buf.String())
+	assert.Contains(t, buf.String(), "* [my\\_tool \\*star\\*](")
+
+	buf.Reset()
+	assert.NoError(t, GenerateSingleFile(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# my\\\\_tool\n", buf.String())
+	assert.Contains(t, buf.String(), "## my\\_tool \\*star\\*\n")
+}
+
+func TestOmitDate(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	opts := Options{OmitDate: true}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".TH \"FOO\" \"1\" \"\" \"\" \"\"", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "mdoc", buf))
+	assert.Regexp(t, "\n.Dd\n", buf.String())
+
+	opts = Options{OmitDate: true, CenterFooter: "Foo 1.0"}
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.Regexp(t, ".TH \"FOO\" \"1\" \"Foo 1.0\" \"\" \"\"", buf.String())
+}
+
+func TestHyphenateJustify(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "troff", buf))
+	assert.Regexp(t, "\n.nh\n.*\n.ad l\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{Hyphenate: true, Justify: true}, "troff", buf))
+	assert.Regexp(t, "\n.hy\n.*\n.ad b\n", buf.String())
+	assert.NotRegexp(t, "\n.nh\n", buf.String())
+}
+
+func TestDescriptionHeadings(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Long: "Does things.\n\n## Modes\n\nThere are two."}
+
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "troff", buf))
+	assert.Regexp(t, ".SH DESCRIPTION\n.PP\nDoes things.\n.SS Modes\n.PP\nThere are two.\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "mdoc", buf))
+	assert.Regexp(t, "\n.Ss Modes\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "markdown", buf))
+	assert.Contains(t, buf.String(), "\nDoes things.\n\n### Modes\n\nThere are two.\n")
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{HeadingOffset: 1}, "markdown", buf))
+	assert.Contains(t, buf.String(), "\n#### Modes\n")
+}
+
+// memFile is a file written to memory by TestCreateFile.
+type memFile struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (f *memFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestCreateFile(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files := map[string]*memFile{}
+	opts := Options{IndexFile: "index.md", CreateFile: func(path string) (io.WriteCloser, error) {
+		files[path] = &memFile{}
+		return files[path], nil
+	}}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "docs", "markdown"))
+	assert.Len(t, files, 3)
+	assert.Contains(t, files["docs/foo_bar.md"].String(), "## foo bar\n")
+	assert.Contains(t, files["docs/index.md"].String(), "[foo bar](foo_bar.md)")
+	assert.True(t, files["docs/foo.md"].closed)
+	_, err := os.Stat("docs")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAtomicWrites(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	opts := Options{Fs: fs}
+	assert.NoError(t, afero.WriteFile(fs, "docs/foo.1", []byte("old page"), 0o644))
+
+	failure := errors.New("interrupted")
+	err := createFile("docs/foo.1", "foo", &opts, func(w io.Writer) error {
+		io.WriteString(w, "half a pa")
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	data, _ := afero.ReadFile(fs, "docs/foo.1")
+	assert.Equal(t, "old page", string(data))
+
+	assert.NoError(t, createFile("docs/foo.1", "foo", &opts, func(w io.Writer) error {
+		_, err := io.WriteString(w, "new page")
+		return err
+	}))
+	data, _ = afero.ReadFile(fs, "docs/foo.1")
+	assert.Equal(t, "new page", string(data))
+	names, _ := afero.Glob(fs, "docs/*")
+	assert.Equal(t, []string{"docs/foo.1"}, names)
+}
+
+func TestFs(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	fs := afero.NewMemMapFs()
+	opts := Options{Fs: fs, NestedDirs: true}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "docs", "markdown"))
+	data, err := afero.ReadFile(fs, "docs/bar.md")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "## foo bar\n")
+	_, err = os.Stat("docs")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileNameOptions(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{FilePrefix: "vendor-", FileExtension: ".3"}, "troff")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "vendor-foo-bar.3")
+	assert.Contains(t, string(files["vendor-foo.3"]), `.TH "FOO" "1"`)
+
+	files, err = RenderDocs(cmd, &Options{FileSuffix: "-cli", FileExtension: "markdown"}, "markdown")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "foo_bar-cli.markdown")
+	assert.Contains(t, string(files["foo-cli.markdown"]), "(foo_bar-cli.markdown)")
+
+	files, err = RenderDocs(cmd, &Options{FilePrefix: "x-", NestedDirs: true}, "markdown")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "x-bar.md")
+}
+
+func TestSectionDirs(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{SectionDirs: true, Section: "8", ManifestFile: "manifest.json"}, "troff")
+	assert.NoError(t, err)
+	assert.Contains(t, files, filepath.Join("man8", "foo.8"))
+	assert.Contains(t, files, filepath.Join("man8", "foo-bar.8"))
+	assert.Contains(t, string(files["manifest.json"]), `"file": "man8/foo-bar.8"`)
+
+	files, err = RenderDocs(cmd, &Options{SectionDirs: true}, "markdown")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "foo_bar.md")
+}
+
+func TestManPathLayout(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{ManPathLayout: true, SectionDirs: true}, "troff")
+	assert.NoError(t, err)
+	assert.Contains(t, files, filepath.Join("share", "man", "man1", "foo-bar.1"))
+
+	files, err = RenderDocs(cmd, &Options{ManPathLayout: true, Gzip: true}, "troff")
+	assert.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(files[filepath.Join("share", "man", "man1", "foo.1.gz")]))
+	assert.NoError(t, err)
+	page, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), `.TH "FOO" "1"`)
+
+	files, err = RenderDocs(cmd, &Options{ManPathLayout: true, Gzip: true}, "markdown")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "foo.md")
+}
+
+func TestProtectFiles(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	fs := afero.NewMemMapFs()
+	opts := Options{Fs: fs, ProtectFiles: true, ManifestFile: "manifest.json", TreeDiagramFile: "tree.md"}
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+
+	assert.NoError(t, afero.WriteFile(fs, "out/foo.1", []byte(".TH FOO 1\nwritten by hand\n"), 0o644))
+	err := GenerateDocs(cmd, &opts, "out", "troff")
+	assert.ErrorIs(t, err, ErrNotGenerated)
+	assert.Contains(t, err.Error(), "out/foo.1")
+	content, _ := afero.ReadFile(fs, "out/foo.1")
+	assert.Contains(t, string(content), "written by hand")
+
+	opts.ProtectFiles = false
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+}
+
+func TestPrune(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	bar := &cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(bar)
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "out/notes.txt", []byte("keep me"), 0o644))
+	opts := Options{Fs: fs, ManifestFile: "manifest.json", Prune: true}
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs, Gzip: true}, "gz", "troff"))
+
+	bar.Use = "baz"
+	buf := new(bytes.Buffer)
+	dryRun := Options{Fs: fs, ManifestFile: "manifest.json", Prune: true, DryRun: true, DryRunOutput: buf}
+	assert.NoError(t, GenerateDocs(cmd, &dryRun, "out", "troff"))
+	assert.Contains(t, buf.String(), "would remove out/foo-bar.1\n")
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+	for name, exists := range map[string]bool{"out/foo-bar.1": false, "out/foo-baz.1": true, "out/foo.1": true, "out/notes.txt": true, "out/manifest.json": true} {
+		ok, err := afero.Exists(fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, exists, ok, name)
+	}
+	assert.Nil(t, opts.written)
+
+	// Without a manifest the comment of the templates is used.
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs, Gzip: true, Prune: true}, "gz", "troff"))
+	ok, _ := afero.Exists(fs, "gz/foo-bar.1.gz")
+	assert.False(t, ok)
+	ok, _ = afero.Exists(fs, "gz/foo-baz.1.gz")
+	assert.True(t, ok)
+}
+
+func TestPruneSharedDirectory(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	bar := &cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(bar)
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs, ManifestFile: "markdown.json"}, "out", "markdown"))
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs}, "out/sub", "troff"))
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs, ManifestFile: "markdown.json", Prune: true}, "out", "troff"))
+
+	bar.Use = "baz"
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs, Prune: true}, "out", "troff"))
+	for name, exists := range map[string]bool{
+		"out/foo-bar.1": false, "out/foo-baz.1": true, "out/foo.md": true, "out/foo_bar.md": true,
+		"out/sub/foo-bar.1": true,
+	} {
+		ok, err := afero.Exists(fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, exists, ok, name)
+	}
+}
+
+func TestFileModes(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	dir := filepath.Join(t.TempDir(), "a", "b")
+
+	assert.NoError(t, GenerateDocs(cmd, &Options{FileMode: 0o640, DirMode: 0o750}, dir, "troff"))
+	info, err := os.Stat(filepath.Join(dir, "foo.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	info, err = os.Stat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o750), info.Mode().Perm()&^0o022)
+
+	// Hyphenate changes the page so WriteIfChanged writes it.
+	assert.NoError(t, GenerateDocs(cmd, &Options{FileMode: 0o604, WriteIfChanged: true, Hyphenate: true}, dir, "troff"))
+	info, err = os.Stat(filepath.Join(dir, "foo.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o604), info.Mode().Perm())
+}
+
+func TestAliasPages(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	remove := &cobra.Command{Use: "remove", Aliases: []string{"rm"}, Run: func(cmd *cobra.Command, args []string) {}}
+	annotations.AddAliases(remove, "delete")
+	cmd.AddCommand(remove)
+
+	files, err := RenderDocs(cmd, &Options{AliasPages: true, Section: "8"}, "troff")
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+	assert.Equal(t, ".so man8/foo-remove.8\n.\\\" This file auto-generated by github.com/alecsammon/cobraman\n", string(files["foo-rm.8"]))
+	assert.Contains(t, string(files["foo-delete.8"]), ".so man8/foo-remove.8\n")
+
+	files, err = RenderDocs(cmd, &Options{AliasPages: true, ManPathLayout: true, Gzip: true}, "mdoc")
+	assert.NoError(t, err)
+	assert.Contains(t, files, filepath.Join("share", "man", "man1", "foo-rm.1.gz"))
+
+	files, err = RenderDocs(cmd, &Options{AliasPages: true}, "markdown")
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestFilter(t *testing.T) {
+	cmd := &cobra.Command{Use: "zap"}
+	internal := &cobra.Command{Use: "internal", Annotations: map[string]string{"internal": "yes"}, Run: func(cmd *cobra.Command, args []string) {}}
+	internal.AddCommand(&cobra.Command{Use: "debug", Run: func(cmd *cobra.Command, args []string) {}})
+	cmd.AddCommand(internal, &cobra.Command{Use: "fetch", Run: func(cmd *cobra.Command, args []string) {}})
+	opts := Options{IndexFile: "index.md", Filter: func(cmd *cobra.Command) bool {
+		return cmd.Annotations["internal"] == ""
+	}}
+
+	files, err := RenderDocs(cmd, &opts, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"index.md", "zap.md", "zap_fetch.md"}, sortedKeys(files))
+	assert.NotContains(t, string(files["zap.md"]), "internal")
+	assert.NotContains(t, string(files["index.md"]), "internal")
+}
+
+func TestOnlyAndMaxDepth(t *testing.T) {
+	cmd := &cobra.Command{Use: "zap"}
+	publish := &cobra.Command{Use: "publish", Run: func(cmd *cobra.Command, args []string) {}}
+	publish.AddCommand(&cobra.Command{Use: "now", Run: func(cmd *cobra.Command, args []string) {}})
+	cmd.AddCommand(publish, &cobra.Command{Use: "fetch", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{Only: "zap publish"}, "troff")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"zap-publish-now.1", "zap-publish.1"}, sortedKeys(files))
+
+	files, err = RenderDocs(cmd, &Options{MaxDepth: 2}, "troff")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"zap-fetch.1", "zap-publish.1", "zap.1"}, sortedKeys(files))
+
+	files, err = RenderDocs(cmd, &Options{Only: "publish", MaxDepth: 1, IndexFile: "index.md"}, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"index.md", "zap_publish.md"}, sortedKeys(files))
+	assert.Contains(t, string(files["index.md"]), "zap fetch")
+
+	_, err = RenderDocs(cmd, &Options{Only: "publish later"}, "troff")
+	assert.EqualError(t, err, `unknown command "later" for "zap publish"`)
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+}
+
+// sortedKeys returns the sorted names of files.
+func sortedKeys(files map[string][]byte) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestPathFor(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Aliases: []string{"b"}, Run: func(cmd *cobra.Command, args []string) {}})
+	opts := Options{AliasPages: true, PathFor: func(cmd *cobra.Command, format string) string {
+		if !cmd.HasParent() {
+			return ""
+		}
+		return path.Join(format, "cmd-"+cmd.Name()+".1")
+	}}
+
+	files, err := RenderDocs(cmd, &opts, "troff")
+	assert.NoError(t, err)
+	assert.Contains(t, files, "foo.1")
+	assert.Contains(t, files, filepath.Join("troff", "cmd-bar.1"))
+	assert.Contains(t, string(files["foo-b.1"]), ".so man1/cmd-bar.1\n")
+}
+
+func TestProgress(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+	cmd.AddCommand(&cobra.Command{Use: "hidden", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}})
+
+	var calls []string
+	opts := Options{IndexFile: "index.md", TreeDiagramFile: "tree.md", Progress: func(done int, total int, file string) {
+		calls = append(calls, fmt.Sprintf("%d/%d %s", done, total, file))
+	}}
+	_, err := RenderDocs(cmd, &opts, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1/4 tree.md", "2/4 foo_bar.md", "3/4 foo.md", "4/4 index.md"}, calls)
+
+	calls = nil
+	opts = Options{SingleFile: "all.md", IndexFile: "index.md", Progress: opts.Progress}
+	_, err = RenderDocs(cmd, &opts, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1/1 all.md"}, calls)
+}
+
+func TestJobs(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.PersistentFlags().String("config", "", "config file")
+	for i := 0; i < 10; i++ {
+		sub := &cobra.Command{Use: fmt.Sprintf("sub%d", i), Aliases: []string{fmt.Sprintf("s%d", i)}}
+		sub.PersistentFlags().Bool("all", false, "all of them")
+		for j := 0; j < 5; j++ {
+			leaf := &cobra.Command{Use: fmt.Sprintf("leaf%d", j), Args: cobra.NoArgs, Run: func(cmd *cobra.Command, args []string) {}}
+			leaf.Flags().Int("count", j, "how many")
+			annotations.SetStructuredExamples(leaf, Example{Description: "Run it", Command: "foo run"})
+			sub.AddCommand(leaf)
+		}
+		cmd.AddCommand(sub)
+	}
+
+	for _, tmpl := range []string{"troff", "markdown"} {
+		serial, err := RenderDocs(cmd, &Options{AliasPages: true, ManifestFile: "manifest.json"}, tmpl)
+		assert.NoError(t, err)
+		parallel, err := RenderDocs(cmd, &Options{AliasPages: true, ManifestFile: "manifest.json", Jobs: 4}, tmpl)
+		assert.NoError(t, err)
+		assert.Equal(t, sortedKeys(serial), sortedKeys(parallel))
+		for name, data := range serial {
+			if name != "manifest.json" {
+				assert.Equal(t, string(data), string(parallel[name]), name)
+			}
+		}
+
+		var m Manifest
+		assert.NoError(t, json.Unmarshal(parallel["manifest.json"], &m))
+		assert.Len(t, m.Files, len(parallel)-1)
+		assert.True(t, sort.SliceIsSorted(m.Files, func(i, j int) bool { return m.Files[i].File < m.Files[j].File }))
+	}
+
+	var done []int
+	opts := Options{Jobs: 3, Progress: func(n int, total int, file string) {
+		done = append(done, n)
+	}}
+	_, err := RenderDocs(cmd, &opts, "troff")
+	assert.NoError(t, err)
+	assert.Len(t, done, 61)
+	assert.True(t, sort.IntsAreSorted(done))
+
+	cmd.Commands()[3].Commands()[2].Annotations[annotations.StructuredExamplesKey] = "{"
+	_, err = RenderDocs(cmd, &Options{Jobs: 4}, "troff")
+	assert.Error(t, err)
+}
+
+func TestContinueOnError(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		cmd.AddCommand(&cobra.Command{Use: name, Run: func(cmd *cobra.Command, args []string) {}})
+	}
+	cmd.Commands()[1].Annotations = map[string]string{annotations.StructuredExamplesKey: "{"}
+	cmd.Commands()[3].Annotations = map[string]string{annotations.StructuredExamplesKey: "["}
+
+	fs := afero.NewMemMapFs()
+	err := GenerateDocs(cmd, &Options{Fs: fs}, "first", "troff")
+	assert.Error(t, err)
+	var pageErr *PageError
+	assert.False(t, errors.As(err, &pageErr))
+
+	for _, jobs := range []int{0, 3} {
+		dir := fmt.Sprintf("jobs%d", jobs)
+		opts := Options{Fs: fs, Jobs: jobs, ContinueOnError: true, IndexFile: "index.md", ManifestFile: "manifest.json", Prune: true}
+		err := GenerateDocs(cmd, &opts, dir, "markdown")
+		assert.Error(t, err)
+		assert.True(t, errors.As(err, &pageErr))
+		assert.Equal(t, "foo b", pageErr.Command)
+		lines := strings.Split(err.Error(), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.True(t, strings.HasPrefix(lines[0], "foo b: "), lines[0])
+			assert.True(t, strings.HasPrefix(lines[1], "foo d: "), lines[1])
+		}
+		for _, name := range []string{"foo.md", "foo_a.md", "foo_c.md", "index.md", "manifest.json"} {
+			ok, _ := afero.Exists(fs, filepath.Join(dir, name))
+			assert.True(t, ok, name)
+		}
+		ok, _ := afero.Exists(fs, filepath.Join(dir, "foo_b.md"))
+		assert.False(t, ok)
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	assert.NoError(t, joinErrors())
+	assert.NoError(t, joinErrors(nil, nil))
+	first := errors.New("first")
+	assert.Equal(t, first, joinErrors(nil, first))
+
+	err := joinErrors(errors.New("first"), nil, &PageError{Command: "foo bar", Err: ErrNotGenerated})
+	assert.EqualError(t, err, "first\nfoo bar: "+ErrNotGenerated.Error())
+	assert.ErrorIs(t, err, ErrNotGenerated)
+	var pageErr *PageError
+	if assert.ErrorAs(t, fmt.Errorf("troff: %w", err), &pageErr) {
+		assert.Equal(t, "foo bar", pageErr.Command)
+	}
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestDryRun(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+	buf := new(bytes.Buffer)
+	opts := Options{DryRun: true, DryRunOutput: buf, Fs: afero.NewMemMapFs()}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "markdown"))
+	assert.Regexp(t, `(?m)^would write out/foo\.md \(\d+ bytes\)$`, buf.String())
+	assert.Regexp(t, `(?m)^would write out/foo_bar\.md \(\d+ bytes\)$`, buf.String())
+	_, err := opts.Fs.Stat("out")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWriteIfChanged(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo", Long: "First"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+	opts := Options{WriteIfChanged: true, Fs: afero.NewMemMapFs()}
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range []string{"out/foo.1", "out/foo-bar.1"} {
+		assert.NoError(t, opts.Fs.Chtimes(name, old, old))
+	}
+
+	cmd.Long = "Second"
+	assert.NoError(t, GenerateDocs(cmd, &opts, "out", "troff"))
+	info, err := opts.Fs.Stat("out/foo-bar.1")
+	assert.NoError(t, err)
+	assert.Equal(t, old, info.ModTime())
+	info, err = opts.Fs.Stat("out/foo.1")
+	assert.NoError(t, err)
+	assert.NotEqual(t, old, info.ModTime())
+	content, _ := afero.ReadFile(opts.Fs, "out/foo.1")
+	assert.Contains(t, string(content), "Second")
+}
+
+func TestManifestFile(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+	opts := Options{ManifestFile: "manifest.json", IndexFile: "index.md"}
+
+	files, err := RenderDocs(cmd, &opts, "markdown")
+	assert.NoError(t, err)
+	assert.Len(t, files, 4)
+
+	var m Manifest
+	assert.NoError(t, json.Unmarshal(files["manifest.json"], &m))
+	assert.Equal(t, "markdown", m.Template)
+	assert.Len(t, m.Files, 3)
+	for _, entry := range m.Files {
+		sum := sha256.Sum256(files[entry.File])
+		assert.Equal(t, hex.EncodeToString(sum[:]), entry.SHA256, entry.File)
+	}
+	assert.Equal(t, ManifestEntry{File: "foo_bar.md", SHA256: m.Files[0].SHA256, Command: "foo bar"}, m.Files[0])
+	assert.Equal(t, "index.md", m.Files[2].File)
+	assert.Equal(t, "foo", m.Files[2].Command)
+	assert.Nil(t, opts.manifest)
+}
+
+func TestRenderDocs(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+	opts := Options{IndexFile: "index.md"}
+
+	files, err := RenderDocs(cmd, &opts, "markdown")
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.Contains(t, string(files["foo_bar.md"]), "## foo bar\n")
+	assert.Contains(t, string(files["index.md"]), "[foo bar](foo_bar.md)")
+	assert.Nil(t, opts.CreateFile)
+	_, err = os.Stat("foo.md")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = RenderDocs(&cobra.Command{}, &Options{}, "troff")
+	assert.Equal(t, ErrMissingCommandName, err)
+}
+
+func TestRenderHooks(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo", Short: "Foo it"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Short: "Bar it", Run: func(cmd *cobra.Command, args []string) {}})
+
+	var paths []string
+	opts := &Options{
+		FilePrepender: func(string) string { return "---\n" },
+		PreRender: func(cmd *cobra.Command, ctx *DocContext) error {
+			ctx.ShortDescription = strings.ToUpper(ctx.ShortDescription)
+			if cmd.Name() == "bar" {
+				flag := DocFlag{Name: "extra", Type: "bool", Usage: "added by the hook"}
+				ctx.AllFlags = append(ctx.AllFlags, flag)
+				ctx.FlagGroups = append(ctx.FlagGroups, FlagGroup{Name: "Extra", Flags: []DocFlag{flag}})
+			}
+			return nil
+		},
+		PostRender: func(cmd *cobra.Command, path string, content []byte) ([]byte, error) {
+			paths = append(paths, path)
+			return append(content, "Copyright Foo Inc.\n"...), nil
+		},
+	}
+	files, err := RenderDocs(cmd, opts, "markdown")
+	assert.NoError(t, err)
+	sort.Strings(paths)
+	assert.Equal(t, []string{"foo.md", "foo_bar.md"}, paths)
+	page := string(files["foo_bar.md"])
+	assert.True(t, strings.HasPrefix(page, "---\n"), page)
+	assert.True(t, strings.HasSuffix(page, "\nCopyright Foo Inc.\n"), page)
+	assert.Contains(t, page, "BAR IT")
+	assert.Contains(t, page, "added by the hook")
+
+	opts.Jobs = 2
+	opts.PreRender = func(cmd *cobra.Command, ctx *DocContext) error { return errors.New("no pre") }
+	_, err = RenderDocs(cmd, opts, "markdown")
+	assert.EqualError(t, err, "no pre")
+
+	opts.PreRender = nil
+	opts.PostRender = func(cmd *cobra.Command, path string, content []byte) ([]byte, error) {
+		return nil, errors.New("no post")
+	}
+	_, err = RenderDocs(cmd, opts, "markdown")
+	assert.EqualError(t, err, "no post")
+}
@@ -0,0 +1,98 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Manifest is the content of Options.ManifestFile.  It lists the files
+// written by GenerateDocs so packaging scripts can check that none is
+// missing and find the ones left over from removed commands.
+type Manifest struct {
+	// Generator marks the manifest as generated, like the comment in the
+	// pages.
+	Generator string `json:"generator"`
+
+	// Template is the name of the template used to generate the files.
+	Template string `json:"template"`
+
+	// Files are the generated files, in the order they were written, or
+	// sorted by file when Options.Jobs generates them concurrently.
+	Files []ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one generated file.
+type ManifestEntry struct {
+	// File is the path of the file relative to the directory given to
+	// GenerateDocs, with forward slashes.
+	File string `json:"file"`
+
+	// SHA256 is the hex encoded SHA-256 of the content of the file.
+	SHA256 string `json:"sha256"`
+
+	// Command is the path of the command the file was generated from,
+	// like "app sub".  Files about the whole tree, like the index, have the
+	// path of the root command.
+	Command string `json:"command"`
+}
+
+// manifest collects the entries of a Manifest while GenerateDocs writes
+// into directory.
+type manifest struct {
+	Manifest
+	directory string
+	mu        sync.Mutex
+}
+
+// record returns generate changed to add filename, generated from the
+// command with cmdPath, to m once it is written.
+func (m *manifest) record(filename string, cmdPath string, generate func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		hash := sha256.New()
+		if err := generate(io.MultiWriter(w, hash)); err != nil {
+			return err
+		}
+		file, err := filepath.Rel(m.directory, filename)
+		if err != nil {
+			file = filename
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.Files = append(m.Files, ManifestEntry{
+			File:    filepath.ToSlash(file),
+			SHA256:  hex.EncodeToString(hash.Sum(nil)),
+			Command: cmdPath,
+		})
+		return nil
+	}
+}
+
+// sort sorts the entries of m by file.
+func (m *manifest) sort() {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].File < m.Files[j].File })
+}
+
+// write writes m as indented JSON to w.
+func (m *manifest) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m.Manifest)
+}
@@ -0,0 +1,180 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMarkdownLineLength is the line length markdownlint allows by
+// default.
+const defaultMarkdownLineLength = 80
+
+var (
+	atxHeading = regexp.MustCompile(`^#{1,6}( |$)`)
+	listMarker = regexp.MustCompile(`^([*+-]|\d{1,9}[.)]) `)
+	bareURL    = regexp.MustCompile(`(^|\s)(https?://[^\s<>]*[^\s<>.,;:!?'")\]])`)
+	blockStart = regexp.MustCompile(`^(#{1,6}|[*+-]|\d{1,9}[.)]|=+|-+|_+|\*+|>.*|\|.*|` +
+		`<[/!?]?[a-zA-Z][a-zA-Z0-9-]*([\s/>].*)?|~~~.*)$|^` + "```")
+)
+
+// lintMarkdown rewrites doc so it passes the common markdownlint rules:
+// no trailing spaces or repeated blank lines, blank lines around headings,
+// lists, tables and code fences, a language on every fence, a single top
+// level heading, no bare URLs, lines wrapped at lineLength and a single
+// trailing newline.  Code blocks, tables and headings are not wrapped.
+//
+//nolint:funlen,gocognit,cyclop // one pass over the lines is easiest to follow
+func lintMarkdown(doc string, lineLength int) string {
+	if lineLength <= 0 {
+		lineLength = defaultMarkdownLineLength
+	}
+
+	var out []string
+	blankLine := func() {
+		if len(out) > 0 && out[len(out)-1] != "" {
+			out = append(out, "")
+		}
+	}
+
+	fence := ""
+	prevKind := ""
+	blankAfter := false
+	seenH1 := false
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimRight(line, " \t")
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			out = append(out, line)
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+				blankAfter = true
+			}
+			continue
+		}
+
+		if trimmed == "" {
+			blankLine()
+			blankAfter = false
+			continue
+		}
+		if blankAfter {
+			blankLine()
+			blankAfter = false
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			if strings.Trim(trimmed, fence[:1]) == "" {
+				line += "text"
+			}
+			blankLine()
+			out = append(out, line)
+			prevKind = ""
+			continue
+		case atxHeading.MatchString(line):
+			if strings.HasPrefix(line, "# ") || line == "#" {
+				if seenH1 {
+					line = "#" + line
+				}
+				seenH1 = true
+			}
+			blankLine()
+			out = append(out, line)
+			blankAfter = true
+			prevKind = ""
+			continue
+		case strings.HasPrefix(trimmed, "|"):
+			if prevKind != "table" {
+				blankLine()
+			}
+			out = append(out, line)
+			prevKind = "table"
+			continue
+		case listMarker.MatchString(trimmed):
+			if prevKind != "list" {
+				blankLine()
+			}
+			prevKind = "list"
+		case prevKind == "list" && strings.HasPrefix(line, " "):
+			// A continuation line of the list item above.
+		default:
+			if prevKind == "list" || prevKind == "table" {
+				blankLine()
+			}
+			prevKind = "text"
+		}
+
+		if strings.HasPrefix(trimmed, "<") || strings.HasPrefix(trimmed, "[//]:") {
+			out = append(out, line)
+			continue
+		}
+		out = append(out, wrapLine(bracketURLs(line), lineLength)...)
+	}
+
+	return strings.TrimRight(strings.Join(out, "\n"), "\n") + "\n"
+}
+
+// bracketURLs turns bare URLs outside code spans into autolinks.
+func bracketURLs(line string) string {
+	parts := strings.Split(line, "`")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = bareURL.ReplaceAllString(parts[i], "$1<$2>")
+	}
+	return strings.Join(parts, "`")
+}
+
+// wrapLine breaks line at spaces so no line is longer than lineLength.  The
+// lines after the first keep the indentation, block quote markers and list
+// item indentation of line.  Words that would start a new block at the
+// start of a line stay on the line before.
+func wrapLine(line string, lineLength int) []string {
+	if utf8.RuneCountInString(line) <= lineLength {
+		return []string{line}
+	}
+
+	rest := strings.TrimLeft(line, " ")
+	prefix := line[:len(line)-len(rest)]
+	for strings.HasPrefix(rest, "> ") {
+		prefix += "> "
+		rest = rest[2:]
+	}
+	first, cont := prefix, prefix
+	if marker := listMarker.FindString(rest); marker != "" {
+		first += marker
+		cont += strings.Repeat(" ", len(marker))
+		rest = rest[len(marker):]
+	}
+
+	var lines []string
+	cur := first
+	empty := true
+	for _, word := range strings.Fields(rest) {
+		if !empty && utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > lineLength && !blockStart.MatchString(word) {
+			lines = append(lines, cur)
+			cur = cont + word
+			continue
+		}
+		if !empty {
+			cur += " "
+		}
+		cur += word
+		empty = false
+	}
+	return append(lines, cur)
+}
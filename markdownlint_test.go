@@ -0,0 +1,71 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintMarkdown(t *testing.T) {
+	cases := [][]string{
+		{"# foo  \n\n\n\nbar\n\n\n", "# foo\n\nbar\n"},
+		{"# foo\ntext\n# bar", "# foo\n\ntext\n\n## bar\n"},
+		{"text\n* one\n* two\nmore", "text\n\n* one\n* two\n\nmore\n"},
+		{"text\n```\ncode  \n\n\n```\nmore", "text\n\n```text\ncode\n\n\n```\n\nmore\n"},
+		{"see https://example.com.", "see <https://example.com>.\n"},
+		{"[site](https://example.com) `https://example.com`", "[site](https://example.com) `https://example.com`\n"},
+		{"| a | b |\n| --- | --- |\ntext", "| a | b |\n| --- | --- |\n\ntext\n"},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], lintMarkdown(cases[i][0], 0))
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	assert.Equal(t, []string{"one two", "three"}, wrapLine("one two three", 10))
+	assert.Equal(t, []string{"* one two", "  three"}, wrapLine("* one two three", 10))
+	assert.Equal(t, []string{"> one two", "> three"}, wrapLine("> one two three", 10))
+	assert.Equal(t, []string{"one two -", "three"}, wrapLine("one two - three", 10))
+	assert.Equal(t, []string{"https://example.com/long"}, wrapLine("https://example.com/long", 10))
+}
+
+func TestMarkdownLintOption(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Long: strings.Repeat("word ", 30) + "https://example.com"}
+	cmd.AddCommand(&cobra.Command{Use: "sub", Short: "a sub command", Run: func(*cobra.Command, []string) {}})
+	opts := Options{MarkdownLint: true, MarkdownLineLength: 40}
+
+	assert.NoError(t, GenerateOnePage(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# foo\n\n", buf.String())
+	assert.Regexp(t, "\n## Synopsis\n", buf.String())
+	assert.Regexp(t, "\n## See Also\n\n\\* \\[foo sub\\]", buf.String())
+	assert.Regexp(t, "<https://example.com>", buf.String())
+	assert.NotRegexp(t, "\n\n\n", buf.String())
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.HasPrefix(line, "[//]:") {
+			assert.LessOrEqual(t, len(line), 40, line)
+		}
+	}
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{MarkdownLint: true}, "troff", buf))
+	assert.Regexp(t, "https://example.com\n", buf.String())
+}
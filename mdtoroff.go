@@ -0,0 +1,431 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// markdownParser parses CommonMark with pipe tables, definition lists and
+// bare URLs.
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.Table, extension.DefinitionList, extension.Linkify)).Parser()
+
+// tblAlignments are the tbl column formats of the table alignments.
+var tblAlignments = map[east.Alignment]string{
+	east.AlignLeft:   "l",
+	east.AlignRight:  "r",
+	east.AlignCenter: "c",
+	east.AlignNone:   "l",
+}
+
+// roffStyle holds the macros markdown is rendered into for one of the man
+// page macro packages.
+type roffStyle struct {
+	paragraph string
+	heading   string
+
+	// itemParagraph starts another paragraph in a list item.
+	itemParagraph string
+
+	// listStart and listEnd wrap bullet ([0]) and ordered ([1]) lists.
+	listStart [2]string
+	listEnd   string
+	item      func(ordered bool, number int) string
+
+	// nestStart and nestEnd wrap a list inside a list item.
+	nestStart string
+	nestEnd   string
+
+	// definitionStart and definitionEnd wrap a definition list, termStart
+	// and termEnd the term of each definition.
+	definitionStart string
+	definitionEnd   string
+	termStart       string
+	termEnd         string
+
+	// link returns the macros for a link to url showing text, given in
+	// the format it is written in.
+	link func(url string, text string) string
+	mail func(addr string) string
+
+	codeStart  string
+	codeEnd    string
+	quoteStart string
+	quoteEnd   string
+
+	// spaceBlocks puts a paragraph break before code blocks and quotes as
+	// their macros do not add vertical space themselves.
+	spaceBlocks bool
+}
+
+var troffStyle = roffStyle{
+	paragraph:     ".PP",
+	heading:       ".SS",
+	itemParagraph: ".IP",
+	item: func(ordered bool, number int) string {
+		if ordered {
+			return fmt.Sprintf(".IP %d. 4", number)
+		}
+		return `.IP \(bu 2`
+	},
+	link: func(url string, text string) string {
+		if text == "" {
+			return troffURL(url)
+		}
+		return ".UR " + url + "\n" + text + "\n.UE"
+	},
+	mail:        troffMail,
+	termStart:   ".TP",
+	nestStart:   ".RS",
+	nestEnd:     ".RE",
+	codeStart:   ".EX",
+	codeEnd:     ".EE",
+	quoteStart:  ".RS",
+	quoteEnd:    ".RE",
+	spaceBlocks: true,
+}
+
+var mdocStyle = roffStyle{
+	paragraph:     ".Pp",
+	heading:       ".Ss",
+	itemParagraph: ".Pp",
+	listStart:     [2]string{".Bl -bullet", ".Bl -enum"},
+	listEnd:       ".El",
+	item: func(bool, int) string {
+		return ".It"
+	},
+	link: func(url string, text string) string {
+		if text == "" {
+			return mdocURL(url)
+		}
+		return mdocURL(url) + ` "` + strings.ReplaceAll(strings.ReplaceAll(text, "\n", " "), `"`, `\(dq`) + `"`
+	},
+	mail:            mdocMail,
+	definitionStart: ".Bl -tag -width Ds",
+	definitionEnd:   ".El",
+	termStart:       ".It Xo",
+	termEnd:         ".Xc",
+	codeStart:       ".Bd -literal -offset indent",
+	codeEnd:         ".Ed",
+	quoteStart:      ".Bd -ragged -offset indent",
+	quoteEnd:        ".Ed",
+}
+
+// markdownToTroff renders the markdown in str into troff.  Like
+// simpleToTroff the first paragraph has no .PP in front of it and raw troff
+// fences are passed through.
+func markdownToTroff(str string) string {
+	return markdownToRoff(str, troffStyle)
+}
+
+// markdownToMdoc renders the markdown in str into mdoc.  Like simpleToMdoc
+// the first paragraph has no .Pp in front of it and raw troff fences are
+// passed through.
+func markdownToMdoc(str string) string {
+	return markdownToRoff(str, mdocStyle)
+}
+
+func markdownToRoff(str string, style roffStyle) string {
+	return splitRaw(str, func(str string) string {
+		source := []byte(str)
+		r := roffRenderer{style: style, source: source}
+		r.blocks(markdownParser.Parse(text.NewReader(source)), style.paragraph)
+		return strings.TrimSuffix(r.b.String(), "\n")
+	})
+}
+
+// markdownInlineToTroff renders the inline markdown in str, like the
+// emphasis and code of a flag usage, into troff text.  Blocks are joined
+// into one paragraph.
+func markdownInlineToTroff(str string) string {
+	return markdownInlineToRoff(str, troffStyle)
+}
+
+// markdownInlineToMdoc renders the inline markdown in str into mdoc text.
+func markdownInlineToMdoc(str string) string {
+	return markdownInlineToRoff(str, mdocStyle)
+}
+
+func markdownInlineToRoff(str string, style roffStyle) string {
+	source := []byte(str)
+	r := roffRenderer{style: style, source: source}
+	doc := markdownParser.Parse(text.NewReader(source))
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.PreviousSibling() != nil && !r.atLineStart() {
+			r.b.WriteByte(' ')
+		}
+		r.inline(n)
+	}
+	return strings.TrimSuffix(r.b.String(), "\n")
+}
+
+type roffRenderer struct {
+	style  roffStyle
+	source []byte
+	b      strings.Builder
+
+	// skip is the number of bytes of the next text already written as
+	// punctuation after a link.
+	skip int
+}
+
+// atLineStart tells if the next output starts a new line.
+func (r *roffRenderer) atLineStart() bool {
+	s := r.b.String()
+	return s == "" || s[len(s)-1] == '\n'
+}
+
+// macro writes m on a line of its own.
+func (r *roffRenderer) macro(m string) {
+	if m == "" {
+		return
+	}
+	if !r.atLineStart() {
+		line := strings.TrimRight(r.b.String(), " ")
+		r.b.Reset()
+		r.b.WriteString(line)
+		r.b.WriteByte('\n')
+	}
+	r.b.WriteString(m)
+	r.b.WriteByte('\n')
+}
+
+// text writes escaped text, protecting a leading control character.
+func (r *roffRenderer) text(s string) {
+	if r.atLineStart() {
+		s = strings.TrimLeft(s, " ")
+	}
+	if s == "" {
+		return
+	}
+	if r.atLineStart() && (s[0] == '.' || s[0] == '\'') {
+		r.b.WriteString(`\&`)
+	}
+	r.b.WriteString(backslashify(s))
+}
+
+// blocks renders the block children of parent, separated by the paragraph
+// macro.
+func (r *roffRenderer) blocks(parent ast.Node, paragraph string) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.PreviousSibling() != nil {
+			switch n.Kind() {
+			case ast.KindParagraph, ast.KindTextBlock, east.KindTable:
+				r.macro(paragraph)
+			case ast.KindCodeBlock, ast.KindFencedCodeBlock, ast.KindBlockquote:
+				if r.style.spaceBlocks {
+					r.macro(paragraph)
+				}
+			}
+		}
+		r.block(n)
+	}
+}
+
+func (r *roffRenderer) block(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		r.inline(n)
+	case *ast.Heading:
+		if !r.atLineStart() {
+			r.b.WriteByte('\n')
+		}
+		r.b.WriteString(r.style.heading + " ")
+		r.inline(n)
+		r.b.WriteByte('\n')
+	case *ast.List:
+		r.list(n)
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		var code strings.Builder
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			segment := lines.At(i)
+			code.Write(segment.Value(r.source))
+		}
+		r.macro(r.style.codeStart)
+		r.b.WriteString(troffLiteral(strings.TrimSuffix(code.String(), "\n")))
+		r.macro(r.style.codeEnd)
+	case *east.Table:
+		r.table(n)
+	case *east.DefinitionList:
+		r.definitionList(n)
+	case *ast.Blockquote:
+		r.macro(r.style.quoteStart)
+		r.blocks(n, r.style.paragraph)
+		r.macro(r.style.quoteEnd)
+	}
+}
+
+func (r *roffRenderer) list(n *ast.List) {
+	kind := 0
+	if n.IsOrdered() {
+		kind = 1
+	}
+	start := r.style.listStart[kind]
+	if start != "" && n.IsTight {
+		start += " -compact"
+	}
+	nested := n.Parent() != nil && n.Parent().Kind() == ast.KindListItem
+	if nested {
+		r.macro(r.style.nestStart)
+	}
+	r.macro(start)
+
+	number := n.Start
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		r.macro(r.style.item(n.IsOrdered(), number))
+		r.blocks(item, r.style.itemParagraph)
+		number++
+	}
+	r.macro(r.style.listEnd)
+	if nested {
+		r.macro(r.style.nestEnd)
+	}
+}
+
+// definitionList writes the terms of n as tags followed by their
+// definitions, like the flags in OPTIONS.
+func (r *roffRenderer) definitionList(n *east.DefinitionList) {
+	r.macro(r.style.definitionStart)
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		switch item.Kind() {
+		case east.KindDefinitionTerm:
+			r.macro(r.style.termStart)
+			r.inline(item)
+			if !r.atLineStart() {
+				r.b.WriteByte('\n')
+			}
+			r.macro(r.style.termEnd)
+		case east.KindDefinitionDescription:
+			if item.PreviousSibling().Kind() == east.KindDefinitionDescription {
+				r.macro(r.style.itemParagraph)
+			}
+			r.blocks(item, r.style.itemParagraph)
+		}
+	}
+	r.macro(r.style.definitionEnd)
+}
+
+// table writes a tbl table with a bold header row.  Pages using it need to
+// be run through the tbl preprocessor.
+func (r *roffRenderer) table(n *east.Table) {
+	header := make([]string, len(n.Alignments))
+	body := make([]string, len(n.Alignments))
+	for i, alignment := range n.Alignments {
+		header[i] = tblAlignments[alignment] + "B"
+		body[i] = tblAlignments[alignment]
+	}
+	r.macro(".TS")
+	r.macro(strings.Join(header, " "))
+	r.macro(strings.Join(body, " ") + ".")
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			if cell.PreviousSibling() != nil {
+				r.b.WriteByte('\t')
+			}
+			r.inline(cell)
+		}
+		r.b.WriteByte('\n')
+		if row.Kind() == east.KindTableHeader {
+			r.b.WriteString("_\n")
+		}
+	}
+	r.macro(".TE")
+}
+
+// link writes the macros for a link.  The punctuation at the start of the
+// text node next is passed to the last macro.
+func (r *roffRenderer) link(macros string, next ast.Node) {
+	if t, ok := next.(*ast.Text); ok {
+		value := string(t.Segment.Value(r.source))
+		end := strings.IndexFunc(value, func(c rune) bool { return !strings.ContainsRune(".,;:!?)'\"", c) })
+		if end < 0 {
+			end = len(value)
+		}
+		if end > 0 {
+			macros += " " + backslashify(value[:end])
+			r.skip = end
+		}
+	}
+	r.macro(macros)
+}
+
+func (r *roffRenderer) inline(parent ast.Node) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			r.textNode(n)
+		case *ast.String:
+			r.text(string(n.Value))
+		case *ast.Emphasis:
+			font := `\fI`
+			if n.Level > 1 {
+				font = `\fB`
+			}
+			r.b.WriteString(font)
+			r.inline(n)
+			r.b.WriteString(`\fP`)
+		case *ast.CodeSpan:
+			r.b.WriteString(`\f(CW`)
+			r.inline(n)
+			r.b.WriteString(`\fP`)
+		case *ast.Link, *ast.AutoLink:
+			r.linkNode(n)
+		case *ast.RawHTML:
+			// HTML has no meaning in a man page.
+		default:
+			r.inline(n)
+		}
+	}
+}
+
+// textNode writes a text node, less what a preceding link took of it, and
+// its line break.
+func (r *roffRenderer) textNode(n *ast.Text) {
+	value := string(n.Segment.Value(r.source))
+	r.text(value[r.skip:])
+	r.skip = 0
+	if n.HardLineBreak() {
+		r.macro(".br")
+	} else if n.SoftLineBreak() {
+		r.b.WriteByte('\n')
+	}
+}
+
+// linkNode writes the macros of a link or an autolink.
+func (r *roffRenderer) linkNode(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Link:
+		label := roffRenderer{style: r.style, source: r.source}
+		label.inline(n)
+		text := label.b.String()
+		if string(n.Text(r.source)) == string(n.Destination) {
+			text = ""
+		}
+		r.link(r.style.link(string(n.Destination), text), n.NextSibling())
+	case *ast.AutoLink:
+		if n.AutoLinkType == ast.AutoLinkEmail {
+			r.link(r.style.mail(string(n.Label(r.source))), n.NextSibling())
+			return
+		}
+		r.link(r.style.link(string(n.URL(r.source)), ""), n.NextSibling())
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownToTroff(t *testing.T) {
+	cases := [][]string{
+		{"plain text", "plain text"},
+		{"*foo* **bar** `b-z`", `\fIfoo\fP \fBbar\fP \f(CWb\-z\fP`},
+		{"one\n\ntwo", "one\n.PP\ntwo"},
+		{"line\n.dot", "line\n\\&.dot"},
+		{"* one\n* two", ".IP \\(bu 2\none\n.IP \\(bu 2\ntwo"},
+		{"1. one\n2. two", ".IP 1. 4\none\n.IP 2. 4\ntwo"},
+		{"- one\n  - sub\n- two", ".IP \\(bu 2\none\n.RS\n.IP \\(bu 2\nsub\n.RE\n.IP \\(bu 2\ntwo"},
+		{"text\n\n    code\n    .dot", "text\n.PP\n.EX\ncode\n\\&.dot\n.EE"},
+		{"## Notes\n\ntext", ".SS Notes\n.PP\ntext"},
+		{"| A | B |\n| --- | ---: |\n| `a` | .b |", ".TS\nlB rB\nl r.\nA\tB\n_\n\\f(CWa\\fP\t.b\n.TE"},
+		{"text\n\n| A |\n| --- |\n| .a |", "text\n.PP\n.TS\nlB\nl.\nA\n_\n\\&.a\n.TE"},
+		{"[docs](https://x.io).", ".UR https://x.io\ndocs\n.UE ."},
+		{"see https://x.io, then", "see\n.UR https://x.io\n.UE ,\nthen"},
+		{"Foo Bar <foo@bar.com>.", "Foo Bar\n.MT foo@bar.com\n.ME ."},
+		{"fast\n: go *quick*\n\nslow\n: go slow\n\n  more", ".TP\nfast\ngo \\fIquick\\fP\n.TP\nslow\ngo slow\n.IP\nmore"},
+		{".B not troff", "\\&.B not troff"},
+		{"*a*\n.\\\"RAW\n.B raw\n.\\\"RAW\n*b*", "\\fIa\\fP\n.B raw\n\\fIb\\fP"},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], markdownToTroff(cases[i][0]))
+	}
+}
+
+func TestMarkdownToMdoc(t *testing.T) {
+	cases := [][]string{
+		{"*foo* **bar**", `\fIfoo\fP \fBbar\fP`},
+		{"one\n\ntwo", "one\n.Pp\ntwo"},
+		{"1. one\n2. two", ".Bl -enum -compact\n.It\none\n.It\ntwo\n.El"},
+		{"- one\n  - sub", ".Bl -bullet -compact\n.It\none\n.Bl -bullet -compact\n.It\nsub\n.El\n.El"},
+		{"see [the docs](https://x.io).", "see\n.Lk https://x.io \"the docs\" ."},
+		{"```\ncode\n```", ".Bd -literal -offset indent\ncode\n.Ed"},
+		{"fast\n: go fast", ".Bl -tag -width Ds\n.It Xo\nfast\n.Xc\ngo fast\n.El"},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], markdownToMdoc(cases[i][0]))
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Long: "Copy **all** files.\n\n* one\n* two", Example: "`foo` copies"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Short: "a *sub* command", Run: func(*cobra.Command, []string) {}})
+
+	assert.NoError(t, GenerateOnePage(cmd, &Options{ParseMarkdown: true}, "troff", buf))
+	assert.Regexp(t, "^'\\\\\" t\n.TH", buf.String())
+	assert.Regexp(t, `\nCopy \\fBall\\fP files.\n.IP \\\(bu 2\none\n`, buf.String())
+	assert.Regexp(t, `\na \\fIsub\\fP command\n`, buf.String())
+	assert.Regexp(t, `\n\\f\(CWfoo\\fP copies\n`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{ParseMarkdown: true}, "mdoc", buf))
+	assert.Regexp(t, `\nCopy \\fBall\\fP files.\n.Bl -bullet -compact\n`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "troff", buf))
+	assert.Regexp(t, `\nCopy \*\*all\*\* files.\n`, buf.String())
+	assert.Regexp(t, "^.TH", buf.String())
+}
+
+func TestParseMarkdownFlagUsage(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.Flags().String("mode", "", "use **fast** or `slow_mode` | *none*")
+
+	assert.NoError(t, GenerateOnePage(cmd, &Options{ParseMarkdown: true}, "troff", buf))
+	assert.Regexp(t, `\nuse \\fBfast\\fP or \\f\(CWslow\\_mode\\fP \| \\fInone\\fP\n`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{ParseMarkdown: true}, "mdoc", buf))
+	assert.Regexp(t, `\nuse \\fBfast\\fP or`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{ParseMarkdown: true}, "markdown", buf))
+	assert.Regexp(t, " - use \\*\\*fast\\*\\* or `slow_mode` \\| \\*none\\*\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{ParseMarkdown: true, FlagTable: true}, "markdown", buf))
+	assert.Regexp(t, "\\| use \\*\\*fast\\*\\* or `slow_mode` \\\\\\| \\*none\\* \\|\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "troff", buf))
+	assert.Regexp(t, `\nuse \*\*fast\*\* or`, buf.String())
+}
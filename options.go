@@ -0,0 +1,224 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"io/fs"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// Option sets one of the Options.  The doc of each says which templates it
+// affects, all of them unless it names the man page templates (troff and
+// mdoc) or markdown.
+type Option func(opts *Options)
+
+// NewOptions returns the Options set by options, for GenerateDocs and the
+// other generators.  Options not covered by an Option can still be set on
+// the returned struct.
+func NewOptions(options ...Option) *Options {
+	opts := &Options{}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
+}
+
+// CobraManOptions is the name Options had in earlier releases.
+//
+// Deprecated: use Options, or NewOptions and the With functions.
+type CobraManOptions = Options
+
+// GenerateManPages writes the troff man pages of cmd and its children to
+// directory, like earlier releases did.
+//
+// Deprecated: use GenerateDocs with the "troff" template.
+func GenerateManPages(cmd *cobra.Command, opts *CobraManOptions, directory string) error {
+	return GenerateDocs(cmd, opts, directory, "troff")
+}
+
+// WithSection sets the section of the man pages, "1" by default, which is
+// also their file extension.  Man page templates only.
+func WithSection(section string) Option {
+	return func(opts *Options) { opts.Section = section }
+}
+
+// WithDate sets the date of the pages instead of SOURCE_DATE_EPOCH or now.
+// Man page templates only.
+func WithDate(date time.Time) Option {
+	return func(opts *Options) { opts.Date = &date }
+}
+
+// WithOmitDate leaves the date out of the pages.  Man page templates only.
+func WithOmitDate() Option {
+	return func(opts *Options) { opts.OmitDate = true }
+}
+
+// WithCenterHeader sets the header of the pages.  Man page templates only.
+func WithCenterHeader(header string) Option {
+	return func(opts *Options) { opts.CenterHeader = header }
+}
+
+// WithFooters sets the left and center footers of the pages.  The troff
+// template only.
+func WithFooters(left string, center string) Option {
+	return func(opts *Options) {
+		opts.LeftFooter = left
+		opts.CenterFooter = center
+	}
+}
+
+// WithUTF8 writes non-ASCII characters as they are instead of as groff
+// escapes.  Man page templates only.
+func WithUTF8() Option {
+	return func(opts *Options) { opts.UTF8 = true }
+}
+
+// WithGzip compresses the pages with gzip.  Man page templates only.
+func WithGzip() Option {
+	return func(opts *Options) { opts.Gzip = true }
+}
+
+// WithSectionDirs puts the pages in a man<section>/ directory.  Man page
+// templates only.
+func WithSectionDirs() Option {
+	return func(opts *Options) { opts.SectionDirs = true }
+}
+
+// WithAuthor sets the content of the AUTHOR section.
+func WithAuthor(author string) Option {
+	return func(opts *Options) { opts.Author = author }
+}
+
+// WithBugs sets the content of the BUGS section.
+func WithBugs(bugs string) Option {
+	return func(opts *Options) { opts.Bugs = bugs }
+}
+
+// WithFiles sets the content of the FILES section.
+func WithFiles(files string) Option {
+	return func(opts *Options) { opts.Files = files }
+}
+
+// WithEnvironment sets the content of the ENVIRONMENT section.
+func WithEnvironment(environment string) Option {
+	return func(opts *Options) { opts.Environment = environment }
+}
+
+// WithEnvVars adds the environment variables listed in the ENVIRONMENT
+// section.
+func WithEnvVars(envVars ...EnvVar) Option {
+	return func(opts *Options) { opts.EnvVars = append(opts.EnvVars, envVars...) }
+}
+
+// WithSeeAlso adds references to other man pages, like "crontab(5)", to the
+// SEE ALSO section.
+func WithSeeAlso(refs ...string) Option {
+	return func(opts *Options) { opts.SeeAlso = append(opts.SeeAlso, refs...) }
+}
+
+// WithIncludeHidden documents hidden commands and flags.
+func WithIncludeHidden() Option {
+	return func(opts *Options) { opts.IncludeHidden = true }
+}
+
+// WithIncludeDeprecated documents deprecated commands and flags.
+func WithIncludeDeprecated() Option {
+	return func(opts *Options) { opts.IncludeDeprecated = true }
+}
+
+// WithFilter sets the function reporting whether a command is documented.
+func WithFilter(filter func(cmd *cobra.Command) bool) Option {
+	return func(opts *Options) { opts.Filter = filter }
+}
+
+// WithParseMarkdown treats descriptions, examples and sections as markdown.
+func WithParseMarkdown() Option {
+	return func(opts *Options) { opts.ParseMarkdown = true }
+}
+
+// WithTemplateFile replaces the page template with the one in the file at
+// path.
+func WithTemplateFile(path string) Option {
+	return func(opts *Options) { opts.TemplateFile = path }
+}
+
+// WithTemplateDir replaces the registered templates with the ones in dir.
+func WithTemplateDir(dir string) Option {
+	return func(opts *Options) { opts.TemplateDir = dir }
+}
+
+// WithTemplateFS replaces the registered templates with the ones in fsys,
+// like an embed.FS.
+func WithTemplateFS(fsys fs.FS) Option {
+	return func(opts *Options) { opts.TemplateFS = fsys }
+}
+
+// WithFs sets the file system the files are written to.
+func WithFs(fsys afero.Fs) Option {
+	return func(opts *Options) { opts.Fs = fsys }
+}
+
+// WithLogger sets the Logger getting the files written and commands skipped.
+func WithLogger(logger Logger) Option {
+	return func(opts *Options) { opts.Logger = logger }
+}
+
+// WithJobs sets the number of pages generated concurrently.
+func WithJobs(jobs int) Option {
+	return func(opts *Options) { opts.Jobs = jobs }
+}
+
+// WithIndexFile also writes an index page with this file name.  Markdown
+// only.
+func WithIndexFile(name string) Option {
+	return func(opts *Options) { opts.IndexFile = name }
+}
+
+// WithSingleFile writes all the documentation to one file with this name.
+// Markdown only.
+func WithSingleFile(name string) Option {
+	return func(opts *Options) { opts.SingleFile = name }
+}
+
+// WithLinkHandler sets the function turning the file name of a page into
+// the link to it.  Markdown only.
+func WithLinkHandler(linkHandler func(filename string) string) Option {
+	return func(opts *Options) { opts.LinkHandler = linkHandler }
+}
+
+// WithFilePrepender sets the function returning the text put at the top of
+// each file, like front matter.
+func WithFilePrepender(filePrepender func(filename string) string) Option {
+	return func(opts *Options) { opts.FilePrepender = filePrepender }
+}
+
+// WithFlagTable renders the options as a table.  Markdown only.
+func WithFlagTable() Option {
+	return func(opts *Options) { opts.FlagTable = true }
+}
+
+// WithMarkdownDialect sets the markdown flavor, DialectGFM or
+// DialectCommonMark.  Markdown only.
+func WithMarkdownDialect(dialect string) Option {
+	return func(opts *Options) { opts.MarkdownDialect = dialect }
+}
+
+// WithHeadingOffset moves all headings down by offset levels.  Markdown
+// only.
+func WithHeadingOffset(offset int) Option {
+	return func(opts *Options) { opts.HeadingOffset = offset }
+}
@@ -0,0 +1,68 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptions(t *testing.T) {
+	date := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	opts := NewOptions(
+		WithSection("8"),
+		WithDate(date),
+		WithFooters("Foo 1.0", "Jan 2020"),
+		WithAuthor("Jane Doe"),
+		WithSeeAlso("crontab(5)"),
+		WithSeeAlso("cron(8)"),
+		WithGzip(),
+		WithFlagTable(),
+	)
+	assert.Equal(t, "8", opts.Section)
+	assert.Equal(t, date, *opts.Date)
+	assert.Equal(t, "Foo 1.0", opts.LeftFooter)
+	assert.Equal(t, "Jan 2020", opts.CenterFooter)
+	assert.Equal(t, "Jane Doe", opts.Author)
+	assert.Equal(t, []string{"crontab(5)", "cron(8)"}, opts.SeeAlso)
+	assert.True(t, opts.Gzip)
+	assert.True(t, opts.FlagTable)
+	assert.Equal(t, &Options{}, NewOptions())
+}
+
+func TestNewOptionsGenerateDocs(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo", Short: "foo things"}
+	fs := afero.NewMemMapFs()
+	opts := NewOptions(WithFs(fs), WithSection("8"), WithAuthor("Jane Doe"), WithOmitDate())
+	assert.NoError(t, GenerateDocs(cmd, opts, "man", "troff"))
+
+	data, err := afero.ReadFile(fs, "man/foo.8")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `.TH "FOO" "8" "" "" ""`)
+	assert.Contains(t, string(data), ".SH AUTHOR\nJane Doe")
+}
+
+func TestDeprecatedGenerateManPages(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo", Short: "foo things"}
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, GenerateManPages(cmd, &CobraManOptions{Fs: fs, Author: "Jane Doe"}, "man"))
+
+	data, err := afero.ReadFile(fs, "man/foo.1")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), ".SH AUTHOR\nJane Doe")
+}
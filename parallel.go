@@ -0,0 +1,189 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// commandContext is the part of the data of a page that only depends on
+// the command, not on the template or the options, so it is computed once
+// for all the formats generated.
+type commandContext struct {
+	noArgs         bool
+	synopsisForms  []string
+	examples       []Example
+	examplesErr    error
+	customSections []CustomSection
+}
+
+// commandCache holds the commandContext of the commands seen so far.  It
+// is safe for concurrent use.
+type commandCache struct {
+	mu       sync.Mutex
+	contexts map[*cobra.Command]*commandContext
+}
+
+func newCommandCache() *commandCache {
+	return &commandCache{contexts: make(map[*cobra.Command]*commandContext)}
+}
+
+// get returns the commandContext of cmd.  A nil cache computes it each
+// time.
+func (c *commandCache) get(cmd *cobra.Command) *commandContext {
+	if c == nil {
+		return newCommandContext(cmd)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, ok := c.contexts[cmd]
+	if !ok {
+		ctx = newCommandContext(cmd)
+		c.contexts[cmd] = ctx
+	}
+	return ctx
+}
+
+func newCommandContext(cmd *cobra.Command) *commandContext {
+	// Use reflection to see if cobra.NoArgs was set
+	argFuncName := runtime.FuncForPC(reflect.ValueOf(cmd.Args).Pointer()).Name()
+	ctx := &commandContext{
+		noArgs:         strings.HasSuffix(argFuncName, "cobra.NoArgs"),
+		synopsisForms:  getSynopsis(cmd),
+		customSections: genCustomSections(cmd),
+	}
+	ctx.examples, ctx.examplesErr = getExamples(cmd)
+	return ctx
+}
+
+// prepareCommand makes cobra build the flag sets and sort the children of
+// cmd, which it does lazily on first use, so the pages can then be
+// generated concurrently without writing to the commands, and list the same
+// flags whatever Options.Jobs is.
+func prepareCommand(cmd *cobra.Command) {
+	cmd.Commands()
+	if cmd.HasParent() {
+		cmd.Parent().Commands()
+	}
+	for _, flags := range []*pflag.FlagSet{cmd.InheritedFlags(), cmd.NonInheritedFlags(), cmd.Flags()} {
+		flags.VisitAll(func(*pflag.Flag) {})
+	}
+}
+
+// generatePages writes the pages of cmds, with Options.Jobs of them
+// generated concurrently.  It returns the first error and stops starting
+// new pages after it, or with Options.ContinueOnError generates all the
+// pages and returns a *PageError for each that failed, sorted by command
+// and joined in one error.
+func generatePages(cmds []*cobra.Command, opts *Options, directory string, templateName string) error {
+	for _, c := range cmds {
+		prepareCommand(c)
+	}
+	if opts.Jobs <= 1 {
+		return generatePagesInOrder(cmds, opts, directory, templateName)
+	}
+	return generatePagesConcurrently(cmds, opts, directory, templateName)
+}
+
+// generatePagesInOrder writes the pages of cmds one after the other.
+func generatePagesInOrder(cmds []*cobra.Command, opts *Options, directory string, templateName string) error {
+	var pageErrs []*PageError
+	for _, c := range cmds {
+		if err := generatePage(c, opts, directory, templateName); err != nil {
+			if !opts.ContinueOnError {
+				return err
+			}
+			pageErrs = append(pageErrs, &PageError{Command: c.CommandPath(), Err: err})
+		}
+	}
+	return joinPageErrors(pageErrs)
+}
+
+// generatePagesConcurrently writes the pages of cmds with Options.Jobs
+// goroutines.
+func generatePagesConcurrently(cmds []*cobra.Command, opts *Options, directory string, templateName string) error {
+	for _, c := range cmds {
+		opts.commands.get(c)
+	}
+
+	work := make(chan *cobra.Command)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		pageErrs []*PageError
+	)
+	for i := 0; i < opts.Jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				if err := generatePage(c, opts, directory, templateName); err != nil {
+					mu.Lock()
+					if opts.ContinueOnError {
+						pageErrs = append(pageErrs, &PageError{Command: c.CommandPath(), Err: err})
+					} else if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, c := range cmds {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		work <- c
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return joinPageErrors(pageErrs)
+}
+
+// joinPageErrors sorts pageErrs by command and joins them, nil if there
+// are none.
+func joinPageErrors(pageErrs []*PageError) error {
+	sort.SliceStable(pageErrs, func(i, j int) bool {
+		return pageErrs[i].Command < pageErrs[j].Command
+	})
+	errs := make([]error, len(pageErrs))
+	for i, err := range pageErrs {
+		errs[i] = err
+	}
+	return joinErrors(errs...)
+}
+
+// lock locks the bookkeeping of the files GenerateDocs writes, shared by
+// the Options.Jobs generating them, and returns the function unlocking it.
+func lock(opts *Options) func() {
+	if opts.mu == nil {
+		return func() {}
+	}
+	opts.mu.Lock()
+	return opts.mu.Unlock
+}
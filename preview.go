@@ -0,0 +1,376 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// manCommands are the commands the preview subcommand pipes the page
+// through, trying the next one when a command is not installed or exits
+// with an error, like the man of BSD and macOS which has no -l.
+var manCommands = [][]string{{"man", "-l", "-"}, {"mandoc", "-a"}, {"groff", "-man", "-Tutf8"}}
+
+// previewWidth is the width of the pages rendered without man.
+const previewWidth = 80
+
+// ANSI escape sequences for the fonts of the pages rendered without man.
+const (
+	ansiBold      = "\033[1m"
+	ansiUnderline = "\033[4m"
+	ansiReset     = "\033[0m"
+)
+
+// preview writes the troff page of the command with the path args to the
+// output of myCmd through the first of manCommands that works, or renders
+// it itself when none does.
+func (dg *DocGenTool) preview(myCmd *cobra.Command, args []string) error {
+	cmd, err := findCommand(dg.appCmd, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	opts := *dg.flagOptions(dg.fileOptions(dg.templateOptions("troff")), myCmd)
+	page := new(bytes.Buffer)
+	if err := GenerateOnePageContext(cmdContext(myCmd), cmd, &opts, "troff", page); err != nil {
+		return err
+	}
+
+	for _, manCommand := range manCommands {
+		if _, err := exec.LookPath(manCommand[0]); err != nil {
+			continue
+		}
+		// The errors of a command we fall back from are not shown.
+		stderr := new(bytes.Buffer)
+		c := exec.CommandContext(cmdContext(myCmd), manCommand[0], manCommand[1:]...)
+		c.Stdin = bytes.NewReader(page.Bytes())
+		c.Stdout = myCmd.OutOrStdout()
+		c.Stderr = stderr
+		err := c.Run()
+		if err == nil {
+			_, err = myCmd.ErrOrStderr().Write(stderr.Bytes())
+			return err
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || !exitErr.Exited() {
+			return err
+		}
+		logger(&opts).Debug("previewer failed", "command", strings.Join(manCommand, " "), "error", strings.TrimSpace(stderr.String()))
+	}
+
+	opts.UTF8 = true
+	page.Reset()
+	if err := GenerateOnePageContext(cmdContext(myCmd), cmd, &opts, "troff", page); err != nil {
+		return err
+	}
+	_, err = io.WriteString(myCmd.OutOrStdout(), renderTroff(page.String(), previewWidth, isTerminal(myCmd.OutOrStdout())))
+	return err
+}
+
+// troffRenderer lays out a troff page as text, for the macros the
+// templates use.
+type troffRenderer struct {
+	b       strings.Builder
+	ansi    bool
+	width   int
+	indent  int
+	extra   int
+	words   []string
+	nofill  bool
+	tagNext bool
+	table   int
+	blank   bool
+}
+
+// renderTroff returns the troff page doc laid out as text of width
+// columns, like man shows it.  Bold and italic text is shown with ANSI
+// escape sequences when ansi is set.
+func renderTroff(doc string, width int, ansi bool) string {
+	r := &troffRenderer{ansi: ansi, width: width, indent: 7, blank: true}
+	for _, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			r.macro(line)
+		} else {
+			r.text(line)
+		}
+	}
+	r.flush()
+	return r.b.String()
+}
+
+// macro handles the control line line.
+//
+//nolint:funlen,gocognit,cyclop // one case per macro reads best
+func (r *troffRenderer) macro(line string) {
+	name, args := line[1:], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, args = name[:i], strings.TrimSpace(name[i:])
+	}
+	switch name {
+	case "TH":
+		fields := macroArgs(args)
+		if len(fields) >= 2 {
+			r.writeLine(0, r.font(r.inline(fields[0])+"("+fields[1]+")", ansiBold))
+		}
+	case "SH", "SS", "Sh", "Ss":
+		r.space()
+		r.extra = 0
+		heading := strings.Join(macroArgs(args), " ")
+		if name == "SH" || name == "Sh" {
+			r.writeLine(0, r.font(r.inline(heading), ansiBold))
+		} else {
+			r.writeLine(3, r.font(r.inline(heading), ansiBold))
+		}
+		// No blank line between a heading and its first paragraph.
+		r.blank = true
+	case "PP", "LP", "P", "Pp", "sp":
+		r.space()
+		r.extra = 0
+	case "br":
+		r.flush()
+	case "TP":
+		r.space()
+		r.extra = 0
+		r.tagNext = true
+	case "IP":
+		r.space()
+		r.extra = 0
+		if tag := macroArgs(args); len(tag) > 0 && tag[0] != "" {
+			r.writeLine(r.indent, r.inline(tag[0]))
+		}
+		r.extra = 7
+	case "RS":
+		r.flush()
+		r.indent += 7
+	case "RE":
+		r.flush()
+		if r.indent > 7 {
+			r.indent -= 7
+		}
+	case "nf", "EX":
+		r.flush()
+		r.nofill = true
+	case "fi", "EE":
+		r.nofill = false
+	case "TS":
+		r.flush()
+		r.table = 1
+	case "TE":
+		r.table = 0
+	case "B", "I", "SM":
+		font := map[string]string{"B": ansiBold, "I": ansiUnderline}[name]
+		r.text(r.font(strings.Join(macroArgs(args), " "), font))
+	case "BR", "IR", "RB", "RI", "BI", "IB":
+		var b strings.Builder
+		for i, arg := range macroArgs(args) {
+			font := map[byte]string{'B': ansiBold, 'I': ansiUnderline}[name[i%2]]
+			b.WriteString(r.font(arg, font))
+		}
+		r.text(b.String())
+	case "UR":
+		r.text(args)
+	}
+}
+
+// text handles the text line line.
+func (r *troffRenderer) text(line string) {
+	switch {
+	case r.table == 1:
+		// The options and format lines of tbl end with the first one
+		// ending in a dot.
+		if strings.HasSuffix(strings.TrimSpace(line), ".") {
+			r.table = 2
+		}
+	case r.table == 2:
+		r.writeLine(r.indent+r.extra, r.inline(strings.ReplaceAll(line, "\t", "  ")))
+	case r.nofill:
+		r.writeLine(r.indent+r.extra, r.inline(line))
+	case r.tagNext:
+		r.tagNext = false
+		r.writeLine(r.indent, r.inline(line))
+		r.extra = 7
+	default:
+		r.words = append(r.words, strings.Fields(r.inline(line))...)
+	}
+}
+
+// flush writes the words of the paragraph so far, filled to the width.
+func (r *troffRenderer) flush() {
+	margin := r.indent + r.extra
+	line, length := "", 0
+	for _, word := range r.words {
+		wordLength := visibleLength(word)
+		if line != "" && margin+length+1+wordLength > r.width {
+			r.writeLine(margin, line)
+			line, length = "", 0
+		}
+		if line != "" {
+			line += " "
+			length++
+		}
+		line += word
+		length += wordLength
+	}
+	if line != "" {
+		r.writeLine(margin, line)
+	}
+	r.words = nil
+}
+
+// space ends the paragraph with a blank line.
+func (r *troffRenderer) space() {
+	r.flush()
+	if !r.blank {
+		r.b.WriteString("\n")
+		r.blank = true
+	}
+}
+
+func (r *troffRenderer) writeLine(margin int, line string) {
+	r.b.WriteString(strings.Repeat(" ", margin))
+	r.b.WriteString(line)
+	r.b.WriteString("\n")
+	r.blank = false
+}
+
+// font returns text in the ANSI font, if any.
+func (r *troffRenderer) font(text string, font string) string {
+	if !r.ansi || font == "" || text == "" {
+		return text
+	}
+	return font + text + ansiReset
+}
+
+// troffNames are the characters of the groff special character escapes,
+// like \(em, the templates write.
+var troffNames = func() map[string]string {
+	names := map[string]string{`\(aq`: "'", `\(dq`: `"`, `\(ga`: "`", `\(ti`: "~", `\(ha`: "^"}
+	for r, glyph := range troffGlyphs {
+		names[glyph] = string(r)
+	}
+	return names
+}()
+
+// inline returns the text line with its troff escapes replaced by the
+// characters they stand for and its font changes by ANSI escape sequences.
+//
+//nolint:gocognit,cyclop // one case per escape reads best
+func (r *troffRenderer) inline(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' || i+1 == len(line) {
+			b.WriteByte(line[i])
+			continue
+		}
+		i++
+		switch c := line[i]; c {
+		case 'f':
+			font := ""
+			if i+1 < len(line) {
+				font = line[i+1 : i+2]
+				i++
+				if font == "[" || font == "(" {
+					end := strings.IndexAny(line[i:], "])")
+					if font == "(" {
+						end = minInt(2, len(line)-i-1)
+					}
+					if end >= 0 {
+						font = strings.Trim(line[i+1:i+1+end], "[]")
+						i += end
+					}
+				}
+			}
+			if r.ansi {
+				switch font {
+				case "B":
+					b.WriteString(ansiBold)
+				case "I":
+					b.WriteString(ansiUnderline)
+				default:
+					b.WriteString(ansiReset)
+				}
+			}
+		case '(':
+			name := line[i-1 : minInt(i+3, len(line))]
+			b.WriteString(troffNames[name])
+			i += len(name) - 2
+		case '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				continue
+			}
+			name := line[i+1 : i+end]
+			if code, err := strconv.ParseUint(strings.TrimPrefix(name, "u"), 16, 32); strings.HasPrefix(name, "u") && err == nil {
+				b.WriteRune(rune(code))
+			}
+			i += end
+		case '&', 'c':
+		case '~', ' ':
+			b.WriteByte(' ')
+		case 'e':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// macroArgs splits the arguments of a macro, which can be double quoted.
+func macroArgs(args string) []string {
+	var fields []string
+	for args = strings.TrimSpace(args); args != ""; args = strings.TrimSpace(args) {
+		if args[0] == '"' {
+			end := strings.IndexByte(args[1:], '"')
+			if end < 0 {
+				end = len(args) - 1
+			}
+			fields = append(fields, args[1:1+end])
+			args = args[minInt(end+2, len(args)):]
+			continue
+		}
+		end := strings.IndexAny(args, " \t")
+		if end < 0 {
+			end = len(args)
+		}
+		fields = append(fields, args[:end])
+		args = args[end:]
+	}
+	return fields
+}
+
+// visibleLength returns the number of characters of s shown on a
+// terminal, without its ANSI escape sequences.
+func visibleLength(s string) int {
+	length := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\033' {
+			if end := strings.IndexByte(s[i:], 'm'); end >= 0 {
+				i += end
+				continue
+			}
+		}
+		if utf8.RuneStart(s[i]) {
+			length++
+		}
+	}
+	return length
+}
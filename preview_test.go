@@ -0,0 +1,67 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTroff(t *testing.T) {
+	doc := `.TH "APP" "8" 
+.\" a comment
+.SH NAME
+app \- do things
+.SH DESCRIPTION
+.PP
+Use \fB\-\-all\fP for \fIall\fR of them \(em or \[u00E9]t\(aqe\&.
+.RS
+.nf
+line one
+  line two
+.fi
+.RE
+.IP \(bu
+A bullet
+.TS
+tab(	);
+l l.
+a	b
+.TE
+.SH SEE ALSO
+.BR other (1)
+`
+	assert.Equal(t, `APP(8)
+
+NAME
+       app - do things
+
+DESCRIPTION
+       Use --all for all of them `+"—"+` or `+"é"+`t'e.
+              line one
+                line two
+
+       `+"•"+`
+              A bullet
+              a  b
+
+SEE ALSO
+       other(1)
+`, renderTroff(doc, 80, false))
+
+	assert.Equal(t, "\033[1mAPP(8)\033[0m\n\n\033[1mNAME\033[0m\n       \033[1mapp\033[0m \033[4mnow\033[0m\n",
+		renderTroff(".TH APP 8\n.SH NAME\n.B app\n\\fInow\\fP\n", 80, true))
+	assert.Equal(t, "       one two\n       three\n", renderTroff("one two three\n", 19, false))
+}
@@ -0,0 +1,78 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// modulePath is the module of this package, looked up in the build info
+// for its version.
+const modulePath = "github.com/alecsammon/cobraman"
+
+// moduleVersion returns the version of this package built into the
+// program, "(devel)" when it is the main module, or "unknown".
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
+
+// setProvenance sets the provenance the pages of cmd are stamped with when
+// Options.Provenance is set.
+func setProvenance(cmd *cobra.Command, opts *Options, templateName string) {
+	opts.provenance = ""
+	if !opts.Provenance {
+		return
+	}
+	app := cmd.Root().Name()
+	if version := cmd.Root().Version; version != "" {
+		app += " " + version
+	}
+	opts.provenance = fmt.Sprintf("Generated by %s %s for %s, template=%s section=%s",
+		modulePath, moduleVersion(), app, templateName, opts.Section)
+}
+
+// stampProvenance adds the provenance of opts to out as a comment of the
+// format of the template with the file extension ext.
+func stampProvenance(out string, ext string, opts *Options) string {
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	switch ext {
+	case "md":
+		return out + "\n[//]: # ( " + opts.provenance + " )\n"
+	case "use_section":
+		return out + `.\" ` + opts.provenance + "\n"
+	}
+	return out
+}
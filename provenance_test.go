@@ -0,0 +1,49 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvenance(t *testing.T) {
+	cmd := &cobra.Command{Use: "prov", Version: "1.2.3"}
+	cmd.AddCommand(&cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{}, "troff")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(files["prov-sub.1"]), "Generated by")
+
+	files, err = RenderDocs(cmd, &Options{Provenance: true, Section: "8"}, "troff")
+	assert.NoError(t, err)
+	assert.Regexp(t, `\n\.\\" Generated by github\.com/alecsammon/cobraman \S+ for prov 1\.2\.3, template=troff section=8\n$`, string(files["prov-sub.8"]))
+
+	files, err = RenderDocs(cmd, &Options{Provenance: true}, "mdoc")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(files["prov.1"]), "template=mdoc section=1\n"))
+
+	files, err = RenderDocs(cmd, &Options{Provenance: true, IndexFile: "index.md"}, "markdown")
+	assert.NoError(t, err)
+	for _, name := range []string{"prov_sub.md", "index.md"} {
+		assert.Regexp(t, `\n\n\[//\]: # \( Generated by github\.com/alecsammon/cobraman \S+ for prov 1\.2\.3, template=markdown section=1 \)\n$`, string(files[name]), name)
+	}
+
+	files, err = RenderDocs(cmd, &Options{Provenance: true, SingleFile: "all.md"}, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(files["all.md"]), "Generated by"))
+}
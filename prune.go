@@ -0,0 +1,193 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// generatedMarker is in the comment the templates put in every page.
+const generatedMarker = "auto-generated by github.com/alecsammon/cobraman"
+
+// pruner removes the files of an output directory that GenerateDocs did
+// not write, for Options.Prune.
+type pruner struct {
+	fs        afero.Fs
+	directory string
+
+	// root is the directory the pages of the template are written to and
+	// ext their file extension.  Only the files with ext in root, or below
+	// it when recursive, are checked for generatedMarker, so the pages of
+	// other templates sharing the directory are left alone.
+	root      string
+	ext       string
+	recursive bool
+
+	// listed are the files of the manifest of the previous run.
+	listed map[string]bool
+
+	// written are the files written by this run.
+	written map[string]bool
+}
+
+// newPruner returns a pruner for the files templateName writes to
+// directory, reading the manifest of the previous run if
+// Options.ManifestFile is set and it was written with templateName.  opts
+// must be validated for templateName and the pruner created before the
+// files are generated.
+func newPruner(directory string, templateName string, opts *Options) *pruner {
+	p := &pruner{
+		fs:        opts.Fs,
+		directory: directory,
+		root:      filepath.Join(directory, opts.sectionDir),
+		ext:       "." + opts.fileExtension,
+		recursive: opts.NestedDirs || opts.PathFor != nil,
+		listed:    make(map[string]bool),
+		written:   make(map[string]bool),
+	}
+	if p.fs == nil {
+		p.fs = afero.NewOsFs()
+	}
+	if opts.ManifestFile == "" {
+		return p
+	}
+
+	// A missing or broken manifest only means the markers are used.
+	manifestPath := filepath.Join(directory, opts.ManifestFile)
+	data, err := afero.ReadFile(p.fs, manifestPath)
+	if err != nil {
+		return p
+	}
+	var m Manifest
+	if json.Unmarshal(data, &m) != nil || m.Template != templateName {
+		return p
+	}
+	p.listed[manifestPath] = true
+	for _, entry := range m.Files {
+		p.listed[filepath.Join(directory, filepath.FromSlash(entry.File))] = true
+	}
+	return p
+}
+
+// prune removes the files that were not written and are listed in the
+// previous manifest, or are pages of the template holding
+// generatedMarker.  With Options.DryRun they are reported instead.
+func (p *pruner) prune(opts *Options) error {
+	stale, err := p.stale()
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if opts.DryRun {
+			if _, err := fmt.Fprintf(dryRunOutput(opts), "would remove %s\n", path); err != nil {
+				return err
+			}
+			continue
+		}
+		logger(opts).Info("removing stale file", "file", path)
+		if err := p.fs.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stale returns the files prune removes, sorted.
+func (p *pruner) stale() ([]string, error) {
+	found := make(map[string]bool)
+	for path := range p.listed {
+		if p.written[path] {
+			continue
+		}
+		ok, err := afero.Exists(p.fs, path)
+		if err != nil {
+			return nil, err
+		}
+		found[path] = ok
+	}
+
+	if err := afero.Walk(p.fs, p.root, p.findGenerated(found)); err != nil {
+		return nil, err
+	}
+
+	var stale []string
+	for path, ok := range found {
+		if ok {
+			stale = append(stale, path)
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// findGenerated returns the function walking the directory that marks the
+// pages holding generatedMarker in found.
+func (p *pruner) findGenerated(found map[string]bool) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != p.root && !p.recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p.written[path] || !p.isPage(path) {
+			return nil
+		}
+		if isGenerated(p.fs, path) {
+			found[path] = true
+		}
+		return nil
+	}
+}
+
+// isPage reports whether path has the file extension of the pages of the
+// template, compressed or not.
+func (p *pruner) isPage(path string) bool {
+	return strings.HasSuffix(path, p.ext) || strings.HasSuffix(path, p.ext+".gz")
+}
+
+// isGenerated reports whether the file at path in fs holds generatedMarker,
+// looking into gzip compressed pages.
+func isGenerated(fs afero.Fs, path string) bool {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return false
+	}
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return false
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			return false
+		}
+	}
+	return bytes.Contains(data, []byte(generatedMarker))
+}
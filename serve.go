@@ -0,0 +1,110 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// previewIndex is the name of the index page of the HTML preview.
+const previewIndex = "index.md"
+
+// htmlConverter turns the markdown pages into HTML for the preview.  Raw
+// HTML, like the anchors of Options.FlagAnchors, is kept.
+var htmlConverter = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.DefinitionList),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// htmlPage wraps the HTML of a page for the preview.
+var htmlPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Title }}</title>
+<style>
+body { max-width: 50em; margin: 2em auto; font-family: sans-serif; line-height: 1.5; }
+pre { background: #f4f4f4; padding: 1em; overflow-x: auto; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+</style>
+</head>
+<body>
+{{ .Body }}
+</body>
+</html>
+`))
+
+// PreviewHandler renders the docs of cmd and all of its children with the
+// markdown template and opts, converts them to HTML and returns a handler
+// serving them, for browsing the docs before they are written.  The pages
+// are served under their file name, so the links between them work, and
+// the index of the commands under /.  Options.IndexFile is set to index.md
+// if it is empty.  The docs are rendered once, create a new handler to see
+// changes.
+func PreviewHandler(cmd *cobra.Command, opts *Options) (http.Handler, error) {
+	previewOpts := *opts
+	previewOpts.SingleFile = ""
+	if previewOpts.IndexFile == "" {
+		previewOpts.IndexFile = previewIndex
+	}
+	files, err := RenderDocs(cmd, &previewOpts, "markdown")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string][]byte, len(files))
+	for name, data := range files {
+		if filepath.Ext(name) != ".md" {
+			continue
+		}
+		body := new(bytes.Buffer)
+		if err := htmlConverter.Convert(data, body); err != nil {
+			return nil, err
+		}
+		title := strings.TrimSuffix(filepath.Base(name), ".md")
+		if name == previewOpts.IndexFile {
+			title = cmd.CommandPath()
+		}
+		page := new(bytes.Buffer)
+		err := htmlPage.Execute(page, struct {
+			Title string
+			Body  template.HTML
+		}{title, template.HTML(body.String())}) //nolint:gosec // rendered from the docs of the application
+		if err != nil {
+			return nil, err
+		}
+		pages["/"+filepath.ToSlash(name)] = page.Bytes()
+	}
+	pages["/"] = pages["/"+filepath.ToSlash(previewOpts.IndexFile)]
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(page)
+	}), nil
+}
@@ -0,0 +1,57 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	assert.NoError(t, err)
+	return rec.Code, string(body)
+}
+
+func TestPreviewHandler(t *testing.T) {
+	cmd := &cobra.Command{Use: "web", Short: "Browse the web"}
+	sub := &cobra.Command{Use: "open", Short: "Open a page", Run: func(cmd *cobra.Command, args []string) {}}
+	sub.Flags().String("url", "", "the <url> to open")
+	cmd.AddCommand(sub)
+
+	handler, err := PreviewHandler(cmd, &Options{FlagAnchors: true, SingleFile: "all.md"})
+	assert.NoError(t, err)
+
+	code, body := get(t, handler, "/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<title>web</title>")
+	assert.Contains(t, body, `<a href="web_open.md">`)
+
+	code, body = get(t, handler, "/web_open.md")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<title>web_open</title>")
+	assert.Contains(t, body, `<a id="flag-url"></a>`)
+	assert.Contains(t, body, "the &lt;url&gt; to open")
+
+	code, _ = get(t, handler, "/all.md")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,171 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// ErrNoSingleFileTemplate is returned when a single file is asked for with a
+// template that has no single file template registered.
+var ErrNoSingleFileTemplate = errors.New("the template has no single file template registered")
+
+// maxHeadingLevel is the deepest heading markdown supports.
+const maxHeadingLevel = 6
+
+type singleFileStruct struct {
+	Date             *time.Time
+	Section          string
+	CommandPath      string
+	ShortDescription string
+	Author           string
+	PageHeader       string
+
+	Commands []indexEntry
+	Pages    []string
+
+	CustomData map[string]interface{}
+
+	headingOffset int
+}
+
+// Heading returns the markdown marker for a heading of the given level
+// moved down by Options.HeadingOffset.
+func (s singleFileStruct) Heading(level int) string {
+	return heading(level + s.headingOffset)
+}
+
+// RegisterSingleFileTemplate adds a template that puts the pages of all
+// commands in one file to the already registered template name.  The pages
+// are rendered with the template name and passed in as .Pages.
+func RegisterSingleFileTemplate(name string, templateString string) {
+	t, ok := templateMap[name]
+	if !ok {
+		panic("the given template has not been registered: " + name)
+	}
+	t.single = template.Must(template.New(name + "-single").Funcs(templateFuncs).Parse(templateString))
+	templateMap[name] = t
+}
+
+// GenerateSingleFile writes the documentation of cmd and all of its
+// documented children to w as one document using the single file template
+// of templateName.
+func GenerateSingleFile(cmd *cobra.Command, opts *Options, templateName string, w io.Writer) error {
+	validate(opts, templateName)
+	if err := loadTemplates(opts, templateName); err != nil {
+		return err
+	}
+	setProvenance(cmd, opts, templateName)
+
+	t := opts.templateOf(templateName)
+	if t.single == nil {
+		return ErrNoSingleFileTemplate
+	}
+
+	values := singleFileStruct{
+		Date:             opts.Date,
+		Section:          opts.Section,
+		CommandPath:      cmd.CommandPath(),
+		ShortDescription: cmd.Short,
+		Author:           opts.Author,
+		Commands:         genIndexEntries(cmd, opts, 0),
+		CustomData:       opts.CustomData,
+		headingOffset:    opts.HeadingOffset,
+	}
+	if opts.OmitDate {
+		values.Date = nil
+	}
+	values.PageHeader = opts.PageHeader
+	if opts.PageHeaderFunc != nil {
+		values.PageHeader = opts.PageHeaderFunc(cmd)
+	}
+	for i := range values.Commands {
+		values.Commands[i].Link = "#" + commandAnchor(values.Commands[i].CommandPath, opts)
+	}
+
+	pages, err := singleFilePages(nil, cmd, 0, opts, t)
+	if err != nil {
+		return err
+	}
+	values.Pages = pages
+
+	return executeTemplate(t.single, values, t.extension, opts, w)
+}
+
+// singleFilePages appends the rendered pages of c and its documented
+// children to pages, the headings of c shifted by depth levels.
+func singleFilePages(pages []string, c *cobra.Command, depth int, opts *Options, t manTemplate) ([]string, error) {
+	page, err := genManStruct(c, opts)
+	if err != nil {
+		return nil, err
+	}
+	page.SingleFile = true
+	page.headingOffset = opts.HeadingOffset + depth
+	if opts.SlugFunc != nil {
+		page.Anchor = commandAnchor(c.CommandPath(), opts)
+	}
+
+	buf := new(bytes.Buffer)
+	if t.generator != nil {
+		err = t.generator.Render(opts.renderContext(), c, opts, buf)
+	} else {
+		err = t.template.Execute(buf, page)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pages = append(pages, strings.TrimSpace(buf.String()))
+
+	for _, child := range c.Commands() {
+		if !isDocumented(child, opts) {
+			continue
+		}
+		if pages, err = singleFilePages(pages, child, depth+1, opts, t); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// commandAnchor returns the anchor of the heading of the command with the
+// space separated cmdPath in a single file.
+func commandAnchor(cmdPath string, opts *Options) string {
+	if opts.SlugFunc != nil {
+		return opts.SlugFunc(cmdPath)
+	}
+	return headingSlug(cmdPath)
+}
+
+// headingSlug returns the anchor GitHub gives to a heading with the text
+// str: lower case, spaces turned into dashes and punctuation dropped.
+func headingSlug(str string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(str) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSingleFile(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Short: "does foo"}
+	cmd2 := &cobra.Command{Use: "bar", Short: "does bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2)
+	opts := Options{Author: "Ray"}
+
+	assert.NoError(t, GenerateSingleFile(cmd, &opts, "markdown", buf))
+	assert.Regexp(t, "^# foo\n\ndoes foo\n\n\\* \\[foo\\]\\(#foo\\) - does foo\n  \\* \\[foo bar\\]\\(#foo-bar\\) - does bar\n", buf.String())
+	assert.Contains(t, buf.String(), "\n## foo\n")
+	assert.Contains(t, buf.String(), "\n### Commands\n\n* [foo bar](#foo-bar) - does bar\n")
+	assert.Contains(t, buf.String(), "\n### foo bar\n\ndoes bar\n\n#### Synopsis\n")
+	assert.Regexp(t, "\n## Author\n\nRay\n", buf.String())
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("auto-generated by github.com")))
+
+	assert.Equal(t, ErrNoSingleFileTemplate, GenerateSingleFile(cmd, &opts, "troff", buf))
+}
+
+func TestGenerateDocsSingleFile(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2)
+	opts := Options{SingleFile: "CLI.md"}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "", "markdown"))
+	data, err := os.ReadFile("CLI.md")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "### foo bar\n")
+	checkForFile(t, "CLI.md")
+	checkFileNotExist(t, "foo.md")
+	checkFileNotExist(t, "foo_bar.md")
+}
+
+func TestHeadingSlug(t *testing.T) {
+	assert.Equal(t, "foo-bar-baz", headingSlug("foo bar-baz"))
+	assert.Equal(t, "foo_bar", headingSlug("Foo_Bar!"))
+}
@@ -0,0 +1,71 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"strings"
+
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/cobra"
+)
+
+// SynopsisItem is a flag, or flags that exclude each other, of the SYNOPSIS
+// line, optional unless Required is set.
+type SynopsisItem struct {
+	Flags    []DocFlag
+	Required bool
+}
+
+// getSynopsis returns the usage forms set on cmd with the command path
+// stripped from them.
+func getSynopsis(cmd *cobra.Command) []string {
+	forms := annotations.Synopsis(cmd)
+	for i, form := range forms {
+		if form == cmd.CommandPath() || strings.HasPrefix(form, cmd.CommandPath()+" ") {
+			forms[i] = strings.TrimSpace(strings.TrimPrefix(form, cmd.CommandPath()))
+		}
+	}
+	return forms
+}
+
+// genSynopsisFlags builds the flag part of the SYNOPSIS.  Mutually exclusive
+// flags are combined into one item listing the alternatives.
+func genSynopsisFlags(flags []DocFlag) []SynopsisItem {
+	byName := make(map[string]DocFlag, len(flags))
+	for _, flag := range flags {
+		byName[flag.Name] = flag
+	}
+
+	items := make([]SynopsisItem, 0, len(flags))
+	seen := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		if seen[flag.Name] {
+			continue
+		}
+		seen[flag.Name] = true
+		item := SynopsisItem{Flags: []DocFlag{flag}, Required: flag.Required}
+		for _, name := range flag.ExclusiveWith {
+			peer, exists := byName[name]
+			if !exists || seen[name] {
+				continue
+			}
+			seen[name] = true
+			item.Flags = append(item.Flags, peer)
+			item.Required = false
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
@@ -0,0 +1,56 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSynopsis(t *testing.T) {
+	cmd := &cobra.Command{Use: "app"}
+	cmd2 := &cobra.Command{Use: "copy", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(cmd2)
+
+	assert.Nil(t, getSynopsis(cmd2))
+
+	annotations.SetSynopsis(cmd2, "app copy SRC DST", "--stdin DST", "")
+	assert.Equal(t, []string{"SRC DST", "--stdin DST"}, getSynopsis(cmd2))
+}
+
+func TestSynopsisForms(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "app"}
+	cmd2 := &cobra.Command{Use: "copy", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.Flags().Bool("stdin", false, "read from stdin")
+	cmd.AddCommand(cmd2)
+	annotations.SetSynopsis(cmd2, "SRC DST", "--stdin DST")
+	opts := Options{}
+
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "troff", buf))
+	assert.Regexp(t, ".SH SYNOPSIS\n.sp\n.fBapp copy.fR SRC DST\n.PP\n.fBapp copy.fR \\\\-\\\\-stdin DST\n.SH DESCRIPTION", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "mdoc", buf))
+	assert.Regexp(t, ".Sh SYNOPSIS\n.Nm app copy\nSRC DST\n.Nm app copy\n\\\\-\\\\-stdin DST\n.Ek", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd2, &opts, "markdown", buf))
+	assert.Regexp(t, "### Synopsis\n\n```\napp copy SRC DST\napp copy --stdin DST\n```\n", buf.String())
+}
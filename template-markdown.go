@@ -15,70 +15,251 @@ package cobraman
 
 func init() {
 	RegisterTemplate("markdown", "_", "md", markdownTemplate)
+	RegisterIndexTemplate("markdown", markdownIndexTemplate)
+	RegisterSingleFileTemplate("markdown", markdownSingleFileTemplate)
 }
 
 // markdownTemplate is a template what will generate markdown syntax documentation.
-const markdownTemplate = `## {{.CommandPath}}
+// nolint:lll // this is a template
+const markdownTemplate = `{{- define "flag" -}}
+* {{ if .Anchor }}<a id="{{ .Anchor }}"></a>{{ end }}{{ if .Shorthand }}{{ print "-" .Shorthand | escapeMarkdown }}, {{ end -}}
+{{ print "--" .Name | escapeMarkdown }}
+{{- if not .NoOptDefVal }}=\<{{ .Placeholder | escapeMarkdown }}\>{{ end }}
+{{- if .OptionalValue }}, {{ print "--" .Name | escapeMarkdown }}=\<{{ .Placeholder | escapeMarkdown }}\>{{ end }}
+{{- print " - " }}{{ if .Markdown }}{{ .Usage }}{{ else }}{{ .Usage | escapeMarkdown }}{{ end }}{{ if .Default }} (default: {{ .Default | escapeMarkdown }}){{ end }}{{ if .Required }} (required){{ end }}
+{{- if .OptionalValue }} (without a value: {{ .NoOptDefVal | escapeMarkdown }}){{ end }}
+{{- if .ExclusiveWith }} (mutually exclusive with {{ flagList .ExclusiveWith | escapeMarkdown }}){{ end }}
+{{- if .RequiredWith }} (must be used together with {{ flagList .RequiredWith | escapeMarkdown }}){{ end }}
+{{- end -}}
+{{- define "flagRow" -}}
+| {{ if .Anchor }}<a id="{{ .Anchor }}"></a>{{ end }}` + "`" + `{{ print "--" .Name }}` + "`" + ` |{{ if .Shorthand }} ` + "`" + `{{ print "-" .Shorthand }}` + "`" + `{{ end }} |
+{{- if not .NoOptDefVal }} {{ .Placeholder | tableCell }}{{ else if .OptionalValue }} [{{ .Placeholder | tableCell }}]{{ end }} |
+{{- if .Default }} {{ .Default | tableCell }}{{ end }} | {{ if .Markdown }}{{ .Usage | markdownCell }}{{ else }}{{ .Usage | tableCell }}{{ end }}{{ if .Required }} (required){{ end }}
+{{- if .OptionalValue }} (without a value: {{ .NoOptDefVal | tableCell }}){{ end }}
+{{- if .ExclusiveWith }} (mutually exclusive with {{ flagList .ExclusiveWith | tableCell }}){{ end }}
+{{- if .RequiredWith }} (must be used together with {{ flagList .RequiredWith | tableCell }}){{ end }} |
+{{- end -}}
+{{- define "custom" -}}
+{{- range .CustomSections }}
 
-{{ .ShortDescription }}
+{{ $.Heading 3 }} {{ .Name | escapeMarkdown }}
 
-### Synopsis
+{{ simpleToMarkdown .Content }}
+{{- end }}
+{{- end -}}
+{{ if and .PageHeader (not .SingleFile) }}{{ .PageHeader }}
+
+{{ end -}}
+{{ if .Anchor }}<a id="{{ .Anchor }}"></a>
+
+{{ end -}}
+{{ $.Heading 2 }} {{ .CommandPath | escapeMarkdown }}
+{{- if .Deprecated }}
+
+{{ $.Admonition "Deprecated" .Deprecated }}
+{{- end }}
+{{- if .Warning }}
+
+{{ $.Admonition "Warning" .Warning }}
+{{- end }}
+
+{{ .ShortDescription | escapeMarkdown }}
+{{- if .Aliases }}
+
+**Aliases:** {{ range $i, $alias := .Aliases }}{{ if $i }}, {{ end }}` + "`" + `{{ $alias }}` + "`" + `{{ end }}
+{{- end }}
+
+{{ $.Heading 3 }} Synopsis
+{{- if .SynopsisForms }}
+
+` + "```" + `
+{{- range .SynopsisForms }}
+{{ $.CommandPath }} {{ . }}
+{{- end }}
+` + "```" + `
+{{- end }}
 
-{{ .Description }}
+{{ $.ToMarkdown .Description }}
 
 {{- if .AllFlags }}
 
-### Options
+{{ $.Heading 3 }} Options
 
 The following options are supported:
+{{ range .FlagGroups }}
+{{- if .Name }}
+{{ $.Heading 4 }} {{ .Name | escapeMarkdown }}
+{{ end }}
+{{- if $.FlagTable }}
+| Flag | Shorthand | Type | Default | Description |
+| --- | --- | --- | --- | --- |
+{{ range .Flags -}}
+{{ template "flagRow" . }}
+{{ end }}
+{{- else }}
+{{ range .Flags -}}
+{{ template "flag" . }}
+{{ end }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- if .DeprecatedFlags }}
 
-{{ range .AllFlags -}}
-* {{ if .Shorthand }}{{ print "-" .Shorthand }}, {{ end -}}{{ print "--" .Name }}
-{{- if not .NoOptDefVal }}{{if .ArgHint }}=<{{ .ArgHint }}>{{ else }}=<{{ .DefValue }}>{{ end }}{{ end }}
-{{- print " - " .Usage }}
+{{ $.Heading 4 }} Deprecated Options
+
+{{ range .DeprecatedFlags -}}
+{{ template "flag" . }} (deprecated: {{ .Deprecated | escapeMarkdown }})
 {{ end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "OPTIONS" }}{{ template "custom" . }}{{ end }}
 
-{{- if .Environment }}
+{{- if .SubCommands }}
 
-### Environment
+{{ $.Heading 3 }} Commands
+{{ range .SubCommands }}
+* [{{ .CommandPath | escapeMarkdown }}]({{ $.Link .CommandPath }}) - {{ .Short | escapeMarkdown }}
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "COMMANDS" }}{{ template "custom" . }}{{ end }}
 
-{{ .Environment }}
+{{- if or .Environment .EnvVars }}
+
+{{ $.Heading 3 }} Environment
+{{- if .Environment }}
+
+{{ simpleToMarkdown .Environment }}
+{{- end }}
+{{- if .EnvVars }}
+{{ range .EnvVars }}
+* {{ .Name | escapeMarkdown }} - {{ .Description | escapeMarkdown }}{{ if .Flag }} (same as {{ print "--" .Flag | escapeMarkdown }}){{ end }}
+{{- end }}
+{{- end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "ENVIRONMENT" }}{{ template "custom" . }}{{ end }}
+{{- if or .Files .ConfigFiles }}
+
+{{ $.Heading 3 }} Files
 {{- if .Files }}
 
-### Files
+{{ simpleToMarkdown .Files }}
+{{- end }}
+{{- if .ConfigFiles }}
+
+Configuration is read from the first of these files that exists:
+{{ range .ConfigFiles }}
+* {{ . | escapeMarkdown }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "FILES" }}{{ template "custom" . }}{{ end }}
+{{- if .Diagnostics }}
+
+{{ $.Heading 3 }} Diagnostics
 
-{{ .Files }}
+{{ simpleToMarkdown .Diagnostics }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "DIAGNOSTICS" }}{{ template "custom" . }}{{ end }}
 {{- if .Bugs }}
 
-### Bugs
+{{ $.Heading 3 }} Bugs
 
-{{ .Bugs }}
+{{ simpleToMarkdown .Bugs }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "BUGS" }}{{ template "custom" . }}{{ end }}
+{{- if or .Examples .StructuredExamples }}
+
+{{ $.Heading 3 }} Examples
 {{- if .Examples }}
 
-### Examples
+{{ simpleToMarkdown .Examples }}
+{{- end }}
+{{- range .StructuredExamples }}
+{{- if .Description }}
 
-{{ .Examples }}
+{{ simpleToMarkdown .Description }}
+{{- end }}
+
+` + "```" + `{{ $.ExampleLanguage }}
+{{ .Command }}
+{{- if .Output }}
+{{ .Output }}
+{{- end }}
+` + "```" + `
+{{- end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "EXAMPLES" }}{{ template "custom" . }}{{ end }}
+{{- if not .SingleFile }}
 
-### Author
+{{ $.Heading 3 }} Author
 {{- if .Author }}
 
-{{ .Author }}
+{{ simpleToMarkdown .Author }}
 {{- end }}
 
 Page auto-generated by rayjohnson/cobraman and spf13/cobra
+{{- end }}
+{{- if eq .CustomSectionsAfter "AUTHOR" }}{{ template "custom" . }}{{ end }}
 {{- if .SeeAlsos }}
 
-### See Also
+{{ $.Heading 3 }} See Also
 
 {{- range $index, $element := .SeeAlsos}}
-* [{{ $element.CmdPath }}]({{ $element.CmdPath | underscoreify }}.md)
+{{- if $element.IsURL }}
+* <{{ $element.CmdPath }}>
+{{- else if $element.IsExternal }}
+* {{ $element.CmdPath | escapeMarkdown }}{{ if $element.Section }}({{ $element.Section | escapeMarkdown }}){{ end }}
+{{- else }}
+* [{{ $element.CmdPath | escapeMarkdown }}]({{ $.Link $element.CmdPath }})
+{{- end }}
 {{- end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "SEE ALSO" }}{{ template "custom" . }}{{ end }}
+{{- if not .SingleFile }}
+
+[//]: # ( This file auto-generated by github.com/alecsammon/cobraman  )
+{{ end -}}
+`
+
+// markdownIndexTemplate lists the whole command tree with links to the pages.
+const markdownIndexTemplate = `{{ .Heading 1 }} {{ .CommandPath | escapeMarkdown }}
+{{- if .ShortDescription }}
+
+{{ .ShortDescription | escapeMarkdown }}
+{{- end }}
+
+{{ .Heading 2 }} Commands
+{{ range .Commands }}
+{{ repeat "  " .Depth }}* [{{ .CommandPath | escapeMarkdown }}]({{ .Link }}){{ if .Short }} - {{ .Short | escapeMarkdown }}{{ end }}
+{{- end }}
+
+[//]: # ( This file auto-generated by github.com/alecsammon/cobraman  )
+`
+
+// markdownSingleFileTemplate puts the pages of all commands in one document.
+const markdownSingleFileTemplate = `{{ if .PageHeader }}{{ .PageHeader }}
+
+{{ end -}}
+{{ .Heading 1 }} {{ .CommandPath | escapeMarkdown }}
+{{- if .ShortDescription }}
+
+{{ .ShortDescription | escapeMarkdown }}
+{{- end }}
+{{ range .Commands }}
+{{ repeat "  " .Depth }}* [{{ .CommandPath | escapeMarkdown }}]({{ .Link }}){{ if .Short }} - {{ .Short | escapeMarkdown }}{{ end }}
+{{- end }}
+{{- range .Pages }}
+
+{{ . }}
+{{- end }}
+
+{{ .Heading 2 }} Author
+{{- if .Author }}
+
+{{ simpleToMarkdown .Author }}
+{{- end }}
+
+Page auto-generated by rayjohnson/cobraman and spf13/cobra
 
 [//]: # ( This file auto-generated by github.com/alecsammon/cobraman  )
 `
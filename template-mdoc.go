@@ -18,81 +18,192 @@ func init() {
 }
 
 // mdocManTemplate is a template what will use the mdoc macro package.
-const mdocManTemplate = `.\" Man page for {{.CommandPath}}
-.Dd {{ .Date.Format "January 2006"}}
+// nolint:lll // this is a template
+const mdocManTemplate = `{{- define "flag" -}}
+.Pp
+.It {{ if .Shorthand }}Fl {{ .Shorthand | escapeTroff }}, {{ end -}}
+Fl {{ print "-" .Name | escapeTroff }}
+{{- if not .NoOptDefVal }} Ar {{ .Placeholder | escapeTroff }}{{ end }}
+{{- if .OptionalValue }} , Fl {{ print "-" .Name | escapeTroff }} Ns = Ns Ar {{ .Placeholder | escapeTroff }}{{ end }}
+{{ if .Markdown }}{{ .Usage | inlineMdoc }}{{ else }}{{ .Usage | escapeTroff }}{{ end }}{{ if .Default }} (default: {{ .Default | escapeTroff }}){{ end }}{{ if .Required }} (required){{ end }}
+{{- if .OptionalValue }} (without a value: {{ .NoOptDefVal | escapeTroff }}){{ end }}
+{{- if .ExclusiveWith }} (mutually exclusive with {{ flagList .ExclusiveWith | escapeTroff }}){{ end }}
+{{- if .RequiredWith }} (must be used together with {{ flagList .RequiredWith | escapeTroff }}){{ end }}
+{{- end -}}
+{{- define "custom" -}}
+{{- range .CustomSections }}
+.Sh {{ .Name | upper | escapeTroff }}
+{{ $.ToMdoc .Content }}
+{{- end }}
+{{- end -}}
+{{ if .ParseMarkdown }}'\" t
+{{ end -}}
+.\" Man page for {{.CommandPath | escapeTroff}}
+.Dd{{ if .Date }} {{ .Date.Format "January 2006" }}{{ end }}
 {{ if .CenterHeader -}}
-.Dt {{.CommandPath | dashify | backslashify | upper}} \&{{ .Section }} "{{.CenterHeader}}" 
+.Dt {{.CommandPath | dashify | upper | escapeTroff}} \&{{ .Section | escapeTroff }} "{{.CenterHeader | escapeTroff}}" 
 {{- else -}}
-.Dt {{.CommandPath | dashify | backslashify | upper}} {{ .Section }}
+.Dt {{.CommandPath | dashify | upper | escapeTroff}} {{ .Section | escapeTroff }}
 {{- end }}
 ./" TODO: The Dt macro can take one additonal arg - what does it do?
 .Os
 ." This file auto-generated by github.com/alecsammon/cobraman 
 .Sh NAME
-.Nm {{ .CommandPath | dashify | backslashify }}
-{{- if .ShortDescription }}
-.Nd {{ .ShortDescription }}
+.Nm {{ .CommandPath | dashify | escapeTroff }}
+{{- if .NameDescription }}
+.Nd {{ .NameDescription | escapeTroff }}
 {{- end }}
 .Sh SYNOPSIS
-{{- if .SubCommands }}
+{{- if .SynopsisForms }}
+{{- range .SynopsisForms }}
+.Nm {{ $.CommandPath | escapeTroff }}
+{{ . | escapeTroff }}
+{{- end }}
+{{- else if .SubCommands }}
 {{- range .SubCommands }}
-.Nm {{ .CommandPath }} Op Fl flags Op args
+.Nm {{ .CommandPath | escapeTroff }} Op Fl flags Op args
 {{- end }}
 {{- else }}
-.Nm {{ .CommandPath }}
-{{- range .AllFlags }}
-.Op Fl {{ if .Shorthand }}{{ .Shorthand | backslashify }} | {{ end -}}
-{{ print "-" .Name | backslashify }}
+.Nm {{ .CommandPath | escapeTroff }}
+{{- range .SynopsisFlags }}
+{{ if .Required }}.Fl{{ else }}.Op Fl{{ end }}
+{{- range $i, $flag := .Flags }}{{ if $i }} | Fl{{ end }} {{ if .Shorthand }}{{ .Shorthand | escapeTroff }} | {{ end -}}
+{{ print "-" .Name | escapeTroff }}
+{{- if .OptionalValue }} Ns Op = Ns Ar {{ .Placeholder | escapeTroff }}{{ end }}
+{{- end }}
 {{- end }}
 {{ if not .NoArgs }}.Op Fl <args>
 {{- end }}
 {{- end }}
 .Ek
 .Sh DESCRIPTION
+{{- if .Deprecated }}
+.Sy This command is deprecated:
+{{ .Deprecated | escapeTroff }}
+.Pp
+{{- end }}
+{{- if .Warning }}
+.Sy Warning:
+{{ .Warning | escapeTroff }}
+.Pp
+{{- end }}
 .Nm
-{{ .Description | simpleToMdoc }}
+{{ $.ToMdoc .Description }}
 {{- if .AllFlags }}
 .Pp
 The options are as follows:
+{{- range .FlagGroups }}
+{{- if .Name }}
+.Ss {{ .Name | escapeTroff }}
+{{- end }}
 .Pp
 .Bl -tag -width Ds -compact
-{{ range .AllFlags -}}
-.Pp
-.It {{ if .Shorthand }}Fl {{ .Shorthand | backslashify }}, {{ end -}}
-Fl {{ print "-" .Name | backslashify }}
-{{- if not .NoOptDefVal }} Ar {{if .ArgHint }} {{ .ArgHint }}{{ else }} {{ .DefValue }}{{ end }}{{ end }}
-{{ .Usage | backslashify }}
+{{ range .Flags -}}
+{{ template "flag" . }}
 {{ end }}
 .El
 {{- end }}
-{{- if .Environment }}
+{{- end }}
+{{- if .DeprecatedFlags }}
+.Ss DEPRECATED OPTIONS
+.Bl -tag -width Ds -compact
+{{ range .DeprecatedFlags -}}
+{{ template "flag" . }}
+.br
+.Em Deprecated:
+{{ .Deprecated | escapeTroff }}
+{{ end }}
+.El
+{{- end }}
+{{- if eq .CustomSectionsAfter "OPTIONS" }}{{ template "custom" . }}{{ end }}
+{{- if .SubCommands }}
+.Sh COMMANDS
+.Bl -tag -width Ds
+{{- range .SubCommands }}
+.It Xr {{ .CommandPath | dashify | escapeTroff }} {{ $.Section }}
+{{ $.ToMdoc .Short }}
+{{- end }}
+.El
+{{- end }}
+{{- if eq .CustomSectionsAfter "COMMANDS" }}{{ template "custom" . }}{{ end }}
+{{- if or .Environment .EnvVars }}
 .Sh ENVIRONMENT
-{{ .Environment | simpleToMdoc }}
+{{- if .Environment }}
+{{ $.ToMdoc .Environment }}
 {{- end }}
-{{- if .Files }}
+{{- if .EnvVars }}
+.Bl -tag -width Ds
+{{- range .EnvVars }}
+.It Ev {{ .Name | escapeTroff }}
+{{ .Description | escapeTroff }}{{ if .Flag }} (same as Fl {{ print "-" .Flag | escapeTroff }} ){{ end }}
+{{- end }}
+.El
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "ENVIRONMENT" }}{{ template "custom" . }}{{ end }}
+{{- if or .Files .ConfigFiles }}
 .Sh FILES
-{{ .Files | simpleToMdoc }}
+{{- if .Files }}
+{{ $.ToMdoc .Files }}
+{{- end }}
+{{- if .ConfigFiles }}
+.Pp
+Configuration is read from the first of these files that exists:
+.Bl -tag -width Ds
+{{- range .ConfigFiles }}
+.It Pa {{ . | escapeTroff }}
+{{- end }}
+.El
 {{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "FILES" }}{{ template "custom" . }}{{ end }}
 {{- if .Bugs }}
 .Sh BUGS
-{{ .Bugs | simpleToMdoc }}
+{{ $.ToMdoc .Bugs }}
 {{- end }}
-{{- if .Examples }}
+{{- if eq .CustomSectionsAfter "BUGS" }}{{ template "custom" . }}{{ end }}
+{{- if or .Examples .StructuredExamples }}
 .Sh EXAMPLES
-{{ .Examples | simpleToMdoc }}
+{{- if .Examples }}
+{{ $.ToMdoc .Examples }}
+{{- end }}
+{{- range .StructuredExamples }}
+{{- if .Description }}
+.Pp
+{{ $.ToMdoc .Description }}
+{{- end }}
+.Bd -literal -offset indent
+{{ .Command | troffLiteral }}
+{{- if .Output }}
+{{ .Output | troffLiteral }}
 {{- end }}
+.Ed
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "EXAMPLES" }}{{ template "custom" . }}{{ end }}
+{{- if .Diagnostics }}
+.Sh DIAGNOSTICS
+{{ $.ToMdoc .Diagnostics }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "DIAGNOSTICS" }}{{ template "custom" . }}{{ end }}
 .Sh AUTHOR
 {{- if .Author }}
-{{ .Author }}
+{{ $.ToMdoc .Author }}
 {{- end }}
 .sp
 Page auto-generated by rayjohnson/cobraman and spf13/cobra
+{{- if eq .CustomSectionsAfter "AUTHOR" }}{{ template "custom" . }}{{ end }}
 {{- if .SeeAlsos }}
 .Sh SEE ALSO
 {{- range $index, $element := .SeeAlsos}}
 {{- if $index}} ,{{end}}
-.Xr {{$element.CmdPath}} {{$element.Section}}
+{{- if $element.IsURL }}
+.Lk {{ $element.CmdPath }}
+{{- else }}
+.Xr {{$element.CmdPath | escapeTroff}}{{ if $element.Section }} {{$element.Section | escapeTroff}}{{ end }}
+{{- end }}
 {{- end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "SEE ALSO" }}{{ template "custom" . }}{{ end }}
 ." This file auto-generated by github.com/alecsammon/cobraman 
 `
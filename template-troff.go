@@ -19,73 +19,187 @@ func init() {
 
 // troffManTemplate generates a man page with only basic troff macros.
 // nolint:lll // this is a template
-const troffManTemplate = `.TH "{{.CommandPath | dashify | backslashify | upper}}" "{{ .Section }}" "{{.CenterFooter}}" "{{.LeftFooter}}" "{{.CenterHeader}}" 
+const troffManTemplate = `{{- define "flag" -}}
+.TP
+{{ if .Shorthand }}\fB{{ print "-" .Shorthand | escapeTroff }}\fP, {{ end -}}
+\fB{{ print "--" .Name | escapeTroff }}\fP{{ if not .NoOptDefVal }} = <{{ .Placeholder | escapeTroff }}>{{ end }}
+{{- if .OptionalValue }}, \fB{{ print "--" .Name | escapeTroff }}\fP=<{{ .Placeholder | escapeTroff }}>{{ end }}
+{{ if .Markdown }}{{ .Usage | inlineTroff }}{{ else }}{{ .Usage | escapeTroff }}{{ end }}{{ if .Default }} (default: {{ .Default | escapeTroff }}){{ end }}{{ if .Required }} (required){{ end }}
+{{- if .OptionalValue }} (without a value: {{ .NoOptDefVal | escapeTroff }}){{ end }}
+{{- if .ExclusiveWith }} (mutually exclusive with {{ flagList .ExclusiveWith | escapeTroff }}){{ end }}
+{{- if .RequiredWith }} (must be used together with {{ flagList .RequiredWith | escapeTroff }}){{ end }}
+{{- end -}}
+{{- define "custom" -}}
+{{- range .CustomSections }}
+.SH {{ .Name | upper | escapeTroff }}
+.PP
+{{ $.ToTroff .Content }}
+{{- end }}
+{{- end -}}
+{{ if .ParseMarkdown }}'\" t
+{{ end -}}
+.TH "{{.CommandPath | dashify | upper | escapeTroff}}" "{{ .Section | escapeTroff }}" "{{ .CenterFooter | escapeTroff }}" "{{ .LeftFooter | escapeTroff }}" "{{ .CenterHeader | escapeTroff }}" 
+{{ if .Hyphenate -}}
+.\" enable hyphenation
+.hy
+{{ else -}}
 .\" disable hyphenation
 .nh
+{{ end -}}
+{{ if .Justify -}}
+.\" enable justification (adjust text to both margins)
+.ad b
+{{ else -}}
 .\" disable justification (adjust text to left margin only)
 .ad l
+{{ end -}}
 ." This file auto-generated by github.com/alecsammon/cobraman 
 .SH NAME
-{{ .CommandPath | dashify | backslashify }}
-{{- if .ShortDescription }} - {{ .ShortDescription }}
+{{ .CommandPath | dashify | escapeTroff }}
+{{- if .NameDescription }} - {{ .NameDescription | escapeTroff }}
  {{- end }}
 .SH SYNOPSIS
 .sp
-{{- if .SubCommands }}
+{{- if .SynopsisForms }}
+{{- range $i, $form := .SynopsisForms }}
+{{- if $i }}
+.PP{{ end }}
+\fB{{ $.CommandPath | escapeTroff }}\fR {{ $form | escapeTroff }}
+{{- end }}
+{{- else if .SubCommands }}
 {{- range .SubCommands }}
-\fB{{ .CommandPath }}\fR [ flags ]
+\fB{{ .CommandPath | escapeTroff }}\fR [ flags ]
 .br{{ end }}
 {{- else }}
-\fB{{ .CommandPath }} \fR
-{{- range .AllFlags -}}
-[{{ if .Shorthand }}\fI{{ print "-" .Shorthand | backslashify }}\fP|{{ end -}}
-\fI{{ print "--" .Name | backslashify }}\fP] {{ end }}
+\fB{{ .CommandPath | escapeTroff }} \fR
+{{- range .SynopsisFlags -}}
+{{ if not .Required }}[{{ end }}
+{{- range $i, $flag := .Flags }}{{ if $i }} | {{ end }}
+{{- if .Shorthand }}\fI{{ print "-" .Shorthand | escapeTroff }}\fP|{{ end -}}
+\fI{{ print "--" .Name | escapeTroff }}\fP
+{{- if .OptionalValue }}[=<{{ .Placeholder | escapeTroff }}>]{{ end }}{{ end -}}
+{{ if not .Required }}]{{ end }} {{ end }}
 {{- if not .NoArgs }}[<args>]{{ end }}
 {{- end }}
 .SH DESCRIPTION
+{{- if .Deprecated }}
+.PP
+\fBThis command is deprecated:\fP {{ .Deprecated | escapeTroff }}
+{{- end }}
+{{- if .Warning }}
+.PP
+\fBWarning:\fP {{ .Warning | escapeTroff }}
+{{- end }}
 .PP
-{{ .Description | simpleToTroff }}
-{{- if .AllFlags }}
+{{ $.ToTroff .Description }}
+{{- if or .AllFlags .DeprecatedFlags }}
 .SH OPTIONS
-{{ range .AllFlags -}}
-.TP
-{{ if .Shorthand }}\fB{{ print "-" .Shorthand | backslashify }}\fP, {{ end -}}
-\fB{{ print "--" .Name | backslashify }}\fP{{ if not .NoOptDefVal }} =
-{{- if .ArgHint }} <{{ .ArgHint }}>{{ else }} {{ .DefValue }}{{ end }}{{ end }}
-{{ .Usage | backslashify }}
+{{ range .FlagGroups -}}
+{{ if .Name }}.SS {{ .Name | escapeTroff }}
+{{ end -}}
+{{ range .Flags -}}
+{{ template "flag" . }}
+{{ end }}
+{{- end }}
+{{- if .DeprecatedFlags }}
+.SS DEPRECATED OPTIONS
+{{ range .DeprecatedFlags -}}
+{{ template "flag" . }}
+.br
+\fIDeprecated:\fP {{ .Deprecated | escapeTroff }}
 {{ end }}
+{{- end }}
 {{- end -}}
-{{- if .Environment }}
+{{- if eq .CustomSectionsAfter "OPTIONS" }}{{ template "custom" . }}{{ end }}
+{{- if .SubCommands }}
+.SH COMMANDS
+{{- range .SubCommands }}
+.TP
+\fB{{ .CommandPath | dashify | escapeTroff }}\fP({{ $.Section }})
+{{ $.ToTroff .Short }}
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "COMMANDS" }}{{ template "custom" . }}{{ end }}
+{{- if or .Environment .EnvVars }}
 .SH ENVIRONMENT
+{{- if .Environment }}
 .PP
-{{ .Environment | simpleToTroff }}
+{{ $.ToTroff .Environment }}
 {{- end }}
-{{- if .Files }}
+{{- range .EnvVars }}
+.TP
+\fB{{ .Name | escapeTroff }}\fP
+{{ .Description | escapeTroff }}{{ if .Flag }} (same as \fB{{ print "--" .Flag | escapeTroff }}\fP){{ end }}
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "ENVIRONMENT" }}{{ template "custom" . }}{{ end }}
+{{- if or .Files .ConfigFiles }}
 .SH FILES
+{{- if .Files }}
+.PP
+{{ $.ToTroff .Files }}
+{{- end }}
+{{- if .ConfigFiles }}
+.PP
+Configuration is read from the first of these files that exists:
+{{- range .ConfigFiles }}
+.IP
+\fI{{ . | escapeTroff }}\fP
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if eq .CustomSectionsAfter "FILES" }}{{ template "custom" . }}{{ end }}
+{{- if .Diagnostics }}
+.SH DIAGNOSTICS
 .PP
-{{ .Files | simpleToTroff }}
+{{ $.ToTroff .Diagnostics }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "DIAGNOSTICS" }}{{ template "custom" . }}{{ end }}
 {{- if .Bugs }}
 .SH BUGS
 .PP
-{{ .Bugs | simpleToTroff }}
+{{ $.ToTroff .Bugs }}
 {{- end }}
-{{- if .Examples }}
+{{- if eq .CustomSectionsAfter "BUGS" }}{{ template "custom" . }}{{ end }}
+{{- if or .Examples .StructuredExamples }}
 .SH EXAMPLES
+{{- if .Examples }}
+.PP
+{{ $.ToTroff .Examples }}
+{{- end }}
+{{- range .StructuredExamples }}
+{{- if .Description }}
+.PP
+{{ $.ToTroff .Description }}
+{{- end }}
 .PP
-{{ .Examples | simpleToTroff }}
+.EX
+{{ .Command | troffLiteral }}
+{{- if .Output }}
+{{ .Output | troffLiteral }}
+{{- end }}
+.EE
+{{- end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "EXAMPLES" }}{{ template "custom" . }}{{ end }}
 .SH AUTHOR
 {{- if .Author }}
-{{ .Author }}
+{{ $.ToTroff .Author }}
 {{- end }}
 .PP
 .SM Page auto-generated by rayjohnson/cobraman and spf13/cobra
+{{- if eq .CustomSectionsAfter "AUTHOR" }}{{ template "custom" . }}{{ end }}
 {{- if .SeeAlsos }}
 .SH SEE ALSO
 {{- range .SeeAlsos }}
-.BR {{ .CmdPath | dashify | backslashify }} ({{ .Section }})
+{{- if .IsURL }}
+.UR {{ .CmdPath }}
+.UE
+{{- else }}
+.BR {{ .CmdPath | dashify | escapeTroff }}{{ if .Section }} ({{ .Section }}){{ end }}
+{{- end }}
 {{- end }}
 {{- end }}
+{{- if eq .CustomSectionsAfter "SEE ALSO" }}{{ template "custom" . }}{{ end }}
 ." This file auto-generated by github.com/alecsammon/cobraman 
 `
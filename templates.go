@@ -14,29 +14,75 @@
 package cobraman
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 	"text/template"
+
+	"github.com/spf13/cobra"
 )
 
 type manTemplate struct {
 	separator string
 	extension string
 	template  *template.Template
+	index     *template.Template
+	single    *template.Template
+	generator Generator
+}
+
+// GeneratorFunc writes the page of cmd in a custom format to w, for formats
+// that are easier to write in Go than as a template.
+type GeneratorFunc func(cmd *cobra.Command, opts *Options, w io.Writer) error
+
+// funcGenerator is the Generator of a GeneratorFunc.
+type funcGenerator struct {
+	separator string
+	extension string
+	generate  GeneratorFunc
+}
+
+func (g funcGenerator) Render(ctx context.Context, cmd *cobra.Command, opts *Options, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return g.generate(cmd, opts, w)
 }
 
+func (g funcGenerator) Separator() string { return g.separator }
+
+func (g funcGenerator) Extension() string { return g.extension }
+
 var templateMap = make(map[string]manTemplate)
 
 var templateFuncs = template.FuncMap{
-	"upper":          strings.ToUpper,
-	"backslashify":   backslashify,
-	"dashify":        dashify,
-	"underscoreify":  underscoreify,
-	"simpleToTroff":  simpleToTroff,
-	"simpleToMdoc":   simpleToMdoc,
-	"makeline":       makeline,
-	"trim":           strings.TrimSpace,
-	"trimRightSpace": trimRightSpace,
-	"rpad":           rpad,
+	"upper":            strings.ToUpper,
+	"backslashify":     backslashify,
+	"escapeTroff":      EscapeTroff,
+	"dashify":          dashify,
+	"underscoreify":    underscoreify,
+	"simpleToTroff":    simpleToTroff,
+	"simpleToMdoc":     simpleToMdoc,
+	"simpleToMarkdown": simpleToMarkdown,
+	"markdownToTroff":  markdownToTroff,
+	"markdownToMdoc":   markdownToMdoc,
+	"inlineTroff":      markdownInlineToTroff,
+	"inlineMdoc":       markdownInlineToMdoc,
+	"troffLiteral":     troffLiteral,
+	"makeline":         makeline,
+	"trim":             strings.TrimSpace,
+	"trimRightSpace":   trimRightSpace,
+	"rpad":             rpad,
+	"flagList":         flagList,
+	"tableCell":        tableCell,
+	"markdownCell":     markdownCell,
+	"escapeMarkdown":   escapeMarkdown,
+	"repeat":           strings.Repeat,
 }
 
 // AddTemplateFunc adds a template function that's available to doc templates.
@@ -66,7 +112,212 @@ func RegisterTemplate(name string, separator string, extension string, templateS
 	templateMap[name] = t
 }
 
+// loadedTemplates are the templates of name with the ones of
+// Options.TemplateFile and Options.TemplateDir.
+type loadedTemplates struct {
+	name      string
+	templates manTemplate
+}
+
+// loadTemplates reads the templates of Options.TemplateFile,
+// Options.TemplateDir and Options.TemplateFS replacing the registered ones
+// of name, if any.
+func loadTemplates(opts *Options, name string) error {
+	opts.templates = nil
+	if opts.TemplateFile == "" && opts.TemplateDir == "" && opts.TemplateFS == nil {
+		return nil
+	}
+	t := templateMap[name]
+	files := []struct {
+		file     string
+		tmplName string
+		tmpl     **template.Template
+	}{
+		{name + ".tmpl", name, &t.template},
+		{name + "-index.tmpl", name + "-index", &t.index},
+		{name + "-single.tmpl", name + "-single", &t.single},
+	}
+	for i, f := range files {
+		data, err := readTemplate(opts, f.file, i == 0)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		parsed, err := template.New(f.tmplName).Funcs(templateFuncs).Parse(string(data))
+		if err != nil {
+			return err
+		}
+		*f.tmpl = parsed
+	}
+	opts.templates = &loadedTemplates{name: name, templates: t}
+	return nil
+}
+
+// readTemplate returns the content of the template file named file, nil if
+// there is none.  page is set for the page template, which
+// Options.TemplateFile replaces.
+func readTemplate(opts *Options, file string, page bool) ([]byte, error) {
+	if filename := templateFilename(opts, file, page); filename != "" {
+		return os.ReadFile(filename)
+	}
+	if opts.TemplateFS == nil {
+		return nil, nil
+	}
+	data, err := fs.ReadFile(opts.TemplateFS, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// templateFilename returns the file of Options.TemplateDir named file,
+// Options.TemplateFile instead for the page template, or "" if there is
+// none.
+func templateFilename(opts *Options, file string, page bool) string {
+	if page && opts.TemplateFile != "" {
+		return opts.TemplateFile
+	}
+	if opts.TemplateDir == "" {
+		return ""
+	}
+	filename := filepath.Join(opts.TemplateDir, file)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return ""
+	}
+	return filename
+}
+
+// templateOf returns the templates of name, with the ones loaded by
+// loadTemplates.
+func (opts *Options) templateOf(name string) manTemplate {
+	if opts.templates != nil && opts.templates.name == name {
+		return opts.templates.templates
+	}
+	return templateMap[name]
+}
+
+// RegisterGenerator registers a format written by generate instead of a
+// template, with a separator and file extension used to name the files like
+// RegisterTemplate.  It can then be used wherever a template name is, like
+// with GenerateDocs.
+func RegisterGenerator(name string, separator string, extension string, generate GeneratorFunc) {
+	RegisterFormat(name, funcGenerator{separator: separator, extension: extension, generate: generate})
+}
+
+// Generator writes the documentation of a command in one format.  The
+// registered templates and generators, including the built-in troff, mdoc
+// and markdown ones, are available as a Generator from GetGenerator so they
+// can be called from tests or wrapped, and RegisterFormat registers any
+// other implementation.
+type Generator interface {
+	// Render writes the page of cmd to w.
+	Render(ctx context.Context, cmd *cobra.Command, opts *Options, w io.Writer) error
+
+	// Separator joins the words of the command path in the file names of
+	// the pages, like the separator given to RegisterTemplate.
+	Separator() string
+
+	// Extension is the file extension of the pages, without the dot, or
+	// "use_section" for man pages named after Options.Section.
+	Extension() string
+}
+
+// registeredGenerator is the Generator of a registered format.
+type registeredGenerator struct {
+	name string
+}
+
+// GetGenerator returns the Generator of the format registered as name, nil
+// if there is none.
+func GetGenerator(name string) Generator {
+	if _, ok := templateMap[name]; !ok {
+		return nil
+	}
+	return registeredGenerator{name: name}
+}
+
+// Render writes the page of cmd like GenerateOnePage.  opts is not
+// modified, so a wrapping Generator can be used with Options.Jobs.
+func (g registeredGenerator) Render(ctx context.Context, cmd *cobra.Command, opts *Options, w io.Writer) error {
+	o := *opts
+	return GenerateOnePageContext(ctx, cmd, &o, g.name, w)
+}
+
+func (g registeredGenerator) Separator() string { return templateMap[g.name].separator }
+
+func (g registeredGenerator) Extension() string { return templateMap[g.name].extension }
+
+// RegisterFormat registers g as the format name, which can then be used
+// wherever a template name is, like with GenerateDocs.  The files are named
+// with its separator and extension like the pages of templates, and
+// rendered with the context given to GenerateDocsContext, a background one
+// otherwise.
+func RegisterFormat(name string, g Generator) {
+	templateMap[name] = manTemplate{
+		separator: g.Separator(),
+		extension: g.Extension(),
+		generator: g,
+	}
+}
+
 func getTemplate(name string) (sep string, ext string, tmpl *template.Template) {
 	t := templateMap[name]
 	return t.separator, t.extension, t.template
 }
+
+// executeTemplate runs t with data and writes the result to w.  ext is the
+// file extension of the template.  Markdown output is cleaned up with
+// lintMarkdown when Options.MarkdownLint is set, lines are wrapped with
+// Options.WrapLines, the non-ASCII characters of man pages are escaped
+// unless Options.UTF8 is set and the provenance of Options.Provenance is
+// added.
+func executeTemplate(t *template.Template, data interface{}, ext string, opts *Options, w io.Writer) error {
+	filters := outputFilters(ext, opts)
+	if len(filters) == 0 {
+		return t.Execute(w, data)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return err
+	}
+	out := buf.String()
+	for _, filter := range filters {
+		out = filter(out)
+	}
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// outputFilters returns the functions executeTemplate passes the output of
+// a template with the file extension ext through.
+func outputFilters(ext string, opts *Options) []func(string) string {
+	width := opts.LineWidth
+	if width <= 0 {
+		width = defaultLineWidth
+	}
+
+	var filters []func(string) string
+	switch ext {
+	case "md":
+		if opts.MarkdownLint {
+			filters = append(filters, func(out string) string { return lintMarkdown(out, opts.MarkdownLineLength) })
+		}
+		if opts.WrapLines {
+			filters = append(filters, func(out string) string { return wrapMarkdown(out, width) })
+		}
+	case "use_section":
+		if opts.WrapLines {
+			filters = append(filters, func(out string) string { return wrapTroff(out, width) })
+		}
+		if !opts.UTF8 {
+			filters = append(filters, escapeNonASCII)
+		}
+	}
+	if opts.provenance != "" {
+		filters = append(filters, func(out string) string { return stampProvenance(out, ext, opts) })
+	}
+	return filters
+}
@@ -15,10 +15,18 @@ package cobraman
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"text/template"
 
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,3 +70,163 @@ func TestAddTemplateFunc(t *testing.T) {
 	assert.Regexp(t, "hello world!", buf.String())
 	assert.Regexp(t, "xxxxx", buf.String())
 }
+
+func TestTemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "page.tmpl"), []byte("Page of {{ .CommandPath }}"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "markdown.tmpl"), []byte("Dir page of {{ .CommandPath }}"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "markdown-index.tmpl"), []byte("Dir index of {{ .CommandPath }}"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.tmpl"), []byte("{{ .CommandPath "), 0o644))
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, GenerateOnePage(cmd, &Options{TemplateFile: filepath.Join(dir, "page.tmpl")}, "troff", buf))
+	assert.Equal(t, "Page of foo", buf.String())
+
+	files, err := RenderDocs(cmd, &Options{TemplateDir: dir, IndexFile: "index.md"}, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, "Dir page of foo bar", string(files["foo_bar.md"]))
+	assert.Equal(t, "Dir index of foo", string(files["index.md"]))
+
+	files, err = RenderDocs(cmd, &Options{TemplateDir: dir, TemplateFile: filepath.Join(dir, "page.tmpl")}, "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, "Page of foo", string(files["foo.md"]))
+
+	files, err = RenderDocs(cmd, &Options{TemplateDir: dir}, "troff")
+	assert.NoError(t, err)
+	assert.Contains(t, string(files["foo.1"]), ".TH")
+
+	_, err = RenderDocs(cmd, &Options{TemplateFile: filepath.Join(dir, "bad.tmpl")}, "troff")
+	assert.Error(t, err)
+	_, err = RenderDocs(cmd, &Options{TemplateFile: filepath.Join(dir, "missing.tmpl")}, "troff")
+	assert.Error(t, err)
+}
+
+func TestTemplateFS(t *testing.T) {
+	templates := fstest.MapFS{
+		"markdown.tmpl":       {Data: []byte("FS page of {{ .CommandPath }}")},
+		"markdown-index.tmpl": {Data: []byte("FS index of {{ .CommandPath }}")},
+	}
+	cmd := &cobra.Command{Use: "foo"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, NewOptions(WithTemplateFS(templates), WithIndexFile("index.md")), "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, "FS page of foo bar", string(files["foo_bar.md"]))
+	assert.Equal(t, "FS index of foo", string(files["index.md"]))
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "markdown.tmpl"), []byte("Dir page of {{ .CommandPath }}"), 0o644))
+	files, err = RenderDocs(cmd, NewOptions(WithTemplateFS(templates), WithTemplateDir(dir), WithIndexFile("index.md")), "markdown")
+	assert.NoError(t, err)
+	assert.Equal(t, "Dir page of foo bar", string(files["foo_bar.md"]))
+	assert.Equal(t, "FS index of foo", string(files["index.md"]))
+
+	_, err = RenderDocs(cmd, NewOptions(WithTemplateFS(fstest.MapFS{"troff.tmpl": {Data: []byte("{{ .CommandPath ")}})), "troff")
+	assert.Error(t, err)
+}
+
+// bannerGenerator wraps a Generator, writing a banner before its pages and
+// joining the words of the file names with "-".
+type bannerGenerator struct {
+	Generator
+}
+
+func (g bannerGenerator) Render(ctx context.Context, cmd *cobra.Command, opts *Options, w io.Writer) error {
+	if _, err := io.WriteString(w, "<!-- banner -->\n"); err != nil {
+		return err
+	}
+	return g.Generator.Render(ctx, cmd, opts, w)
+}
+
+func (g bannerGenerator) Separator() string {
+	return "-"
+}
+
+func TestGetGenerator(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo", Short: "Foo it"}
+	bar := &cobra.Command{Use: "bar", Short: "Bar it", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd.AddCommand(bar)
+
+	assert.Nil(t, GetGenerator("no-such-format"))
+	g := GetGenerator("troff")
+	assert.Equal(t, "-", g.Separator())
+	assert.Equal(t, "use_section", g.Extension())
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, g.Render(context.Background(), bar, &Options{OmitDate: true}, buf))
+	want := new(bytes.Buffer)
+	assert.NoError(t, GenerateOnePage(bar, &Options{OmitDate: true}, "troff", want))
+	assert.Equal(t, want.String(), buf.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, g.Render(ctx, bar, &Options{}, io.Discard), context.Canceled)
+
+	RegisterFormat("banner", bannerGenerator{GetGenerator("markdown")})
+	files, err := RenderDocs(cmd, &Options{}, "banner")
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.True(t, strings.HasPrefix(string(files["foo-bar.md"]), "<!-- banner -->\n## foo bar"), string(files["foo-bar.md"]))
+	assert.Contains(t, string(files["foo.md"]), "<!-- banner -->\n")
+}
+
+// contextKey is the key of the value the contexts of the tests carry.
+type contextKey struct{}
+
+// contextGenerator writes the value its context carries.
+type contextGenerator struct {
+	Generator
+}
+
+func (g contextGenerator) Render(ctx context.Context, cmd *cobra.Command, opts *Options, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%v\n", ctx.Value(contextKey{}))
+	return err
+}
+
+func TestGenerateDocsContext(t *testing.T) {
+	RegisterFormat("context", contextGenerator{GetGenerator("markdown")})
+	cmd := &cobra.Command{Use: "foo", Short: "Foo it"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Short: "Bar it", Run: func(cmd *cobra.Command, args []string) {}})
+	ctx := context.WithValue(context.Background(), contextKey{}, "from ctx")
+
+	opts := &Options{Fs: afero.NewMemMapFs()}
+	assert.NoError(t, GenerateDocsContext(ctx, cmd, opts, "docs", "context"))
+	content, err := afero.ReadFile(opts.Fs, "docs/foo_bar.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "from ctx\n", string(content))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, GenerateOnePageContext(ctx, cmd, &Options{}, "context", buf))
+	assert.Equal(t, "from ctx\n", buf.String())
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = GenerateDocsContext(canceled, cmd, &Options{Fs: afero.NewMemMapFs()}, "docs", "markdown")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRegisterGenerator(t *testing.T) {
+	RegisterGenerator("plain", "-", "txt", func(cmd *cobra.Command, opts *Options, w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%s: %s\n", cmd.CommandPath(), cmd.Short)
+		return err
+	})
+	RegisterSingleFileTemplate("plain", "{{ range .Pages }}{{ . }}\n{{ end }}")
+	cmd := &cobra.Command{Use: "foo", Short: "Foo it"}
+	cmd.AddCommand(&cobra.Command{Use: "bar", Short: "Bar it", Run: func(cmd *cobra.Command, args []string) {}})
+
+	files, err := RenderDocs(cmd, &Options{}, "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"foo.txt": []byte("foo: Foo it\n"), "foo-bar.txt": []byte("foo bar: Bar it\n")}, files)
+
+	files, err = RenderDocs(cmd, &Options{SingleFile: "all.txt"}, "plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo: Foo it\nfoo bar: Bar it\n", string(files["all.txt"]))
+
+	RegisterGenerator("broken", "-", "txt", func(cmd *cobra.Command, opts *Options, w io.Writer) error {
+		return errors.New("broken")
+	})
+	_, err = RenderDocs(cmd, &Options{}, "broken")
+	assert.EqualError(t, err, "broken")
+}
@@ -14,32 +14,566 @@
 package cobraman
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// ErrNoGenerator is returned by the subcommands of the doc tool that work
+// on every generator when none was added with AddDocGenerator.
+var ErrNoGenerator = errors.New("no generator added with AddDocGenerator")
+
+// ErrNoFormat is returned by the generate subcommand when neither --format
+// nor --all is given and the config file lists no templates.
+var ErrNoFormat = errors.New("no format given")
+
+// ErrUnknownFormat is returned by the generate subcommand for a format that
+// is not registered.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ErrInvalidDocs is returned by the validate subcommand when ValidateDocs
+// finds problems in the docs.
+var ErrInvalidDocs = errors.New("problem(s) found")
+
+// ErrDocsOutOfDate is returned by the diff subcommand when the docs on disk
+// differ from the ones the templates generate.
+var ErrDocsOutOfDate = errors.New("docs out of date")
+
+// ErrNoVersion is returned by the archive subcommand when neither
+// --version is given nor the application has a version.
+var ErrNoVersion = errors.New("the application has no version")
+
 // DocGenTool is an opaque type created by CreateDocGenCmdLineTool.
 type DocGenTool struct {
 	installDirectory string
+	dryRun           bool
+	prune            bool
+	force            bool
+	page             string
+	only             string
+	maxDepth         int
+	formats          []string
+	all              bool
+	generators       map[string]*Options
+	defaults         *Options
+	directories      map[string]string
+	registered       []string
+	config           ToolConfig
+	configFile       string
+	verbose          bool
+	progress         bool
+	quiet            bool
+	jobs             int
+	continueOnError  bool
+	templateFile     string
+	templateDir      string
+	includeHidden    bool
+	strict           bool
+	minCoverage      float64
+	watch            bool
+	watchPaths       []string
+	addr             string
+	prefix           string
+	destDir          string
+	version          string
+	zip              bool
+	commands         *commandCache
+	fileConfig       toolFileConfig
 	docCmd           *cobra.Command
 	appCmd           *cobra.Command
 }
 
+// ToolConfig renames the subcommands and flags of the doc tool to avoid
+// collisions or follow house conventions.  Empty fields keep the default
+// names given in their comments.
+type ToolConfig struct {
+	// Use is the name of the tool, "doc".
+	Use string
+
+	// GenerateCommand is the name of the subcommand taking --format,
+	// "generate".
+	GenerateCommand string
+
+	// GeneratorPrefix is put before the template name to name the
+	// subcommands added with AddDocGenerator, "generate-".
+	GeneratorPrefix string
+
+	// GeneratorNames maps template names to the names of their subcommands,
+	// like "troff" to "man", replacing GeneratorPrefix for them.
+	GeneratorNames map[string]string
+
+	// ArchivePrefix is put before the template name to name the
+	// subcommands added with AddArchiveGenerator, "archive-".
+	ArchivePrefix string
+
+	// CompletionCommand is the name of the subcommand added with
+	// AddBashCompletionGenerator, "generate-auto-complete".
+	CompletionCommand string
+
+	// ListCommand is the name of the subcommand listing the files of the
+	// generators, "list".
+	ListCommand string
+
+	// ValidateCommand is the name of the subcommand checking the files of
+	// the generators, "validate".
+	ValidateCommand string
+
+	// DiffCommand is the name of the subcommand comparing the files of the
+	// generators with the ones in their directory, "diff".
+	DiffCommand string
+
+	// ServeCommand is the name of the subcommand serving an HTML preview of
+	// the docs, "serve".
+	ServeCommand string
+
+	// InstallCommand is the name of the subcommand installing the man pages
+	// and completion scripts, "install".
+	InstallCommand string
+
+	// UninstallCommand is the name of the subcommand removing the files
+	// written by install, "uninstall".
+	UninstallCommand string
+
+	// CleanCommand is the name of the subcommand removing the generated
+	// files from the directories of the generators, "clean".
+	CleanCommand string
+
+	// ShellCompletionCommand is the name of the subcommand writing the
+	// completion scripts of the application for every shell, "completion".
+	ShellCompletionCommand string
+
+	// ArchiveCommand is the name of the subcommand packing the docs of all
+	// the generators into one archive, "archive".
+	ArchiveCommand string
+
+	// PreviewCommand is the name of the subcommand showing the man page of
+	// one command, "preview".
+	PreviewCommand string
+
+	// CoverageCommand is the name of the subcommand reporting how
+	// completely the commands are documented, "coverage".
+	CoverageCommand string
+
+	// WatchCommand is run, with the subcommand, flags and arguments given
+	// to the tool but the watch flags, each time a watched file changes,
+	// like {"go", "run", "./doc"} to rebuild the tool so changes to help
+	// texts and templates show up.  By default the subcommand is only run
+	// again in the running tool.
+	WatchCommand []string
+
+	// Flag names, without the leading dashes.
+	DirectoryFlag     string // "directory"
+	DryRunFlag        string // "dry-run"
+	PruneFlag         string // "prune"
+	ForceFlag         string // "force"
+	OnlyFlag          string // "only"
+	MaxDepthFlag      string // "max-depth"
+	PageFlag          string // "page"
+	FormatFlag        string // "format"
+	AllFlag           string // "all"
+	ConfigFlag        string // "config"
+	VerboseFlag       string // "verbose"
+	QuietFlag         string // "quiet"
+	ProgressFlag      string // "progress"
+	JobsFlag          string // "jobs"
+	KeepGoingFlag     string // "keep-going"
+	TemplateFlag      string // "template"
+	TemplateDirFlag   string // "template-dir"
+	IncludeHiddenFlag string // "include-hidden"
+	StrictFlag        string // "strict"
+	MinCoverageFlag   string // "min-coverage"
+	WatchFlag         string // "watch"
+	WatchPathFlag     string // "watch-path"
+	AddrFlag          string // "addr"
+	PrefixFlag        string // "prefix"
+	DestDirFlag       string // "destdir"
+	VersionFlag       string // "version"
+	ZipFlag           string // "zip"
+}
+
+// toolFileConfig is the content of the configuration file of the doc tool.
+type toolFileConfig struct {
+	// Author, Bugs, Files and Environment replace the Options fields.
+	Author      string
+	Bugs        string
+	Files       string
+	Environment string
+
+	// Templates are used by the generate subcommand when no format is
+	// given.
+	Templates []string
+
+	// Directories maps template names to the directory their files are
+	// written to when no directory is given.
+	Directories map[string]string
+}
+
+// withDefaults returns c with the empty names set to their default.
+func (c ToolConfig) withDefaults() ToolConfig {
+	for _, name := range []struct {
+		field *string
+		value string
+	}{
+		{&c.Use, "doc"},
+		{&c.GenerateCommand, "generate"},
+		{&c.GeneratorPrefix, "generate-"},
+		{&c.ArchivePrefix, "archive-"},
+		{&c.CompletionCommand, "generate-auto-complete"},
+		{&c.ListCommand, "list"},
+		{&c.ValidateCommand, "validate"},
+		{&c.DiffCommand, "diff"},
+		{&c.ServeCommand, "serve"},
+		{&c.PreviewCommand, "preview"},
+		{&c.CoverageCommand, "coverage"},
+		{&c.InstallCommand, "install"},
+		{&c.UninstallCommand, "uninstall"},
+		{&c.CleanCommand, "clean"},
+		{&c.ShellCompletionCommand, "completion"},
+		{&c.ArchiveCommand, "archive"},
+		{&c.DirectoryFlag, "directory"},
+		{&c.DryRunFlag, "dry-run"},
+		{&c.PruneFlag, "prune"},
+		{&c.ForceFlag, "force"},
+		{&c.OnlyFlag, "only"},
+		{&c.MaxDepthFlag, "max-depth"},
+		{&c.PageFlag, "page"},
+		{&c.FormatFlag, "format"},
+		{&c.AllFlag, "all"},
+		{&c.ConfigFlag, "config"},
+		{&c.VerboseFlag, "verbose"},
+		{&c.QuietFlag, "quiet"},
+		{&c.ProgressFlag, "progress"},
+		{&c.JobsFlag, "jobs"},
+		{&c.KeepGoingFlag, "keep-going"},
+		{&c.TemplateFlag, "template"},
+		{&c.TemplateDirFlag, "template-dir"},
+		{&c.IncludeHiddenFlag, "include-hidden"},
+		{&c.StrictFlag, "strict"},
+		{&c.MinCoverageFlag, "min-coverage"},
+		{&c.WatchFlag, "watch"},
+		{&c.WatchPathFlag, "watch-path"},
+		{&c.AddrFlag, "addr"},
+		{&c.PrefixFlag, "prefix"},
+		{&c.DestDirFlag, "destdir"},
+		{&c.VersionFlag, "version"},
+		{&c.ZipFlag, "zip"},
+	} {
+		if *name.field == "" {
+			*name.field = name.value
+		}
+	}
+	return c
+}
+
 // CreateDocGenCmdLineTool creates a command line parser that can be used
 // in a utility tool to generate documentation for a companion application.
+// Its generate subcommand takes the templates to use at run time with a
+// --format flag, like --format troff,markdown, with the Options given to
+// AddDocGenerator for them or the default Options.  With --all it uses
+// every template added with AddDocGenerator.
+//
+// The tool reads the optional cobraman.yaml configuration file, or the file
+// given with --config, for the Author, Bugs, Files and Environment Options,
+// the templates generate uses when no --format is given and a directory per
+// template:
+//
+//	author: Jane Doe <jane@example.com>
+//	bugs: File bugs at https://example.com/issues
+//	templates: [troff, markdown]
+//	directories:
+//	  troff: doc/man
+//	  markdown: doc/md
 func CreateDocGenCmdLineTool(appCmd *cobra.Command) *DocGenTool {
+	return CreateDocGenCmdLineToolWithConfig(appCmd, ToolConfig{})
+}
+
+// newDocGenTool returns the doc tool of appCmd with the command generating
+// the docs and its flags, but without subcommands.
+func newDocGenTool(appCmd *cobra.Command, config ToolConfig) *DocGenTool {
 	dg := &DocGenTool{
-		appCmd: appCmd,
+		appCmd:      appCmd,
+		generators:  make(map[string]*Options),
+		directories: make(map[string]string),
+		config:      config.withDefaults(),
 	}
+	config = dg.config
 
 	dg.docCmd = &cobra.Command{
-		Use:   "doc",
+		Use:   config.Use,
 		Args:  cobra.NoArgs,
 		Short: "Generate documentation, etc.",
+		PersistentPreRunE: func(myCmd *cobra.Command, args []string) error {
+			if err := parentPreRun(dg.docCmd, myCmd, args); err != nil {
+				return err
+			}
+			// The formats generated in this run share the data of the commands.
+			dg.commands = newCommandCache()
+			return dg.readConfigFile(myCmd.Flags().Changed(config.ConfigFlag))
+		},
+	}
+	flags := dg.docCmd.PersistentFlags()
+	flags.StringVar(&dg.configFile, config.ConfigFlag, "cobraman.yaml",
+		"Configuration file with the author, bugs, files, environment, templates and directories")
+	flags.StringVar(&dg.installDirectory, config.DirectoryFlag, ".", "Directory to install generated files")
+	flags.BoolVar(&dg.dryRun, config.DryRunFlag, false, "Report the files that would be generated without writing them")
+	flags.BoolVar(&dg.prune, config.PruneFlag, false, "Remove generated files of commands that no longer exist")
+	flags.BoolVar(&dg.force, config.ForceFlag, false, "Overwrite files that were not generated")
+	flags.StringVar(&dg.only, config.OnlyFlag, "", "Generate only the pages of this command path (e.g. \"sub cmd\") and its children")
+	flags.IntVar(&dg.maxDepth, config.MaxDepthFlag, 0, "Levels of commands to generate pages for, 0 for all")
+	flags.BoolVar(&dg.verbose, config.VerboseFlag, false, "Report the files written and the commands skipped")
+	flags.BoolVar(&dg.quiet, config.QuietFlag, false, "Report only errors")
+	dg.docCmd.MarkFlagsMutuallyExclusive(config.VerboseFlag, config.QuietFlag)
+	flags.BoolVar(&dg.progress, config.ProgressFlag, false, "Show the progress of the generation")
+	flags.IntVar(&dg.jobs, config.JobsFlag, 0, "Number of pages to generate concurrently")
+	flags.BoolVar(&dg.continueOnError, config.KeepGoingFlag, false, "Go on after pages that fail and report all the failures")
+	flags.StringVar(&dg.templateFile, config.TemplateFlag, "", "Template file replacing the page template of the format")
+	flags.StringVar(&dg.templateDir, config.TemplateDirFlag, "",
+		"Directory of <format>.tmpl, <format>-index.tmpl and <format>-single.tmpl templates replacing the built-in ones")
+	flags.BoolVar(&dg.includeHidden, config.IncludeHiddenFlag, false, "Document hidden commands and flags too")
+	flags.BoolVar(&dg.strict, config.StrictFlag, false, "Fail when commands lack a short or long description or flags lack a usage")
+	flags.StringVar(&dg.page, config.PageFlag, "", "Write only the page of this command path (e.g. \"sub cmd\") to standard output")
+
+	return dg
+}
+
+// parentPreRun runs the persistent pre-run hook of the closest parent of
+// cmd that has one.  cobra only runs the closest hook, so the one of cmd
+// would otherwise keep the one of the application from running when the
+// doc tool is part of it.
+func parentPreRun(cmd *cobra.Command, myCmd *cobra.Command, args []string) error {
+	for p := cmd.Parent(); p != nil; p = p.Parent() {
+		if p.PersistentPreRunE != nil {
+			return p.PersistentPreRunE(myCmd, args)
+		}
+		if p.PersistentPreRun != nil {
+			p.PersistentPreRun(myCmd, args)
+			return nil
+		}
+	}
+	return nil
+}
+
+// CreateDocGenCmdLineToolWithConfig is CreateDocGenCmdLineTool with the
+// subcommands and flags named after config.
+func CreateDocGenCmdLineToolWithConfig(appCmd *cobra.Command, config ToolConfig) *DocGenTool {
+	dg := newDocGenTool(appCmd, config)
+	dg.addGenerateCommand()
+	dg.addCheckCommands()
+	dg.addPreviewCommands()
+	dg.addInstallCommands()
+	dg.addPackageCommands()
+	return dg
+}
+
+// addGenerateCommand adds the subcommand generating the docs of the formats
+// given with --format, or of all the generators with --all.
+func (dg *DocGenTool) addGenerateCommand() {
+	config := dg.config
+	generateCmd := &cobra.Command{
+		Use:   config.GenerateCommand,
+		Args:  cobra.NoArgs,
+		Short: "Generate docs with the templates given with --" + config.FormatFlag,
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			if dg.all {
+				return dg.generateAll(myCmd)
+			}
+			formats := dg.formats
+			if len(formats) == 0 {
+				formats = dg.fileConfig.Templates
+			}
+			if len(formats) == 0 {
+				return fmt.Errorf("%w, use --%s or --%s", ErrNoFormat, config.FormatFlag, config.AllFlag)
+			}
+			for _, format := range formats {
+				if _, ok := templateMap[format]; !ok {
+					return fmt.Errorf("%w %q", ErrUnknownFormat, format)
+				}
+			}
+			for _, format := range formats {
+				opts := dg.generators[format]
+				if opts == nil {
+					opts = &Options{}
+				}
+				if err := dg.generate(myCmd, opts, format); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	generateCmd.Flags().StringSliceVar(&dg.formats, config.FormatFlag, nil,
+		"Comma separated templates to generate docs with (e.g. troff,markdown)")
+	generateCmd.Flags().BoolVar(&dg.all, config.AllFlag, false, "Generate docs with every template added with AddDocGenerator")
+	generateCmd.MarkFlagsMutuallyExclusive(config.FormatFlag, config.AllFlag)
+	dg.addWatchedCommand(generateCmd)
+}
+
+// addCheckCommands adds the subcommands listing, checking and comparing the
+// docs of the generators, and reporting their coverage.
+func (dg *DocGenTool) addCheckCommands() {
+	config := dg.config
+	dg.docCmd.AddCommand(&cobra.Command{
+		Use:   config.ListCommand,
+		Args:  cobra.NoArgs,
+		Short: "List the files every generator would write, one per line",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.listAll(myCmd)
+		},
+	})
+
+	dg.docCmd.AddCommand(&cobra.Command{
+		Use:   config.ValidateCommand,
+		Args:  cobra.NoArgs,
+		Short: "Check the docs of every generator for problems",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.validateAll(myCmd)
+		},
+	})
+
+	dg.docCmd.AddCommand(&cobra.Command{
+		Use:   config.DiffCommand,
+		Args:  cobra.NoArgs,
+		Short: "Show how the docs of every generator differ from the ones in their directory",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.diffAll(myCmd)
+		},
+	})
+
+	coverageCmd := &cobra.Command{
+		Use:   config.CoverageCommand,
+		Args:  cobra.NoArgs,
+		Short: "Report which commands have long descriptions, examples, argument and environment docs",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.coverage(myCmd)
+		},
+	}
+	coverageCmd.Flags().Float64Var(&dg.minCoverage, config.MinCoverageFlag, 0, "Fail when the coverage percentage is below this")
+	dg.docCmd.AddCommand(coverageCmd)
+}
+
+// addPreviewCommands adds the subcommands serving and showing the docs.
+func (dg *DocGenTool) addPreviewCommands() {
+	config := dg.config
+	serveCmd := &cobra.Command{
+		Use:   config.ServeCommand,
+		Args:  cobra.NoArgs,
+		Short: "Serve an HTML preview of the docs until interrupted",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.serve(myCmd)
+		},
+	}
+	serveCmd.Flags().StringVar(&dg.addr, config.AddrFlag, "localhost:8080", "Address to serve the preview on")
+	dg.docCmd.AddCommand(serveCmd)
+
+	dg.docCmd.AddCommand(&cobra.Command{
+		Use:   config.PreviewCommand + " [command path]",
+		Short: "Show the man page of a command through man, or rendered as text without it",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.preview(myCmd, args)
+		},
+	})
+}
+
+// addInstallCommands adds the subcommands installing and uninstalling the
+// man pages and completion scripts.
+func (dg *DocGenTool) addInstallCommands() {
+	config := dg.config
+	installCmd := &cobra.Command{
+		Use:   config.InstallCommand,
+		Args:  cobra.NoArgs,
+		Short: "Install the man pages and completion scripts",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.install(myCmd)
+		},
+	}
+	uninstallCmd := &cobra.Command{
+		Use:   config.UninstallCommand,
+		Args:  cobra.NoArgs,
+		Short: "Remove the files written by " + config.InstallCommand,
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.uninstall(myCmd)
+		},
+	}
+	for _, c := range []*cobra.Command{installCmd, uninstallCmd} {
+		c.Flags().StringVar(&dg.prefix, config.PrefixFlag, "/usr/local", "Installation prefix")
+		c.Flags().StringVar(&dg.destDir, config.DestDirFlag, os.Getenv("DESTDIR"), "Staging directory put before the prefix, for packaging")
+		dg.docCmd.AddCommand(c)
 	}
-	dg.docCmd.PersistentFlags().StringVar(&dg.installDirectory, "directory", ".", "Directory to install generated files")
+}
 
+// addPackageCommands adds the subcommands writing the completion scripts
+// and the archive of the docs, and removing the generated files.
+func (dg *DocGenTool) addPackageCommands() {
+	config := dg.config
+	dg.docCmd.AddCommand(&cobra.Command{
+		Use:       config.ShellCompletionCommand + " [bash|zsh|fish|powershell|all]",
+		Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: shellNames(),
+		Short:     "Generate the completion scripts of the application, for all shells by default",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			shell := "all"
+			if len(args) > 0 {
+				shell = args[0]
+			}
+			return dg.completion(myCmd, shell)
+		},
+	})
+
+	archiveCmd := &cobra.Command{
+		Use:   config.ArchiveCommand,
+		Args:  cobra.NoArgs,
+		Short: "Pack the docs of every generator into docs-<version>.tar.gz",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.archiveAll(myCmd)
+		},
+	}
+	archiveCmd.Flags().StringVar(&dg.version, config.VersionFlag, "",
+		"Version in the archive name, defaults to the version of the application")
+	archiveCmd.Flags().BoolVar(&dg.zip, config.ZipFlag, false, "Write a zip archive instead of a .tar.gz")
+	dg.docCmd.AddCommand(archiveCmd)
+
+	dg.docCmd.AddCommand(&cobra.Command{
+		Use:   config.CleanCommand,
+		Args:  cobra.NoArgs,
+		Short: "Remove the generated files from the directories of the generators",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			return dg.clean(myCmd)
+		},
+	})
+}
+
+// AttachDocGenCommand adds the doc tool to rootCmd as a hidden gen-docs
+// subcommand, so man pages can be regenerated from the installed
+// application without a separate program:
+//
+//	app gen-docs --directory /usr/share/man/man1
+//
+// gen-docs writes the troff man pages with opts.  It has none of the other
+// subcommands of the doc tool, like install or serve, which have no place
+// in the application; generators added to the returned DocGenTool get their
+// generate-<template> subcommand below it.  The persistent pre-run hook of
+// rootCmd still runs for gen-docs, and gen-docs gets no page itself.
+func AttachDocGenCommand(rootCmd *cobra.Command, opts *Options) *DocGenTool {
+	dg := newDocGenTool(rootCmd, ToolConfig{Use: "gen-docs"})
+	dg.docCmd.Hidden = true
+	dg.docCmd.Short = "Generate the documentation of " + rootCmd.Name()
+	dg.docCmd.RunE = func(myCmd *cobra.Command, args []string) error {
+		return dg.generate(myCmd, opts, "troff")
+	}
+	rootCmd.AddCommand(dg.docCmd)
 	return dg
 }
 
@@ -48,11 +582,15 @@ func CreateDocGenCmdLineTool(appCmd *cobra.Command) *DocGenTool {
 // support a --directory flag and use the fileName passed into this function.
 func (dg *DocGenTool) AddBashCompletionGenerator(fileName string) *DocGenTool {
 	completeCmd := &cobra.Command{
-		Use:   "generate-auto-complete",
+		Use:   dg.config.CompletionCommand,
 		Args:  cobra.NoArgs,
 		Short: "Generate bash auto complete script",
 		RunE: func(myCmd *cobra.Command, args []string) error {
 			path := filepath.Join(dg.installDirectory, fileName)
+			if dg.dryRun {
+				_, err := fmt.Fprintf(myCmd.OutOrStdout(), "would write %s\n", path)
+				return err
+			}
 			return dg.appCmd.GenBashCompletionFile(path)
 		},
 	}
@@ -64,9 +602,15 @@ func (dg *DocGenTool) AddBashCompletionGenerator(fileName string) *DocGenTool {
 
 // AddDocGenerator will create a subcommand for the utility tool that will
 // generate documentation with the passed in Options and templateName.
-// It supports a --directory flag for where to place the generated files.  The
-// subcommand will be named generate-<templateName> where templateName is the
-// same as the template used to generate the documentation.
+// It supports a --directory flag for where to place the generated files, a
+// --dry-run flag to only report them, a --prune flag to remove stale ones,
+// a --force flag to overwrite files Options.ProtectFiles protects, --only
+// and --max-depth flags to limit the commands and a --page flag to write
+// only the page of one command to standard output.
+// The subcommand will be named generate-<templateName>, unless ToolConfig
+// renames it, where templateName is the same as the template used to
+// generate the documentation.  The fields left zero in opts take their
+// value from the Options given to SetDefaults.
 func (dg *DocGenTool) AddDocGenerator(opts *Options, templateName string) *DocGenTool {
 	// Make sure template exists or we will later get runtime panic
 	_, ok := templateMap[templateName]
@@ -75,19 +619,513 @@ func (dg *DocGenTool) AddDocGenerator(opts *Options, templateName string) *DocGe
 	}
 
 	genCmd := &cobra.Command{
-		Use:   "generate-" + templateName,
+		Use:   dg.generatorName(templateName),
 		Args:  cobra.NoArgs,
 		Short: "Generate docs with the " + templateName + " template",
 		RunE: func(myCmd *cobra.Command, args []string) error {
-			return GenerateDocs(dg.appCmd, opts, dg.installDirectory, templateName)
+			return dg.generate(myCmd, opts, templateName)
 		},
 	}
 
-	dg.docCmd.AddCommand(genCmd)
+	dg.generators[templateName] = opts
+	dg.registered = append(dg.registered, templateName)
+	dg.addWatchedCommand(genCmd)
 
 	return dg
 }
 
+// AddCustomGenerator is AddDocGenerator for a format written by generate
+// instead of a template, like a corporate wiki format, so it gets the same
+// subcommands and flags as the built-in formats.  The format is registered
+// with RegisterGenerator under name, with "_" between the words of the file
+// names and name as the file extension unless Options.FileExtension is set.
+func (dg *DocGenTool) AddCustomGenerator(name string, opts *Options, generate GeneratorFunc) *DocGenTool {
+	RegisterGenerator(name, "_", name, generate)
+	return dg.AddDocGenerator(opts, name)
+}
+
+// AddDocGeneratorTo is AddDocGenerator writing the files of templateName
+// to directory, unless --directory or the configuration file gives another
+// one, so every generator can have its own directory:
+//
+//	dg.AddDocGeneratorTo(&cobraman.Options{Section: "8"}, "troff", "doc/man").
+//		AddDocGeneratorTo(&cobraman.Options{FileExtension: ".mdx"}, "markdown", "site/docs")
+func (dg *DocGenTool) AddDocGeneratorTo(opts *Options, templateName string, directory string) *DocGenTool {
+	dg.directories[templateName] = directory
+	return dg.AddDocGenerator(opts, templateName)
+}
+
+// SetDefaults sets the Options shared by the generators: the fields left
+// zero in the Options given to AddDocGenerator, or in the default Options
+// of the templates given with --format, take their value from opts.  A
+// boolean set in opts can thus not be turned off by one generator.
+func (dg *DocGenTool) SetDefaults(opts *Options) *DocGenTool {
+	dg.defaults = opts
+	return dg
+}
+
+// generateAll runs generate for every template added with AddDocGenerator,
+// in the order they were added.  It goes on after a failure and returns the
+// errors of all the templates that failed.
+func (dg *DocGenTool) generateAll(myCmd *cobra.Command) error {
+	if len(dg.registered) == 0 {
+		return ErrNoGenerator
+	}
+	var errs []error
+	for _, templateName := range dg.registered {
+		if err := dg.generate(myCmd, dg.generators[templateName], templateName); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", templateName, err))
+		}
+	}
+	return joinErrors(errs...)
+}
+
+// listAll writes the files every template added with AddDocGenerator
+// would write to the output of myCmd, one per line.  The files of each
+// template are sorted, the templates are in the order they were added.
+func (dg *DocGenTool) listAll(myCmd *cobra.Command) error {
+	if len(dg.registered) == 0 {
+		return ErrNoGenerator
+	}
+	for _, templateName := range dg.registered {
+		files, err := dg.list(myCmd, dg.generators[templateName], templateName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", templateName, err)
+		}
+		for _, file := range files {
+			if _, err := fmt.Fprintln(myCmd.OutOrStdout(), file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// list returns the sorted files generate would write for myCmd with opts
+// and templateName.  The pages are rendered in memory to get them.
+func (dg *DocGenTool) list(myCmd *cobra.Command, opts *Options, templateName string) ([]string, error) {
+	listOpts := *dg.flagOptions(dg.fileOptions(opts), myCmd)
+	listOpts.commands = dg.commands
+	rendered, err := RenderDocs(dg.appCmd, &listOpts, templateName)
+	if err != nil {
+		return nil, err
+	}
+	directory := dg.directory(myCmd, templateName)
+	files := make([]string, 0, len(rendered))
+	for name := range rendered {
+		files = append(files, filepath.Join(directory, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// validateAll runs ValidateDocs for every template added with
+// AddDocGenerator and writes the problems found to the output of myCmd,
+// one per line.  It returns an error if there are any.
+func (dg *DocGenTool) validateAll(myCmd *cobra.Command) error {
+	if len(dg.registered) == 0 {
+		return ErrNoGenerator
+	}
+	count := 0
+	for _, templateName := range dg.registered {
+		opts := *dg.flagOptions(dg.fileOptions(dg.generators[templateName]), myCmd)
+		opts.commands = dg.commands
+		problems, err := ValidateDocs(dg.appCmd, &opts, templateName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", templateName, err)
+		}
+		directory := dg.directory(myCmd, templateName)
+		for _, problem := range problems {
+			problem.File = filepath.Join(directory, problem.File)
+			if _, err := fmt.Fprintln(myCmd.OutOrStdout(), problem); err != nil {
+				return err
+			}
+		}
+		count += len(problems)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d %w", count, ErrInvalidDocs)
+	}
+	return nil
+}
+
+// diffAll runs DiffDocs for every template added with AddDocGenerator,
+// writing the diffs to the output of myCmd.  It returns an error if the
+// docs of any template are out of date.
+func (dg *DocGenTool) diffAll(myCmd *cobra.Command) error {
+	if len(dg.registered) == 0 {
+		return ErrNoGenerator
+	}
+	var outdated []string
+	for _, templateName := range dg.registered {
+		opts := *dg.flagOptions(dg.fileOptions(dg.generators[templateName]), myCmd)
+		opts.commands = dg.commands
+		differ, err := DiffDocs(dg.appCmd, &opts, dg.directory(myCmd, templateName), templateName, myCmd.OutOrStdout())
+		if err != nil {
+			return fmt.Errorf("%s: %w", templateName, err)
+		}
+		if differ {
+			outdated = append(outdated, templateName)
+		}
+	}
+	if len(outdated) > 0 {
+		return fmt.Errorf("%w for %s, run %s", ErrDocsOutOfDate, strings.Join(outdated, ", "), dg.config.GenerateCommand)
+	}
+	return nil
+}
+
+// serve serves PreviewHandler for the application, with the Options
+// given to AddDocGenerator for markdown if any, on the address given with
+// --addr until the context of myCmd is done.
+func (dg *DocGenTool) serve(myCmd *cobra.Command) error {
+	opts := dg.generators["markdown"]
+	if opts == nil {
+		opts = &Options{}
+	}
+	previewOpts := *dg.flagOptions(dg.fileOptions(opts), myCmd)
+	previewOpts.commands = dg.commands
+	handler, err := PreviewHandler(dg.appCmd, &previewOpts)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", dg.addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	ctx := cmdContext(myCmd)
+	if _, err := fmt.Fprintf(myCmd.ErrOrStderr(), "serving the docs on http://%s/\n", listener.Addr()); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	closed := make(chan error, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			closed <- server.Close()
+		case <-stop:
+		}
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-closed
+}
+
+// generatorName returns the name of the subcommand AddDocGenerator adds for
+// templateName.
+func (dg *DocGenTool) generatorName(templateName string) string {
+	if name := dg.config.GeneratorNames[templateName]; name != "" {
+		return name
+	}
+	return dg.config.GeneratorPrefix + templateName
+}
+
+// generate runs GenerateDocs, or GenerateOnePage with --page, for myCmd
+// with opts and templateName.
+func (dg *DocGenTool) generate(myCmd *cobra.Command, opts *Options, templateName string) error {
+	opts = dg.fileOptions(opts)
+	if dg.page != "" {
+		cmd, err := findCommand(dg.appCmd, dg.page)
+		if err != nil {
+			return err
+		}
+		pageOpts := *dg.flagOptions(opts, myCmd)
+		dg.skipDocCmd(&pageOpts)
+		return GenerateOnePageContext(cmdContext(myCmd), cmd, &pageOpts, templateName, myCmd.OutOrStdout())
+	}
+	genOpts := *dg.flagOptions(opts, myCmd)
+	dg.skipDocCmd(&genOpts)
+	genOpts.commands = dg.commands
+	return GenerateDocsContext(cmdContext(myCmd), dg.appCmd, &genOpts, dg.directory(myCmd, templateName), templateName)
+}
+
+// cmdContext returns the context of cmd, a background one when it was
+// not executed by cobra.
+func cmdContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// skipDocCmd makes opts skip the command of the doc tool when it is part of
+// the application, as AttachDocGenCommand makes it, even with
+// IncludeHidden.
+func (dg *DocGenTool) skipDocCmd(opts *Options) {
+	if !dg.docCmd.HasParent() {
+		return
+	}
+	filter := opts.Filter
+	opts.Filter = func(cmd *cobra.Command) bool {
+		return cmd != dg.docCmd && (filter == nil || filter(cmd))
+	}
+}
+
+// readConfigFile reads the configuration file given with --config.  A
+// missing file is only an error if the flag was given.
+func (dg *DocGenTool) readConfigFile(required bool) error {
+	dg.fileConfig = toolFileConfig{}
+	if _, err := os.Stat(dg.configFile); os.IsNotExist(err) && !required {
+		return nil
+	}
+	v := viper.New()
+	v.SetConfigFile(dg.configFile)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	return v.Unmarshal(&dg.fileConfig)
+}
+
+// fileOptions returns opts over the Options given to SetDefaults, changed
+// by the configuration file.  opts itself is returned when neither changes
+// anything.
+func (dg *DocGenTool) fileOptions(opts *Options) *Options {
+	if dg.defaults != nil {
+		opts = mergeOptions(dg.defaults, opts)
+	}
+	fc := dg.fileConfig
+	if fc.Author == "" && fc.Bugs == "" && fc.Files == "" && fc.Environment == "" {
+		return opts
+	}
+	fileOpts := *opts
+	for _, field := range []struct {
+		opt   *string
+		value string
+	}{
+		{&fileOpts.Author, fc.Author},
+		{&fileOpts.Bugs, fc.Bugs},
+		{&fileOpts.Files, fc.Files},
+		{&fileOpts.Environment, fc.Environment},
+	} {
+		if field.value != "" {
+			*field.opt = field.value
+		}
+	}
+	return &fileOpts
+}
+
+// directory returns where the files of templateName go: the --directory
+// flag if given, else the directory of the configuration file for
+// templateName, else the one given to AddDocGeneratorTo, else the default
+// of the flag.
+func (dg *DocGenTool) directory(myCmd *cobra.Command, templateName string) string {
+	if myCmd.Flags().Changed(dg.config.DirectoryFlag) {
+		return dg.installDirectory
+	}
+	if dir := dg.fileConfig.Directories[templateName]; dir != "" {
+		return dir
+	}
+	if dir := dg.directories[templateName]; dir != "" {
+		return dir
+	}
+	return dg.installDirectory
+}
+
+// mergeOptions returns a copy of opts with its zero exported fields set to
+// the ones of defaults.
+func mergeOptions(defaults *Options, opts *Options) *Options {
+	merged := *opts
+	from := reflect.ValueOf(defaults).Elem()
+	to := reflect.ValueOf(&merged).Elem()
+	for i := 0; i < to.NumField(); i++ {
+		if to.Type().Field(i).IsExported() && to.Field(i).IsZero() {
+			to.Field(i).Set(from.Field(i))
+		}
+	}
+	return &merged
+}
+
+// AddArchiveGenerator will create a subcommand for the utility tool that
+// will write the documentation generated with the passed in Options and
+// templateName to a .tar.gz archive named fileName, with GenerateArchive.
+// The subcommand will be named archive-<templateName>, unless ToolConfig
+// renames it, and supports the --directory and --dry-run flags.
+func (dg *DocGenTool) AddArchiveGenerator(opts *Options, templateName string, fileName string) *DocGenTool {
+	// Make sure template exists or we will later get runtime panic
+	_, ok := templateMap[templateName]
+	if !ok {
+		panic("the given template has not been registered: " + templateName)
+	}
+
+	archiveCmd := &cobra.Command{
+		Use:   dg.config.ArchivePrefix + templateName,
+		Args:  cobra.NoArgs,
+		Short: "Archive docs generated with the " + templateName + " template",
+		RunE: func(myCmd *cobra.Command, args []string) error {
+			// The archive is written like a page, in Options.Fs with its
+			// modes, but not in the manifest of the pages.
+			fileOpts := &Options{
+				Fs:           opts.Fs,
+				FileMode:     opts.FileMode,
+				DirMode:      opts.DirMode,
+				DryRun:       dg.dryRun,
+				DryRunOutput: myCmd.OutOrStdout(),
+			}
+			path := filepath.Join(dg.installDirectory, fileName)
+			return createFile(path, dg.appCmd.CommandPath(), fileOpts, func(w io.Writer) error {
+				return GenerateArchive(dg.appCmd, opts, templateName, w)
+			})
+		},
+	}
+
+	dg.docCmd.AddCommand(archiveCmd)
+
+	return dg
+}
+
+// archiveAll writes the docs of every template added with AddDocGenerator
+// to docs-<version>.tar.gz, or .zip with --zip, in the directory given with
+// --directory.  The files of a template are in docs-<version>/<template>/,
+// the pages of man page templates in man<section>/ below it.
+func (dg *DocGenTool) archiveAll(myCmd *cobra.Command) error {
+	if len(dg.registered) == 0 {
+		return ErrNoGenerator
+	}
+	version := dg.version
+	if version == "" {
+		version = dg.appCmd.Version
+	}
+	if version == "" {
+		return fmt.Errorf("%w, use --%s", ErrNoVersion, dg.config.VersionFlag)
+	}
+	base := "docs-" + version
+
+	files := make(map[string][]byte)
+	var date *time.Time
+	var fileOpts *Options
+	for _, templateName := range dg.registered {
+		opts := *dg.flagOptions(dg.fileOptions(dg.generators[templateName]), myCmd)
+		opts.commands = dg.commands
+		opts.SectionDirs = true
+		validate(&opts, templateName)
+		rendered, err := RenderDocs(dg.appCmd, &opts, templateName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", templateName, err)
+		}
+		for name, data := range rendered {
+			files[path.Join(base, templateName, filepath.ToSlash(name))] = data
+		}
+		if date == nil {
+			date = opts.Date
+		}
+		if fileOpts == nil {
+			// The archive is written like a page, in the Options.Fs of
+			// the first generator with its modes.
+			fileOpts = &Options{
+				Fs:           opts.Fs,
+				FileMode:     opts.FileMode,
+				DirMode:      opts.DirMode,
+				DryRun:       dg.dryRun,
+				DryRunOutput: myCmd.OutOrStdout(),
+			}
+		}
+	}
+
+	name, write := base+".tar.gz", writeTarGz
+	if dg.zip {
+		name, write = base+".zip", writeZip
+	}
+	return createFile(filepath.Join(dg.installDirectory, name), dg.appCmd.CommandPath(), fileOpts, func(w io.Writer) error {
+		return write(w, files, *date)
+	})
+}
+
+// optionFlag is a flag of the doc tool changing the Options.
+type optionFlag struct {
+	name  string
+	apply func(opts *Options)
+}
+
+// optionFlags returns the flags of the doc tool changing the Options, with
+// how they change them.
+func (dg *DocGenTool) optionFlags(myCmd *cobra.Command) []optionFlag {
+	config := dg.config
+	return []optionFlag{
+		{config.DryRunFlag, func(opts *Options) {
+			opts.DryRun = dg.dryRun
+			opts.DryRunOutput = myCmd.OutOrStdout()
+		}},
+		{config.PruneFlag, func(opts *Options) { opts.Prune = dg.prune }},
+		{config.ForceFlag, func(opts *Options) { opts.ProtectFiles = opts.ProtectFiles && !dg.force }},
+		{config.OnlyFlag, func(opts *Options) { opts.Only = dg.only }},
+		{config.MaxDepthFlag, func(opts *Options) { opts.MaxDepth = dg.maxDepth }},
+		{config.VerboseFlag, func(opts *Options) {
+			if dg.verbose {
+				opts.Logger = &textLogger{w: myCmd.ErrOrStderr()}
+			}
+		}},
+		{config.QuietFlag, func(opts *Options) {
+			if dg.quiet {
+				opts.Logger = discardLogger{}
+			}
+		}},
+		{config.ProgressFlag, func(opts *Options) {
+			if dg.progress {
+				opts.Progress = progressPrinter(myCmd.ErrOrStderr())
+			}
+		}},
+		{config.JobsFlag, func(opts *Options) { opts.Jobs = dg.jobs }},
+		{config.KeepGoingFlag, func(opts *Options) { opts.ContinueOnError = dg.continueOnError }},
+		{config.TemplateFlag, func(opts *Options) { opts.TemplateFile = dg.templateFile }},
+		{config.TemplateDirFlag, func(opts *Options) { opts.TemplateDir = dg.templateDir }},
+		{config.IncludeHiddenFlag, func(opts *Options) { opts.IncludeHidden = dg.includeHidden }},
+		{config.StrictFlag, func(opts *Options) { opts.Strict = dg.strict }},
+	}
+}
+
+// flagOptions returns opts changed by the flags of optionFlags given to
+// myCmd.  opts itself is returned when none is given.
+func (dg *DocGenTool) flagOptions(opts *Options, myCmd *cobra.Command) *Options {
+	var flagOpts *Options
+	for _, flag := range dg.optionFlags(myCmd) {
+		if !myCmd.Flags().Changed(flag.name) {
+			continue
+		}
+		if flagOpts == nil {
+			copied := *opts
+			flagOpts = &copied
+		}
+		flag.apply(flagOpts)
+	}
+	if flagOpts == nil {
+		return opts
+	}
+	return flagOpts
+}
+
+// progressPrinter returns an Options.Progress writing the progress to w.
+// On a terminal the progress is updated on one line, else every file gets
+// its own line.
+func progressPrinter(w io.Writer) func(done int, total int, file string) {
+	terminal := isTerminal(w)
+	return func(done int, total int, file string) {
+		// The progress is only informative, so failing to show it does not
+		// stop the generation.
+		if !terminal {
+			_, _ = fmt.Fprintf(w, "[%d/%d] %s\n", done, total, file)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "\r\033[K[%d/%d] %s", done, total, file)
+		if done == total {
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	if f, ok := w.(*os.File); ok {
+		if info, err := f.Stat(); err == nil {
+			return info.Mode()&os.ModeCharDevice != 0
+		}
+	}
+	return false
+}
+
 // Execute will parse args and execute the command line.
 func (dg *DocGenTool) Execute() error {
 	return dg.docCmd.Execute()
@@ -14,9 +14,22 @@
 package cobraman
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/alecsammon/cobraman/annotations"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
@@ -45,7 +58,7 @@ func TestAddDocGenerator(t *testing.T) {
 
 	// No error is thrown instead usage string is shown
 	assert.NoError(t, dg.Execute())
-	assert.Regexp(t, "Available Commands.+\n.+completion", buf)
+	assert.Regexp(t, "Available Commands.+\n(.+\n)*.+completion", buf)
 
 	buf.Reset()
 	args = []string{"generate-mdoc"}
@@ -83,3 +96,904 @@ func TestExecute(t *testing.T) {
 	assert.NoError(t, dg.Execute())
 	checkForFile(t, "foo.txt")
 }
+
+func TestDryRunFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "dry"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{}, "troff")
+	dg.AddBashCompletionGenerator("dry.sh")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	assert.Regexp(t, `^would write dry\.1 \(\d+ bytes\)\n$`, buf.String())
+
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"generate-auto-complete", "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	assert.Equal(t, "would write dry.sh\n", buf.String())
+
+	_, err := os.Stat("dry.1")
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat("dry.sh")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAddArchiveGenerator(t *testing.T) {
+	appCmd := &cobra.Command{Use: "arch"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	assert.Panics(t, func() { dg.AddArchiveGenerator(&Options{}, "foo", "arch.tar.gz") })
+	dg.AddArchiveGenerator(&Options{}, "troff", "arch.tar.gz")
+
+	dg.docCmd.SetArgs([]string{"archive-troff"})
+	assert.NoError(t, dg.Execute())
+	data, err := os.ReadFile("arch.tar.gz")
+	assert.NoError(t, err)
+	assert.Contains(t, readArchive(t, data), "man1/arch.1")
+	os.Remove("arch.tar.gz")
+
+	fs := afero.NewMemMapFs()
+	dg = CreateDocGenCmdLineTool(appCmd)
+	dg.AddArchiveGenerator(&Options{Fs: fs, FileMode: 0o600}, "troff", "arch.tar.gz")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+	dg.docCmd.SetArgs([]string{"archive-troff", "--directory", "dist/docs", "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	assert.Contains(t, buf.String(), "would write dist/docs/arch.tar.gz (")
+	dg.docCmd.SetArgs([]string{"archive-troff", "--directory", "dist/docs", "--dry-run=false"})
+	assert.NoError(t, dg.Execute())
+	info, err := fs.Stat("dist/docs/arch.tar.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestForceFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "forced"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{ProtectFiles: true}, "troff")
+	assert.NoError(t, os.WriteFile("forced.1", []byte("by hand"), 0o644))
+	defer os.Remove("forced.1")
+
+	dg.docCmd.SetArgs([]string{"generate-troff"})
+	assert.ErrorIs(t, dg.Execute(), ErrNotGenerated)
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--force"})
+	assert.NoError(t, dg.Execute())
+	content, _ := os.ReadFile("forced.1")
+	assert.Contains(t, string(content), generatedMarker)
+}
+
+func TestPageFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "zap"}
+	appCmd.AddCommand(&cobra.Command{Use: "publish", Short: "Publish it", Run: func(cmd *cobra.Command, args []string) {}})
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{}, "troff")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--page", "publish"})
+	assert.NoError(t, dg.Execute())
+	assert.Contains(t, buf.String(), `.TH "ZAP\-PUBLISH" "1"`)
+	_, err := os.Stat("zap-publish.1")
+	assert.True(t, os.IsNotExist(err))
+
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"generate-troff", "--page", "zap"})
+	assert.NoError(t, dg.Execute())
+	assert.Contains(t, buf.String(), `.TH "ZAP" "1"`)
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--page", "zap publish nope"})
+	assert.Error(t, dg.Execute())
+}
+
+func TestFormatFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "fmt"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}})
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Section: "8"}, "troff")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate", "--format", "troff,markdown", "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	assert.Regexp(t, `(?m)^would write fmt-sub\.8 `, buf.String())
+	assert.Regexp(t, `(?m)^would write fmt_sub\.md `, buf.String())
+
+	dg.docCmd.SetArgs([]string{"generate", "--format", "troff,html"})
+	err := dg.Execute()
+	assert.EqualError(t, err, `unknown format "html"`)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	dg.docCmd.SetArgs([]string{"generate", "--format", ""})
+	assert.Error(t, dg.Execute())
+
+	dg = CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{}, "troff")
+	dg.docCmd.SetArgs([]string{"generate"})
+	assert.ErrorIs(t, dg.Execute(), ErrNoFormat)
+}
+
+func TestAllFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "every"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate", "--all"})
+	assert.Error(t, dg.Execute())
+
+	dg.AddDocGenerator(&Options{}, "troff")
+	dg.AddDocGenerator(&Options{SingleFile: "every.txt"}, "mdoc")
+	dg.AddDocGenerator(&Options{}, "markdown")
+	dg.docCmd.SetArgs([]string{"generate", "--all", "--dry-run"})
+	err := dg.Execute()
+	assert.EqualError(t, err, "mdoc: "+ErrNoSingleFileTemplate.Error())
+	assert.Regexp(t, `(?m)^would write every\.1 `, buf.String())
+	assert.Regexp(t, `(?m)^would write every\.md `, buf.String())
+
+	dg.docCmd.SetArgs([]string{"generate", "--all", "--format", "troff"})
+	assert.Error(t, dg.Execute())
+}
+
+func TestToolConfig(t *testing.T) {
+	appCmd := &cobra.Command{Use: "conf"}
+	dg := CreateDocGenCmdLineToolWithConfig(appCmd, ToolConfig{
+		Use:             "docs",
+		GeneratorNames:  map[string]string{"troff": "man"},
+		GeneratorPrefix: "gen-",
+		DirectoryFlag:   "dir",
+		DryRunFlag:      "check",
+		ListCommand:     "files",
+	})
+	dg.AddDocGenerator(&Options{}, "troff")
+	dg.AddDocGenerator(&Options{}, "markdown")
+	dg.AddBashCompletionGenerator("conf.sh")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	assert.Equal(t, "docs", dg.docCmd.Name())
+	var names []string
+	for _, c := range dg.docCmd.Commands() {
+		names = append(names, c.Name())
+	}
+	assert.Equal(t, []string{"archive", "clean", "completion", "coverage", "diff", "files", "gen-markdown", "generate", "generate-auto-complete", "install", "man", "preview", "serve", "uninstall", "validate"}, names)
+
+	dg.docCmd.SetArgs([]string{"man", "--dir", "out", "--check"})
+	assert.NoError(t, dg.Execute())
+	assert.Regexp(t, `^would write out/conf\.1 `, buf.String())
+
+	dg.docCmd.SetArgs([]string{"man", "--directory", "out"})
+	assert.Error(t, dg.Execute())
+}
+
+func TestConfigFile(t *testing.T) {
+	appCmd := &cobra.Command{Use: "cfg"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Author: "Go Author", Bugs: "Go bugs"}, "troff")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "cobraman.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte(`author: File Author
+templates: [troff, markdown]
+directories:
+  troff: `+filepath.Join(dir, "man")+`
+`), 0o644))
+
+	dg.docCmd.SetArgs([]string{"generate", "--config", config})
+	assert.NoError(t, dg.Execute())
+	page, err := os.ReadFile(filepath.Join(dir, "man", "cfg.1"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), "File Author")
+	assert.Contains(t, string(page), "Go bugs")
+	_, err = os.Stat("cfg.md")
+	assert.NoError(t, err)
+	os.Remove("cfg.md")
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--config", filepath.Join(dir, "missing.yaml")})
+	assert.Error(t, dg.Execute())
+}
+
+func TestGeneratorDefaults(t *testing.T) {
+	appCmd := &cobra.Command{Use: "dflt"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.SetDefaults(&Options{Fs: fs, Author: "Shared Author", Section: "8"}).
+		AddDocGeneratorTo(&Options{Section: "1"}, "troff", "man").
+		AddDocGeneratorTo(&Options{FileExtension: ".mdx"}, "markdown", "site").
+		AddDocGenerator(&Options{}, "mdoc")
+	dg.docCmd.SetOutput(new(bytes.Buffer))
+
+	dg.docCmd.SetArgs([]string{"generate", "--all"})
+	assert.NoError(t, dg.Execute())
+	page, err := afero.ReadFile(fs, "man/dflt-sub.1")
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), `.TH "DFLT\-SUB" "1"`)
+	assert.Contains(t, string(page), "Shared Author")
+	for _, name := range []string{"site/dflt_sub.mdx", "dflt-sub.8"} {
+		ok, _ := afero.Exists(fs, name)
+		assert.True(t, ok, name)
+	}
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--directory", "flag"})
+	assert.NoError(t, dg.Execute())
+	ok, _ := afero.Exists(fs, "flag/dflt-sub.1")
+	assert.True(t, ok)
+}
+
+func TestVerboseFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "loud"}
+	appCmd.AddCommand(&cobra.Command{Use: "secret", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}})
+	run := func(args ...string) string {
+		dg := CreateDocGenCmdLineTool(appCmd)
+		dg.AddDocGenerator(&Options{Fs: afero.NewMemMapFs()}, "troff")
+		buf := new(bytes.Buffer)
+		dg.docCmd.SetOutput(buf)
+		dg.docCmd.SetArgs(append([]string{"generate-troff"}, args...))
+		assert.NoError(t, dg.Execute())
+		return buf.String()
+	}
+
+	assert.Empty(t, run())
+	out := run("--verbose")
+	assert.Contains(t, out, `level=INFO msg="writing file" file=loud.1 command=loud`)
+	assert.Contains(t, out, `level=DEBUG msg="skipping command" command="loud secret" reason=hidden`)
+	assert.Empty(t, run("--quiet"))
+}
+
+func TestProgressFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "slow"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Aliases: []string{"s"}, Run: func(cmd *cobra.Command, args []string) {}})
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: afero.NewMemMapFs(), AliasPages: true, ManifestFile: "manifest.json"}, "troff")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--progress"})
+	assert.NoError(t, dg.Execute())
+	assert.Equal(t, "[1/4] slow-sub.1\n[2/4] slow-s.1\n[3/4] slow.1\n[4/4] manifest.json\n", buf.String())
+}
+
+func TestJobsFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "many"}
+	for i := 0; i < 20; i++ {
+		appCmd.AddCommand(&cobra.Command{Use: fmt.Sprintf("sub%d", i), Run: func(cmd *cobra.Command, args []string) {}})
+	}
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs}, "troff")
+	dg.AddDocGenerator(&Options{Fs: fs}, "markdown")
+	dg.docCmd.SetOutput(new(bytes.Buffer))
+
+	dg.docCmd.SetArgs([]string{"generate", "--all", "--jobs", "4", "--directory", "docs"})
+	assert.NoError(t, dg.Execute())
+	for _, name := range []string{"docs/many.1", "docs/many-sub19.1", "docs/many.md", "docs/many_sub0.md"} {
+		ok, err := afero.Exists(fs, name)
+		assert.NoError(t, err)
+		assert.True(t, ok, name)
+	}
+	assert.Equal(t, 21, len(dg.commands.contexts))
+}
+
+func TestNoGenerator(t *testing.T) {
+	for _, args := range [][]string{{"generate", "--all"}, {"list"}, {"validate"}, {"diff"}, {"archive"}, {"clean"}} {
+		dg := CreateDocGenCmdLineTool(&cobra.Command{Use: "none"})
+		dg.docCmd.SetOutput(new(bytes.Buffer))
+		dg.docCmd.SetArgs(args)
+		assert.ErrorIs(t, dg.Execute(), ErrNoGenerator, args[0])
+	}
+}
+
+func TestKeepGoingFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "kg"}
+	appCmd.AddCommand(&cobra.Command{Use: "bad", Annotations: map[string]string{annotations.StructuredExamplesKey: "{"}, Run: func(cmd *cobra.Command, args []string) {}})
+	appCmd.AddCommand(&cobra.Command{Use: "good", Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs}, "troff")
+	dg.docCmd.SetOutput(new(bytes.Buffer))
+
+	dg.docCmd.SetArgs([]string{"generate", "--all", "--directory", "stop"})
+	assert.EqualError(t, dg.Execute(), "troff: unexpected end of JSON input")
+
+	dg.docCmd.SetArgs([]string{"generate", "--all", "--keep-going", "--directory", "all"})
+	err := dg.Execute()
+	assert.ErrorContains(t, err, "kg bad: ")
+	var pageErr *PageError
+	if assert.ErrorAs(t, err, &pageErr) {
+		assert.Equal(t, "kg bad", pageErr.Command)
+	}
+	ok, _ := afero.Exists(fs, "all/kg-good.1")
+	assert.True(t, ok)
+}
+
+func TestTemplateFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "tpl"}
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "custom.tmpl"), []byte("Custom {{ .CommandPath }}"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "markdown.tmpl"), []byte("Dir {{ .CommandPath }}"), 0o644))
+	fs := afero.NewMemMapFs()
+	run := func(args ...string) string {
+		dg := CreateDocGenCmdLineTool(appCmd)
+		dg.AddDocGenerator(&Options{Fs: fs}, "troff")
+		dg.AddDocGenerator(&Options{Fs: fs}, "markdown")
+		buf := new(bytes.Buffer)
+		dg.docCmd.SetOutput(buf)
+		dg.docCmd.SetArgs(args)
+		assert.NoError(t, dg.Execute())
+		return buf.String()
+	}
+
+	run("generate-troff", "--template", filepath.Join(dir, "custom.tmpl"), "--directory", "out")
+	page, err := afero.ReadFile(fs, "out/tpl.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Custom tpl", string(page))
+
+	run("generate", "--all", "--template-dir", dir, "--directory", "dir")
+	page, err = afero.ReadFile(fs, "dir/tpl.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "Dir tpl", string(page))
+	page, err = afero.ReadFile(fs, "dir/tpl.1")
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), ".TH")
+
+	assert.Equal(t, "Dir tpl", run("generate-markdown", "--template-dir", dir, "--page", "tpl"))
+}
+
+func TestIncludeHiddenFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "hid"}
+	appCmd.AddCommand(&cobra.Command{Use: "debug", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	run := func(args ...string) {
+		dg := CreateDocGenCmdLineTool(appCmd)
+		dg.AddDocGenerator(&Options{Fs: fs}, "troff")
+		dg.docCmd.SetOutput(new(bytes.Buffer))
+		dg.docCmd.SetArgs(args)
+		assert.NoError(t, dg.Execute())
+	}
+
+	run("generate-troff", "--directory", "public")
+	ok, _ := afero.Exists(fs, "public/hid-debug.1")
+	assert.False(t, ok)
+
+	run("generate-troff", "--include-hidden", "--directory", "internal")
+	ok, _ = afero.Exists(fs, "internal/hid-debug.1")
+	assert.True(t, ok)
+}
+
+func TestStrictFlag(t *testing.T) {
+	appCmd := &cobra.Command{Use: "str", Short: "Strict", Long: "Strict docs."}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}})
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: afero.NewMemMapFs()}, "troff")
+	dg.docCmd.SetOutput(new(bytes.Buffer))
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--strict"})
+	assert.ErrorIs(t, dg.Execute(), ErrIncompleteDocs)
+}
+
+func TestCoverageCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "cv", Long: "Covered.", Example: "cv", Args: cobra.NoArgs}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}})
+	run := func(args ...string) (string, error) {
+		dg := CreateDocGenCmdLineTool(appCmd)
+		dg.AddDocGenerator(&Options{Environment: "CV_HOME is the home."}, "troff")
+		buf := new(bytes.Buffer)
+		dg.docCmd.SetOutput(buf)
+		dg.docCmd.SetArgs(args)
+		err := dg.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run("coverage")
+	assert.NoError(t, err)
+	assert.Equal(t, `COMMAND  LONG  EXAMPLES  ARGS  ENV  SCORE
+cv       yes   yes       yes   yes  4/4
+cv sub   no    no        no    yes  1/4
+coverage: 62.5% of 2 commands
+`, out)
+
+	_, err = run("coverage", "--min-coverage", "80")
+	assert.EqualError(t, err, "coverage 62.5% is below the minimum of 80.0%")
+	_, err = run("coverage", "--min-coverage", "60", "--max-depth", "1")
+	assert.NoError(t, err)
+}
+
+func TestListCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "lst"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Aliases: []string{"s"}, Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"list"})
+	assert.Error(t, dg.Execute())
+
+	dg.AddDocGenerator(&Options{Fs: fs, SectionDirs: true, Gzip: true, AliasPages: true}, "troff")
+	dg.AddDocGenerator(&Options{Fs: fs, IndexFile: "index.md"}, "markdown")
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"list", "--directory", "out"})
+	assert.NoError(t, dg.Execute())
+	assert.Equal(t, "out/man1/lst-s.1.gz\nout/man1/lst-sub.1.gz\nout/man1/lst.1.gz\nout/index.md\nout/lst.md\nout/lst_sub.md\n", buf.String())
+
+	files, err := afero.ReadDir(fs, "out")
+	assert.True(t, err != nil || len(files) == 0, "list must not write files")
+}
+
+func TestValidateCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "chk", Short: "Check things"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{}, "troff")
+	dg.AddDocGenerator(&Options{}, "markdown")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"validate"})
+	assert.NoError(t, dg.Execute())
+	assert.Empty(t, buf.String())
+
+	appCmd.AddCommand(&cobra.Command{Use: "bare", Run: func(cmd *cobra.Command, args []string) {}})
+	dg.docCmd.SetArgs([]string{"validate", "--directory", "out"})
+	err := dg.Execute()
+	assert.EqualError(t, err, "1 problem(s) found")
+	assert.ErrorIs(t, err, ErrInvalidDocs)
+	assert.Contains(t, buf.String(), "out/chk-bare.1:8: NAME line has no description\n")
+}
+
+func TestDiffCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "ci", Short: "Check docs"}
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs, OmitDate: true}, "troff")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"diff", "--directory", "man"})
+	err := dg.Execute()
+	assert.EqualError(t, err, "docs out of date for troff, run generate")
+	assert.ErrorIs(t, err, ErrDocsOutOfDate)
+	assert.Contains(t, buf.String(), "+++ man/ci.1\n")
+
+	dg.docCmd.SetArgs([]string{"generate-troff", "--directory", "man"})
+	assert.NoError(t, dg.Execute())
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"diff", "--directory", "man"})
+	assert.NoError(t, dg.Execute())
+	assert.Empty(t, buf.String())
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchFlag(t *testing.T) {
+	defer func(interval time.Duration) { watchInterval = interval }(watchInterval)
+	watchInterval = 10 * time.Millisecond
+	dir := t.TempDir()
+	src := filepath.Join(dir, "help.txt")
+	assert.NoError(t, os.WriteFile(src, []byte("one"), 0o644))
+
+	runs := make(chan string, 10)
+	appCmd := &cobra.Command{Use: "watched"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: afero.NewMemMapFs(), Progress: func(done int, total int, file string) {
+		runs <- file
+	}}, "troff")
+	out := new(syncBuffer)
+	dg.docCmd.SetOutput(out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	dg.docCmd.SetArgs([]string{"generate-troff", "--watch", "--watch-path", src})
+	go func() { done <- dg.docCmd.ExecuteContext(ctx) }()
+
+	assert.Equal(t, "watched.1", <-runs)
+	assert.NoError(t, os.WriteFile(src, []byte("two!"), 0o644))
+	assert.Equal(t, "watched.1", <-runs)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Contains(t, out.String(), "watching "+src+" for changes\n")
+
+	dg = CreateDocGenCmdLineToolWithConfig(appCmd, ToolConfig{WatchCommand: []string{"echo", "rebuilt"}})
+	dg.AddDocGenerator(&Options{}, "troff")
+	out = new(syncBuffer)
+	dg.docCmd.SetOutput(out)
+	ctx, cancel = context.WithCancel(context.Background())
+	dg.docCmd.SetArgs([]string{"generate", "--format", "troff,markdown", "--watch", "--watch-path", src, "--dry-run"})
+	go func() { done <- dg.docCmd.ExecuteContext(ctx) }()
+	assert.Eventually(t, func() bool { return strings.Contains(out.String(), "watching") }, time.Second, time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Contains(t, out.String(), "rebuilt generate --dry-run=true --format=troff,markdown\n")
+
+	// Only the subcommands generating docs can watch.
+	for _, name := range []string{"serve", "install", "uninstall", "clean"} {
+		c, _, err := dg.docCmd.Find([]string{name})
+		if assert.NoError(t, err) {
+			assert.Nil(t, c.Flags().Lookup("watch"), name)
+		}
+	}
+	dg.docCmd.SetArgs([]string{"clean", "--watch"})
+	assert.ErrorContains(t, dg.Execute(), "unknown flag: --watch")
+}
+
+func TestWatchOutputDirectory(t *testing.T) {
+	defer func(interval time.Duration) { watchInterval = interval }(watchInterval)
+	watchInterval = 10 * time.Millisecond
+	dir := t.TempDir()
+
+	var runs int32
+	appCmd := &cobra.Command{Use: "watched"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Progress: func(done int, total int, file string) {
+		atomic.AddInt32(&runs, 1)
+	}}, "troff")
+	dg.docCmd.SetOutput(new(syncBuffer))
+
+	// The pages written into the watched directory are not changes.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*watchInterval)
+	defer cancel()
+	dg.docCmd.SetArgs([]string{"generate-troff", "--watch", "--watch-path", dir, "--directory", dir})
+	assert.NoError(t, dg.docCmd.ExecuteContext(ctx))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func TestServeCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "srv", Short: "Serve things"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	out := new(syncBuffer)
+	dg.docCmd.SetOutput(out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	dg.docCmd.SetArgs([]string{"serve", "--addr", "localhost:0"})
+	go func() { done <- dg.docCmd.ExecuteContext(ctx) }()
+
+	var url string
+	assert.Eventually(t, func() bool {
+		_, url, _ = strings.Cut(out.String(), "serving the docs on ")
+		return strings.HasSuffix(url, "\n")
+	}, 5*time.Second, time.Millisecond)
+	resp, err := http.Get(strings.TrimSpace(url) + "srv.md")
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), "Serve things")
+	}
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestInstallCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "inst", Short: "Install things"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Short: "Sub command", Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs, IndexFile: "index.md"}, "markdown")
+	dg.AddDocGenerator(&Options{Fs: fs, ManifestFile: "manifest.json"}, "troff")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"install", "--destdir", "stage", "--prefix", "/usr", "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	assert.Regexp(t, `(?m)^would write stage/usr/share/man/man1/inst-sub\.1\.gz `, buf.String())
+	assert.Regexp(t, `(?m)^would write stage/usr/share/zsh/site-functions/_inst `, buf.String())
+	exists, _ := afero.DirExists(fs, "stage")
+	assert.False(t, exists)
+
+	dg.docCmd.SetArgs([]string{"install", "--destdir", "stage", "--prefix", "/usr", "--dry-run=false"})
+	assert.NoError(t, dg.Execute())
+	var files []string
+	assert.NoError(t, afero.Walk(fs, "stage", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	}))
+	assert.Equal(t, []string{
+		"stage/usr/share/bash-completion/completions/inst",
+		"stage/usr/share/doc/inst/install-manifest.json",
+		"stage/usr/share/fish/vendor_completions.d/inst.fish",
+		"stage/usr/share/man/man1/inst-sub.1.gz",
+		"stage/usr/share/man/man1/inst.1.gz",
+		"stage/usr/share/zsh/site-functions/_inst",
+	}, files)
+	info, err := fs.Stat("stage/usr/share/man/man1/inst.1.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	assert.NoError(t, afero.WriteFile(fs, "stage/usr/share/man/man1/other.1", []byte("other"), 0o644))
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"uninstall", "--destdir", "stage", "--prefix", "/usr", "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	assert.Equal(t, 6, strings.Count(buf.String(), "would remove stage/usr/share/"))
+	dg.docCmd.SetArgs([]string{"uninstall", "--destdir", "stage", "--prefix", "/usr", "--dry-run=false"})
+	assert.NoError(t, dg.Execute())
+	files = nil
+	assert.NoError(t, afero.Walk(fs, "stage", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	}))
+	assert.Equal(t, []string{"stage/usr/share/man/man1/other.1"}, files)
+	assert.Error(t, dg.Execute())
+}
+
+func TestUninstallOutsideRoot(t *testing.T) {
+	appCmd := &cobra.Command{Use: "inst", Short: "Install things"}
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs}, "troff")
+	dg.docCmd.SetOutput(new(bytes.Buffer))
+
+	assert.NoError(t, afero.WriteFile(fs, "stage/usr/share/man/man1/inst.1.gz", []byte("page"), 0o644))
+	assert.NoError(t, afero.WriteFile(fs, "etc/passwd", []byte("root"), 0o644))
+	manifest := `{"files": [{"file": "share/man/man1/inst.1.gz"}, {"file": "../../etc/passwd"}]}`
+	assert.NoError(t, afero.WriteFile(fs, "stage/usr/share/doc/inst/install-manifest.json", []byte(manifest), 0o644))
+
+	dg.docCmd.SetArgs([]string{"uninstall", "--destdir", "stage", "--prefix", "/usr"})
+	err := dg.Execute()
+	assert.ErrorIs(t, err, ErrOutsideInstallRoot)
+	assert.ErrorContains(t, err, "../../etc/passwd")
+	for _, path := range []string{"etc/passwd", "stage/usr/share/man/man1/inst.1.gz", "stage/usr/share/doc/inst/install-manifest.json"} {
+		exists, _ := afero.Exists(fs, path)
+		assert.True(t, exists, path)
+	}
+}
+
+func TestInstallFirstManTemplate(t *testing.T) {
+	appCmd := &cobra.Command{Use: "inst", Short: "Install things"}
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs}, "mdoc")
+	dg.AddDocGenerator(&Options{Fs: fs}, "troff")
+	dg.docCmd.SetOutput(new(bytes.Buffer))
+
+	dg.docCmd.SetArgs([]string{"install", "--destdir", "stage"})
+	assert.NoError(t, dg.Execute())
+	data, err := afero.ReadFile(fs, "stage/usr/local/share/man/man1/inst.1.gz")
+	assert.NoError(t, err)
+	page, err := gunzip(data)
+	assert.NoError(t, err)
+	assert.Contains(t, string(page), ".Dd ")
+
+	data, err = afero.ReadFile(fs, "stage/usr/local/share/doc/inst/install-manifest.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), `"share/man/man1/inst.1.gz"`))
+}
+
+func TestCleanCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "cln", Short: "Clean things"}
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs, ManifestFile: "manifest.json"}, "troff")
+	dg.AddDocGenerator(&Options{Fs: fs, IndexFile: "index.md"}, "markdown")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate", "--all", "--directory", "docs"})
+	assert.NoError(t, dg.Execute())
+	assert.NoError(t, afero.WriteFile(fs, "docs/README.md", []byte("hand written"), 0o644))
+
+	// The pages of formats not added to the tool are kept.
+	RegisterTemplate("marked", "-", "txt", "{{ .CommandPath }} "+generatedMarker+"\n")
+	assert.NoError(t, GenerateDocs(appCmd, &Options{Fs: fs}, "docs", "marked"))
+
+	dg.docCmd.SetArgs([]string{"clean", "--directory", "docs"})
+	assert.NoError(t, dg.Execute())
+	files, err := afero.ReadDir(fs, "docs")
+	assert.NoError(t, err)
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{"README.md", "cln.txt"}, names)
+}
+
+func TestShellCompletionCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "shells"}
+	dg := CreateDocGenCmdLineTool(appCmd)
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+	dir := t.TempDir()
+
+	dg.docCmd.SetArgs([]string{"completion", "tcsh"})
+	assert.Error(t, dg.Execute())
+
+	dg.docCmd.SetArgs([]string{"completion", "zsh", "--directory", dir})
+	assert.NoError(t, dg.Execute())
+	data, err := os.ReadFile(filepath.Join(dir, "_shells"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "#compdef shells")
+
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"completion", "--directory", dir, "--dry-run"})
+	assert.NoError(t, dg.Execute())
+	for _, name := range []string{"shells.bash", "_shells", "shells.fish", "shells.ps1"} {
+		assert.Contains(t, buf.String(), "would write "+filepath.Join(dir, name)+" (")
+	}
+}
+
+func TestArchiveCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "rel"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Run: func(cmd *cobra.Command, args []string) {}})
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{}, "troff")
+	dg.AddDocGenerator(&Options{IndexFile: "index.md"}, "markdown")
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+	dir := t.TempDir()
+
+	dg.docCmd.SetArgs([]string{"archive", "--directory", dir})
+	err := dg.Execute()
+	assert.EqualError(t, err, "the application has no version, use --version")
+	assert.ErrorIs(t, err, ErrNoVersion)
+
+	appCmd.Version = "1.2.3"
+	dg.docCmd.SetArgs([]string{"archive", "--directory", dir})
+	assert.NoError(t, dg.Execute())
+	data, err := os.ReadFile(filepath.Join(dir, "docs-1.2.3.tar.gz"))
+	assert.NoError(t, err)
+	files := readArchive(t, data)
+	assert.Len(t, files, 5)
+	for _, name := range []string{
+		"docs-1.2.3/markdown/index.md",
+		"docs-1.2.3/markdown/rel.md",
+		"docs-1.2.3/markdown/rel_sub.md",
+		"docs-1.2.3/troff/man1/rel-sub.1",
+		"docs-1.2.3/troff/man1/rel.1",
+	} {
+		assert.Contains(t, files, name)
+	}
+
+	dg.docCmd.SetArgs([]string{"archive", "--directory", dir, "--version", "v2", "--zip"})
+	assert.NoError(t, dg.Execute())
+	zr, err := zip.OpenReader(filepath.Join(dir, "docs-v2.zip"))
+	if assert.NoError(t, err) {
+		assert.Len(t, zr.File, 5)
+		assert.Equal(t, "docs-v2/markdown/index.md", zr.File[0].Name)
+		zr.Close()
+	}
+
+	// The archive goes to the file system of the generators.
+	fs := afero.NewMemMapFs()
+	dg = CreateDocGenCmdLineTool(appCmd)
+	dg.AddDocGenerator(&Options{Fs: fs, FileMode: 0o600}, "troff")
+	dg.docCmd.SetArgs([]string{"archive", "--directory", "out", "--version", "v3"})
+	assert.NoError(t, dg.Execute())
+	info, err := fs.Stat(filepath.Join("out", "docs-v3.tar.gz"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+	assert.NoFileExists(t, filepath.Join("out", "docs-v3.tar.gz"))
+}
+
+func TestAttachDocGenCommand(t *testing.T) {
+	preRuns := 0
+	rootCmd := &cobra.Command{Use: "real", Short: "The real application", PersistentPreRun: func(cmd *cobra.Command, args []string) { preRuns++ }}
+	rootCmd.AddCommand(&cobra.Command{Use: "sub", Short: "Sub command", Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	dg := AttachDocGenCommand(rootCmd, &Options{Fs: fs})
+	dg.AddDocGenerator(&Options{Fs: fs}, "markdown")
+	buf := new(bytes.Buffer)
+	rootCmd.SetOutput(buf)
+
+	rootCmd.SetArgs([]string{"gen-docs", "--directory", "man"})
+	assert.NoError(t, rootCmd.Execute())
+	files, err := afero.ReadDir(fs, "man")
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Contains(t, names, "real-sub.1")
+	assert.Contains(t, names, "real.1")
+	assert.NotContains(t, names, "real-gen-docs.1")
+	assert.Equal(t, 1, preRuns)
+
+	rootCmd.SetArgs([]string{"gen-docs", "--directory", "hidden", "--include-hidden"})
+	assert.NoError(t, rootCmd.Execute())
+	exists, err := afero.Exists(fs, "hidden/real-gen-docs.1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	data, err := afero.ReadFile(fs, "hidden/real.1")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `gen\-docs`)
+
+	var subcommands []string
+	for _, c := range dg.docCmd.Commands() {
+		subcommands = append(subcommands, c.Name())
+	}
+	assert.Equal(t, []string{"generate-markdown"}, subcommands)
+
+	rootCmd.SetArgs([]string{"gen-docs", "generate-markdown", "--directory", "md"})
+	assert.NoError(t, rootCmd.Execute())
+	data, err = afero.ReadFile(fs, "md/real.md")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "gen-docs")
+
+	rootCmd.SetArgs([]string{"--help"})
+	assert.NoError(t, rootCmd.Execute())
+	assert.NotContains(t, buf.String(), "gen-docs")
+}
+
+func TestPreviewCommand(t *testing.T) {
+	appCmd := &cobra.Command{Use: "pv", Short: "Preview it"}
+	sub := &cobra.Command{Use: "sub", Short: "The sub command", Long: "The sub command does `things` for a long time, long enough to be wrapped over several lines of the page.", Run: func(cmd *cobra.Command, args []string) {}}
+	sub.Flags().Bool("fast", false, "go fast")
+	appCmd.AddCommand(sub)
+	run := func(args ...string) string {
+		dg := CreateDocGenCmdLineTool(appCmd)
+		dg.AddDocGenerator(&Options{Author: "Jane Doe"}, "troff")
+		buf := new(bytes.Buffer)
+		dg.docCmd.SetOutput(buf)
+		dg.docCmd.SetArgs(args)
+		assert.NoError(t, dg.Execute())
+		return buf.String()
+	}
+
+	defer func(old [][]string) { manCommands = old }(manCommands)
+	manCommands = [][]string{{"cat"}}
+	assert.Contains(t, run("preview", "sub"), `.TH "PV\-SUB" "1"`)
+
+	manCommands = [][]string{{"sh", "-c", "echo no -l >&2; exit 1"}, {"cat"}}
+	out := run("preview", "sub")
+	assert.True(t, strings.HasPrefix(out, `.TH "PV\-SUB" "1"`), out)
+
+	manCommands = [][]string{{"cobraman-no-such-man"}, {"false"}}
+	out = run("preview", "pv", "sub")
+	assert.True(t, strings.HasPrefix(out, "PV-SUB(1)\n\nNAME\n       pv-sub - The sub command\n"), out)
+	assert.Contains(t, out, "\nOPTIONS\n       --fast\n              go fast\n")
+	assert.Contains(t, out, "\nAUTHOR\n       Jane Doe\n")
+	for _, line := range strings.Split(out, "\n") {
+		assert.LessOrEqual(t, len(line), previewWidth, line)
+	}
+	assert.NotContains(t, out, "\033")
+}
+
+func TestAddCustomGenerator(t *testing.T) {
+	appCmd := &cobra.Command{Use: "cust"}
+	appCmd.AddCommand(&cobra.Command{Use: "sub", Short: "A sub command", Run: func(cmd *cobra.Command, args []string) {}})
+	fs := afero.NewMemMapFs()
+	dg := CreateDocGenCmdLineTool(appCmd)
+	dg.AddCustomGenerator("confluence", &Options{Fs: fs, ManifestFile: "manifest.json"}, func(cmd *cobra.Command, opts *Options, w io.Writer) error {
+		_, err := fmt.Fprintf(w, "h1. %s\n\n%s\n", cmd.CommandPath(), cmd.Short)
+		return err
+	})
+	buf := new(bytes.Buffer)
+	dg.docCmd.SetOutput(buf)
+
+	dg.docCmd.SetArgs([]string{"generate-confluence", "--directory", "wiki"})
+	assert.NoError(t, dg.Execute())
+	page, err := afero.ReadFile(fs, "wiki/cust_sub.confluence")
+	assert.NoError(t, err)
+	assert.Equal(t, "h1. cust sub\n\nA sub command\n", string(page))
+	manifest, err := afero.ReadFile(fs, "wiki/manifest.json")
+	assert.NoError(t, err)
+	assert.Contains(t, string(manifest), `"file": "cust_sub.confluence"`)
+
+	buf.Reset()
+	dg.docCmd.SetArgs([]string{"list", "--directory", "wiki"})
+	assert.NoError(t, dg.Execute())
+	assert.Equal(t, "wiki/cust.confluence\nwiki/cust_sub.confluence\nwiki/manifest.json\n", buf.String())
+}
@@ -0,0 +1,61 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// GenerateTreeDiagram writes a markdown page with a Mermaid flowchart of cmd
+// and all of its documented children to w.
+func GenerateTreeDiagram(cmd *cobra.Command, opts *Options, w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s command tree\n\n", cmd.CommandPath())
+	b.WriteString("```mermaid\nflowchart LR\n")
+	fmt.Fprintf(&b, "    %s[%q]\n", mermaidID(cmd), cmd.Name())
+	writeTreeEdges(&b, cmd, opts)
+	b.WriteString("```\n\n")
+	b.WriteString("[//]: # ( This file " + generatedMarker + "  )\n")
+
+	doc := b.String()
+	if opts.MarkdownLint {
+		doc = lintMarkdown(doc, opts.MarkdownLineLength)
+	}
+	_, err := io.WriteString(w, doc)
+	return err
+}
+
+func writeTreeEdges(b *strings.Builder, cmd *cobra.Command, opts *Options) {
+	for _, c := range cmd.Commands() {
+		if !isDocumented(c, opts) {
+			continue
+		}
+		fmt.Fprintf(b, "    %s --> %s[%q]\n", mermaidID(cmd), mermaidID(c), c.Name())
+		writeTreeEdges(b, c, opts)
+	}
+}
+
+// mermaidID turns the path of cmd into a node ID Mermaid accepts.
+func mermaidID(cmd *cobra.Command) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, cmd.CommandPath())
+}
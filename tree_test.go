@@ -0,0 +1,51 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTreeDiagram(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar-baz", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd3 := &cobra.Command{Use: "qux", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd4 := &cobra.Command{Use: "hidden", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.AddCommand(cmd3)
+	cmd.AddCommand(cmd2, cmd4)
+	opts := Options{}
+
+	assert.NoError(t, GenerateTreeDiagram(cmd, &opts, buf))
+	assert.Equal(t, "# foo command tree\n\n```mermaid\nflowchart LR\n"+
+		"    foo[\"foo\"]\n"+
+		"    foo --> foo_bar_baz[\"bar-baz\"]\n"+
+		"    foo_bar_baz --> foo_bar_baz_qux[\"qux\"]\n"+
+		"```\n\n"+
+		"[//]: # ( This file auto-generated by github.com/alecsammon/cobraman  )\n", buf.String())
+}
+
+func TestGenerateDocsTreeDiagramFile(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	opts := Options{TreeDiagramFile: "tree.md"}
+
+	assert.NoError(t, GenerateDocs(cmd, &opts, "", "troff"))
+	checkForFile(t, "foo.1")
+	checkForFile(t, "tree.md")
+}
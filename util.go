@@ -18,30 +18,397 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-var multiNewlineRegex = regexp.MustCompile(`\n+\n`)
+// multiNewlineRegex matches the blank lines between paragraphs, lines
+// holding only spaces and tabs count as blank.
+var multiNewlineRegex = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
 
 func simpleToMdoc(str string) string {
-	// Guessing this is already troff - so let it pass through
-	if len(str) > 1 && str[0] == '.' {
-		return str
+	return simpleToRoff(str, mdocStyle)
+}
+
+func simpleToTroff(str string) string {
+	return simpleToRoff(str, troffStyle)
+}
+
+// closingHashes matches the optional closing sequence of an ATX heading.
+var closingHashes = regexp.MustCompile(`\s+#+\s*$`)
+
+// simpleToRoff converts plain text with the macros of style.  Blank lines
+// start a new paragraph and markdown style "## Heading" lines a subsection.
+// Fenced code blocks and indented ones after a blank line are put in
+// no-fill regions so groff does not refill the commands in them.
+func simpleToRoff(str string, style roffStyle) string {
+	return splitRaw(str, func(text string) string {
+		return plainToRoff(text, style)
+	})
+}
+
+// rawFence is the line before and after raw troff that is put in man pages
+// as it is.  Being a comment it does not show up itself.
+const rawFence = `.\"RAW`
+
+// splitRaw converts the text of str outside raw troff fences with convert
+// and passes the lines between them through.  A fence that is not closed
+// runs to the end of str.
+func splitRaw(str string, convert func(text string) string) string {
+	if !strings.Contains(str, rawFence) {
+		return convert(str)
 	}
 
-	// TODO: this could certainly be more sophisticated.  Pull requests welcome!
-	// Right now it is good enough for the most simple cases.
-	return backslashify(multiNewlineRegex.ReplaceAllString(str, "\n.Pp\n"))
+	var parts, lines []string
+	raw := false
+	add := func(part string) {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	for _, line := range strings.Split(str, "\n") {
+		if strings.TrimSpace(line) != rawFence {
+			lines = append(lines, line)
+			continue
+		}
+		if raw {
+			add(strings.Join(lines, "\n"))
+		} else {
+			add(convert(strings.Join(lines, "\n")))
+		}
+		lines = nil
+		raw = !raw
+	}
+	if raw {
+		add(strings.Join(lines, "\n"))
+	} else {
+		add(convert(strings.Join(lines, "\n")))
+	}
+	return strings.Join(parts, "\n")
 }
 
-func simpleToTroff(str string) string {
-	// Guessing this is already troff - so let it pass through
-	if len(str) > 1 && str[0] == '.' {
-		return str
+// dropRaw removes the raw troff fences and the lines between them from str,
+// for pages that are not troff.
+func dropRaw(str string) string {
+	var lines []string
+	raw := false
+	for _, line := range strings.Split(str, "\n") {
+		switch {
+		case strings.TrimSpace(line) == rawFence:
+			raw = !raw
+		case !raw:
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func plainToRoff(str string, style roffStyle) string {
+	str = strings.ReplaceAll(str, "\r\n", "\n")
+
+	r := &plainRenderer{style: style}
+	lines := strings.Split(str, "\n")
+	for i := 0; i < len(lines); i++ {
+		switch {
+		case strings.HasPrefix(lines[i], "```"):
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(lines[end], "```") {
+				end++
+			}
+			r.code(lines[i+1 : end])
+			i = end
+		case atxHeading.MatchString(lines[i]):
+			r.flush()
+			title := strings.TrimSpace(closingHashes.ReplaceAllString(strings.TrimLeft(lines[i], "#"), ""))
+			r.b.WriteString(style.heading + " " + EscapeTroff(title) + "\n")
+		case isIndented(lines[i]) && (i == 0 || isBlank(lines[i-1])):
+			end := indentedBlockEnd(lines, i)
+			r.code(lines[i:end])
+			i = end - 1
+		default:
+			r.paragraph = append(r.paragraph, lines[i])
+		}
+	}
+	r.flush()
+	return strings.TrimSuffix(r.b.String(), "\n")
+}
+
+// plainRenderer collects the macros plainToRoff writes.
+type plainRenderer struct {
+	b         strings.Builder
+	style     roffStyle
+	paragraph []string
+}
+
+// flush writes the paragraph so far.
+func (r *plainRenderer) flush() {
+	text := strings.Trim(strings.Join(r.paragraph, "\n"), "\n")
+	r.paragraph = nil
+	if text == "" {
+		return
+	}
+	if r.b.Len() > 0 {
+		r.b.WriteString(r.style.paragraph + "\n")
 	}
+	link := func(url string) string {
+		return r.style.link(url, "")
+	}
+	for i, text := range multiNewlineRegex.Split(text, -1) {
+		if i > 0 {
+			r.b.WriteString(r.style.paragraph + "\n")
+		}
+		r.b.WriteString(roffLinks(text, link, r.style.mail) + "\n")
+	}
+}
+
+// code writes the code block lines.
+func (r *plainRenderer) code(lines []string) {
+	r.flush()
+	if r.b.Len() > 0 && r.style.spaceBlocks {
+		r.b.WriteString(r.style.paragraph + "\n")
+	}
+	r.b.WriteString(r.style.codeStart + "\n" + troffLiteral(dedent(lines)) + "\n" + r.style.codeEnd + "\n")
+}
 
-	// TODO: this could certainly be more sophisticated.  Pull requests welcome!
-	// Right now it is good enough for the most simple cases.
-	return backslashify(multiNewlineRegex.ReplaceAllString(str, "\n.PP\n"))
+// indentedBlockEnd returns the index of the line after the indented code
+// block starting at lines[start], without its trailing blank lines.
+func indentedBlockEnd(lines []string, start int) int {
+	end := start
+	for end < len(lines) && (isIndented(lines[end]) || isBlank(lines[end])) {
+		end++
+	}
+	for isBlank(lines[end-1]) {
+		end--
+	}
+	return end
+}
+
+// simpleToMarkdown separates the paragraphs of str by a single empty line,
+// like simpleToTroff does with .PP, so blank lines holding spaces or Windows
+// line endings do not change how the text is rendered.  Fenced code blocks
+// are kept as they are and raw troff is left out.
+func simpleToMarkdown(str string) string {
+	var lines []string
+	fence := ""
+	for _, line := range strings.Split(dropRaw(strings.ReplaceAll(str, "\r\n", "\n")), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		case trimmed == "":
+			if len(lines) == 0 || lines[len(lines)-1] == "" {
+				continue
+			}
+			line = ""
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// shiftHeadings moves the ATX headings of the markdown in str down by the
+// given number of levels.  Headings in fenced code blocks are left alone.
+func shiftHeadings(str string, by int) string {
+	lines := strings.Split(str, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		case atxHeading.MatchString(line):
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			lines[i] = heading(level+by) + line[level:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func isIndented(line string) bool {
+	return !isBlank(line) && (line[0] == ' ' || line[0] == '\t')
+}
+
+// dedent removes the indentation the lines have in common and joins them.
+func dedent(lines []string) string {
+	indent := -1
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if isBlank(line) {
+			lines[i] = ""
+		} else {
+			lines[i] = line[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"\\]*[^\s<>"\\.,;:!?')\]]`)
+
+// emailRegex matches an email address, with the angle brackets around it
+// as in "Foo Bar <foo@bar.com>".
+var emailRegex = regexp.MustCompile(`<?([\w.%+-]+@[\w-]+(?:\.[\w-]+)*\.[A-Za-z]{2,})>?`)
+
+// linkRegex matches a URL or an email address, the address being its first
+// group.
+var linkRegex = regexp.MustCompile(urlRegex.String() + "|" + emailRegex.String())
+
+// troffURL returns the man macros for a link to url, .UR and .UE make it
+// clickable in terminals and HTML output that support it.
+func troffURL(url string) string {
+	return ".UR " + url + "\n.UE"
+}
+
+// mdocURL returns the mdoc macro for a link to url.
+func mdocURL(url string) string {
+	return ".Lk " + url
+}
+
+// troffMail returns the man macros for a mailto link to addr.  groff shows
+// the address in angle brackets.
+func troffMail(addr string) string {
+	return ".MT " + addr + "\n.ME"
+}
+
+// mdocMail returns the mdoc macro for a mailto link to addr.
+func mdocMail(addr string) string {
+	return ".Mt " + addr
+}
+
+// roffLinks escapes str and puts the URLs and email addresses in it on
+// lines of their own using the macros returned by link and mail.
+// Punctuation right after one is passed to the last macro so no space is
+// put in front of it.
+func roffLinks(str string, link func(url string) string, mail func(addr string) string) string {
+	var b strings.Builder
+	last := 0
+	text := func(chunk string) {
+		// Every chunk starts a line.
+		b.WriteString(protectLines(backslashify(chunk)))
+	}
+	for _, loc := range linkRegex.FindAllStringSubmatchIndex(str, -1) {
+		text(strings.TrimRight(str[last:loc[0]], " "))
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+		if loc[2] >= 0 {
+			b.WriteString(mail(str[loc[2]:loc[3]]))
+		} else {
+			b.WriteString(link(str[loc[0]:loc[1]]))
+		}
+
+		last = loc[1]
+		end := last
+		for end < len(str) && !unicode.IsSpace(rune(str[end])) {
+			end++
+		}
+		if end > last {
+			b.WriteString(" " + backslashify(str[last:end]))
+		}
+		last = skipLineSpace(str, end)
+		if last < len(str) {
+			b.WriteByte('\n')
+		}
+	}
+	text(str[last:])
+	return b.String()
+}
+
+// skipLineSpace returns the index in str after the spaces from i on and a
+// newline following them.
+func skipLineSpace(str string, i int) int {
+	for i < len(str) && str[i] == ' ' {
+		i++
+	}
+	if i < len(str) && str[i] == '\n' {
+		i++
+	}
+	return i
+}
+
+// troffLiteral escapes str for use in a no-fill region like .EX/.EE.  Lines
+// starting with a control character are protected with a zero width \&.
+func troffLiteral(str string) string {
+	return protectLines(backslashify(str))
+}
+
+// troffGlyphs are the groff special characters for common non-ASCII
+// characters.  Others are written as \[uXXXX].
+var troffGlyphs = map[rune]string{
+	'\u00a0': `\~`,
+	'\u00a9': `\(co`,
+	'\u00ae': `\(rg`,
+	'\u00b0': `\(de`,
+	'\u00d7': `\(mu`,
+	'\u00f7': `\(di`,
+	'\u2010': `\(hy`,
+	'\u2013': `\(en`,
+	'\u2014': `\(em`,
+	'\u2018': `\(oq`,
+	'\u2019': `\(cq`,
+	'\u201c': `\(lq`,
+	'\u201d': `\(rq`,
+	'\u2020': `\(dg`,
+	'\u2022': `\(bu`,
+	'\u20ac': `\(Eu`,
+	'\u2122': `\(tm`,
+	'\u2192': `\(->`,
+	'\u2190': `\(<-`,
+}
+
+// escapeNonASCII replaces the non-ASCII characters in str with groff
+// special characters.  Invalid UTF-8 becomes the replacement character.
+func escapeNonASCII(str string) string {
+	var b strings.Builder
+	for _, r := range str {
+		switch glyph, ok := troffGlyphs[r]; {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case ok:
+			b.WriteString(glyph)
+		default:
+			fmt.Fprintf(&b, `\[u%04X]`, r)
+		}
+	}
+	return b.String()
+}
+
+// EscapeTroff makes str safe to put in a troff or mdoc page.  Backslashes
+// are escaped so str cannot hold groff escape sequences, quotes so it cannot
+// end a macro argument and lines starting with a control character get a
+// zero width \& in front so they cannot call a macro.
+func EscapeTroff(str string) string {
+	return protectLines(strings.ReplaceAll(backslashify(str), `"`, `\(dq`))
+}
+
+var lineStartReplacer = strings.NewReplacer("\n.", "\n\\&.", "\n'", "\n\\&'")
+
+// protectLines puts a zero width \& in front of the lines of str starting
+// with a control character.
+func protectLines(str string) string {
+	str = lineStartReplacer.Replace(str)
+	if strings.HasPrefix(str, ".") || strings.HasPrefix(str, "'") {
+		str = `\&` + str
+	}
+	return str
 }
 
 var backslashReplacer *strings.Replacer
@@ -61,10 +428,47 @@ func underscoreify(str string) string {
 	return strings.ReplaceAll(str, " ", "_")
 }
 
+var markdownReplacer = strings.NewReplacer(
+	"\\", "\\\\", "`", "\\`", "*", "\\*", "_", "\\_", "[", "\\[", "]", "\\]", "<", "\\<", ">", "\\>", "|", "\\|")
+
+// escapeMarkdown puts a backslash in front of the characters that markdown
+// would take as formatting, like backslashify does for troff.
+func escapeMarkdown(str string) string {
+	return markdownReplacer.Replace(str)
+}
+
+// tableCell makes str safe to use in a cell of a markdown table.
+func tableCell(str string) string {
+	return escapeMarkdown(strings.Join(strings.Fields(str), " "))
+}
+
+// markdownCell makes markdown str safe to use in a cell of a markdown table
+// without escaping its formatting.
+func markdownCell(str string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(str), " "), "|", "\\|")
+}
+
+// flagList formats flag names as a comma separated list of long options.
+func flagList(names []string) string {
+	options := make([]string, len(names))
+	for i, name := range names {
+		options[i] = "--" + name
+	}
+	return strings.Join(options, ", ")
+}
+
 func trimRightSpace(s string) string {
 	return strings.TrimRightFunc(s, unicode.IsSpace)
 }
 
+// minInt returns the smaller of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // rpad adds padding to the right of a string.
 func rpad(s string, padding int) string {
 	template := fmt.Sprintf("%%-%ds", padding)
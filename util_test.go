@@ -14,9 +14,13 @@
 package cobraman
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bytes"
 	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestBackslashify(t *testing.T) {
@@ -68,9 +72,18 @@ func TestSimpleToTroff(t *testing.T) {
 	cases := [][]string{
 		{"Some test\none a line", "Some test\none a line"},
 		{"Some test\n\nwith empty line", "Some test\n.PP\nwith empty line"},
-		{".ignore me\n\none a line", ".ignore me\n\none a line"},
+		{".escape me\n\none a line", "\\&.escape me\n.PP\none a line"},
+		{"Text\n.\\\"RAW\n.B raw\n.\\\"RAW\n\nmore", "Text\n.B raw\nmore"},
 		{"Some test\n\n\nwith empty line", "Some test\n.PP\nwith empty line"},
 		{"Some test\n\n\n\nwith empty line", "Some test\n.PP\nwith empty line"},
+		{"Filed at https://x.io/a-b.", "Filed at\n.UR https://x.io/a-b\n.UE ."},
+		{"(see https://x.io) .dot", "(see\n.UR https://x.io\n.UE )\n\\&.dot"},
+		{"Mail bugs@x.io, or not", "Mail\n.MT bugs@x.io\n.ME ,\nor not"},
+		{"  foo bar\n    --baz\n\n  foo .", ".EX\nfoo bar\n  \\-\\-baz\n\nfoo .\n.EE"},
+		{"Run it:\n\n```\n.hidden\n```\nDone", "Run it:\n.PP\n.EX\n\\&.hidden\n.EE\n.PP\nDone"},
+		{"Text\n  indented", "Text\n  indented"},
+		{"One\r\n  \t\r\nTwo", "One\n.PP\nTwo"},
+		{"Intro\n## Usage ##\ntext\n\n### C#\nmore", "Intro\n.SS Usage\n.PP\ntext\n.SS C#\n.PP\nmore"},
 	}
 
 	for i := 0; i < len(cases); i++ {
@@ -84,9 +97,12 @@ func TestSimpleToMdoc(t *testing.T) {
 	cases := [][]string{
 		{"Some test\none a line", "Some test\none a line"},
 		{"Some test\n\nwith empty line", "Some test\n.Pp\nwith empty line"},
-		{".ignore me\n\none a line", ".ignore me\n\none a line"},
+		{".escape me\n\none a line", "\\&.escape me\n.Pp\none a line"},
+		{".\\\"RAW\n.Sy raw", ".Sy raw"},
 		{"Some test\n\n\nwith empty line", "Some test\n.Pp\nwith empty line"},
 		{"Some test\n\n\n\nwith empty line", "Some test\n.Pp\nwith empty line"},
+		{"Filed at https://x.io/a-b.", "Filed at\n.Lk https://x.io/a-b ."},
+		{"Run it:\n\n  foo bar", "Run it:\n.Bd -literal -offset indent\nfoo bar\n.Ed"},
 	}
 
 	for i := 0; i < len(cases); i++ {
@@ -96,6 +112,29 @@ func TestSimpleToMdoc(t *testing.T) {
 	}
 }
 
+func TestSimpleToMarkdown(t *testing.T) {
+	cases := [][]string{
+		{"One\n\nTwo", "One\n\nTwo"},
+		{"\nOne\n  \n\t\n\nTwo\n\n", "One\n\nTwo"},
+		{"One\r\n\r\nTwo", "One\n\nTwo"},
+		{"```\na\n\n\nb\n```", "```\na\n\n\nb\n```"},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], simpleToMarkdown(cases[i][0]))
+	}
+}
+
+func TestDropRaw(t *testing.T) {
+	assert.Equal(t, "Text\nmore", dropRaw("Text\n.\\\"RAW\n.B raw\n.\\\"RAW\nmore"))
+	assert.Equal(t, "Text", simpleToMarkdown("Text\n\n.\\\"RAW\n.B raw"))
+}
+
+func TestShiftHeadings(t *testing.T) {
+	assert.Equal(t, "### Usage\ntext\n#### More", shiftHeadings("## Usage\ntext\n### More", 1))
+	assert.Equal(t, "```\n# comment\n```\n###### Deep", shiftHeadings("```\n# comment\n```\n##### Deep", 3))
+}
+
 func TestRpad(t *testing.T) {
 	cases := [][]string{
 		{"foo", "10", "foo       x"},
@@ -136,3 +175,125 @@ func TestMakeline(t *testing.T) {
 		assert.Equal(t, expected, str)
 	}
 }
+
+func TestFlagList(t *testing.T) {
+	assert.Equal(t, "", flagList(nil))
+	assert.Equal(t, "--foo", flagList([]string{"foo"}))
+	assert.Equal(t, "--foo, --bar", flagList([]string{"foo", "bar"}))
+}
+
+func TestTroffLiteral(t *testing.T) {
+	cases := [][]string{
+		{"ls -l", `ls \-l`},
+		{".start\n'quote\nmiddle.", "\\&.start\n\\&'quote\nmiddle."},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], troffLiteral(cases[i][0]))
+	}
+}
+
+func TestEscapeTroff(t *testing.T) {
+	cases := [][]string{
+		{"plain", "plain"},
+		{".so /etc/passwd", `\&.so /etc/passwd`},
+		{"'br", `\&'br`},
+		{"usage\n.rm TH\n'sp", "usage\n\\&.rm TH\n\\&'sp"},
+		{`say "hi"`, `say \(dqhi\(dq`},
+		{`\fBbold\fP \*(lq`, `\\fBbold\\fP \\*(lq`},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], EscapeTroff(cases[i][0]))
+	}
+}
+
+// troffEscapes are the escape sequences EscapeTroff writes.
+var troffEscapes = strings.NewReplacer(`\\`, "", `\-`, "", `\_`, "", `\&`, "", `\~`, "", `\(dq`, "")
+
+func FuzzEscapeTroff(f *testing.F) {
+	f.Add(".so /etc/passwd")
+	f.Add("a\n'br\n\\fB\"x\"")
+	f.Add(`\\\`)
+	f.Add(`does \fBbold\fP " and \" hidden`)
+	f.Add(`a "b" \c`)
+	f.Fuzz(func(t *testing.T, str string) {
+		escaped := EscapeTroff(str)
+		for _, line := range strings.Split(escaped, "\n") {
+			if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+				t.Fatalf("line %q of %q starts with a control character", line, escaped)
+			}
+		}
+		if strings.Contains(escaped, `"`) {
+			t.Fatalf("%q holds a quote", escaped)
+		}
+		if rest := troffEscapes.Replace(escaped); strings.Contains(rest, `\`) {
+			t.Fatalf("%q holds an escape sequence", escaped)
+		}
+
+		// The arguments of .TH are a single line, so only the NAME line
+		// gets the newlines.
+		header := strings.ReplaceAll(str, "\n", " ")
+		cmd := &cobra.Command{Use: "foo", Short: str}
+		opts := Options{CenterFooter: header, LeftFooter: header, CenterHeader: header}
+		buf := new(bytes.Buffer)
+		if err := GenerateOnePage(cmd, &opts, "troff", buf); err != nil {
+			t.Fatal(err)
+		}
+		page := buf.String()
+		th := page[:strings.Index(page, "\n")]
+		if strings.Count(th, `"`) != 10 {
+			t.Fatalf("header %q does not hold five arguments", th)
+		}
+		name := page[strings.Index(page, ".SH NAME\n")+len(".SH NAME\n") : strings.Index(page, ".SH SYNOPSIS")]
+		for _, line := range strings.Split(name, "\n") {
+			if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+				t.Fatalf("line %q of the NAME section starts with a control character", line)
+			}
+		}
+
+		buf.Reset()
+		if err := GenerateOnePage(cmd, &opts, "mdoc", buf); err != nil {
+			t.Fatal(err)
+		}
+		page = buf.String()
+		dt := page[strings.Index(page, "\n.Dt ")+1:]
+		dt = dt[:strings.Index(dt, "\n")]
+		if quotes := strings.Count(dt, `"`); quotes != 0 && quotes != 2 {
+			t.Fatalf("header %q holds %d quotes", dt, quotes)
+		}
+		name = page[strings.Index(page, ".Sh NAME\n")+len(".Sh NAME\n") : strings.Index(page, ".Sh SYNOPSIS")]
+		for i, line := range strings.Split(strings.TrimSuffix(name, "\n"), "\n") {
+			if i > 1 && (strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'")) {
+				t.Fatalf("line %q of the NAME section starts with a control character", line)
+			}
+			if rest := troffEscapes.Replace(line); strings.Contains(rest, `\`) || strings.Contains(rest, `"`) {
+				t.Fatalf("line %q of the NAME section holds an escape sequence or a quote", line)
+			}
+		}
+	})
+}
+
+func TestEscapeNonASCII(t *testing.T) {
+	cases := [][]string{
+		{"plain", "plain"},
+		{"Slavoj Žižek", `Slavoj \[u017D]i\[u017E]ek`},
+		{"a — “b” © 2018", `a \(em \(lqb\(rq \(co 2018`},
+		{"日本", `\[u65E5]\[u672C]`},
+		{"bad \xff", `bad \[uFFFD]`},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], escapeNonASCII(cases[i][0]))
+	}
+}
+
+func TestTableCell(t *testing.T) {
+	assert.Equal(t, "a \\| b", tableCell("a | b"))
+	assert.Equal(t, "two lines", tableCell("two\nlines "))
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	assert.Equal(t, "use \\*.go in dir\\_name \\<path\\> \\| \\[x\\] \\`a\\` \\\\", escapeMarkdown("use *.go in dir_name <path> | [x] `a` \\"))
+	assert.Equal(t, "plain text", escapeMarkdown("plain text"))
+}
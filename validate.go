@@ -0,0 +1,276 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// maxWhatisLength is the longest NAME line ValidateDocs accepts, so whatis
+// and apropos show it on one line.
+const maxWhatisLength = 80
+
+// manPageRegex matches the title macro of troff and mdoc man pages.
+var manPageRegex = regexp.MustCompile(`(?m)^\.(TH|Dd) `)
+
+// Problem is an issue ValidateDocs found in a generated file.
+type Problem struct {
+	// File is the name of the file, relative to the output directory.
+	File string
+
+	// Line is the line of the problem, starting at 1.
+	Line int
+
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the problem as "file:line: message", like compilers do.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+// ValidateDocs renders the files GenerateDocs would write for cmd, like
+// RenderDocs, and checks them for common mistakes: font changes in man
+// pages that are not reset, man pages with a missing or overlong NAME line
+// and markdown links to files that are not generated.  It returns the
+// problems sorted by file and line, none if the files are fine.
+func ValidateDocs(cmd *cobra.Command, opts *Options, templateName string) ([]Problem, error) {
+	files, err := RenderDocs(cmd, opts, templateName)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for name, data := range files {
+		if strings.HasSuffix(name, ".gz") {
+			if data, err = gunzip(data); err != nil {
+				problems = append(problems, Problem{File: name, Line: 1, Message: err.Error()})
+				continue
+			}
+		}
+		doc := string(data)
+		switch {
+		case strings.HasPrefix(doc, ".so "):
+			// Alias pages only include the page of their command.
+		case manPageRegex.MatchString(doc):
+			problems = append(problems, checkFonts(name, doc)...)
+			problems = append(problems, checkNameLine(name, doc)...)
+		case path.Ext(filepath.ToSlash(name)) == ".md":
+			problems = append(problems, checkLinks(name, doc, files)...)
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+// checkComplete returns ErrIncompleteDocs, listing what is missing, if one
+// of cmds has no Short or Long description or one of the flags it documents
+// has no usage.
+func checkComplete(cmds []*cobra.Command, opts *Options) error {
+	var missing []string
+	for _, c := range cmds {
+		if strings.TrimSpace(c.Short) == "" {
+			missing = append(missing, c.CommandPath()+": no Short description")
+		}
+		if strings.TrimSpace(c.Long) == "" {
+			missing = append(missing, c.CommandPath()+": no Long description")
+		}
+		c.NonInheritedFlags().VisitAll(func(flag *pflag.Flag) {
+			if (flag.Hidden && !opts.IncludeHidden) || (flag.Deprecated != "" && !opts.IncludeDeprecated) {
+				return
+			}
+			if strings.TrimSpace(flag.Usage) == "" {
+				missing = append(missing, c.CommandPath()+": flag --"+flag.Name+" has no usage")
+			}
+		})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("%w:\n%s", ErrIncompleteDocs, strings.Join(missing, "\n"))
+}
+
+// gunzip returns the uncompressed content of the gzip data.
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(gz)
+}
+
+// checkFonts reports the font changes, like \fB, of the man page doc that
+// are not reset with \fR or \fP before the next macro or the end of the
+// page.
+func checkFonts(name string, doc string) []Problem {
+	var problems []Problem
+	open, openLine := "", 0
+	report := func() {
+		if open != "" {
+			problems = append(problems, Problem{File: name, Line: openLine, Message: `font \f` + open + ` is not reset`})
+		}
+		open = ""
+	}
+	for i, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			report()
+			if strings.HasPrefix(line, `.\"`) {
+				continue
+			}
+		}
+		if font, changed := lastFont(line); changed {
+			open, openLine = font, i+1
+		}
+	}
+	report()
+	return problems
+}
+
+// lastFont returns the last font line changes to, "" if it is reset, and
+// whether line changes the font at all.
+func lastFont(line string) (string, bool) {
+	font, changed := "", false
+	for j := 0; j < len(line)-1; j++ {
+		if line[j] != '\\' {
+			continue
+		}
+		j++
+		if line[j] != 'f' || j+1 >= len(line) {
+			continue
+		}
+		escape := fontEscape(line[j+1:])
+		j += len(escape)
+		switch strings.Trim(escape, "([]") {
+		case "R", "P", "1", "":
+			font, changed = "", true
+		default:
+			font, changed = escape, true
+		}
+	}
+	return font, changed
+}
+
+// fontEscape returns the font name at the start of str following a \f, as
+// written: B, (CW or [B].
+func fontEscape(str string) string {
+	switch str[0] {
+	case '(':
+		return str[:minInt(3, len(str))]
+	case '[':
+		if end := strings.IndexByte(str, ']'); end >= 0 {
+			return str[:end+1]
+		}
+	}
+	return str[:1]
+}
+
+// whatisEscapes turns the troff escapes of a NAME line into the text
+// whatis shows.
+var whatisEscapes = strings.NewReplacer(`\-`, "-", `\(em`, "-", `\(en`, "-", `\&`, "", `\e`, `\`, `\\`, `\`)
+
+// checkNameLine reports a man page without a NAME section, without a
+// description in it for whatis, or with a NAME line too long for whatis.
+func checkNameLine(name string, doc string) []Problem {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		if line != ".SH NAME" && line != ".Sh NAME" {
+			continue
+		}
+		whatis, lineNum := "", i+1
+		if line == ".SH NAME" && i+1 < len(lines) {
+			whatis, lineNum = lines[i+1], i+2
+			if !strings.Contains(whatis, ` \- `) && !strings.Contains(whatis, " - ") {
+				return []Problem{{File: name, Line: lineNum, Message: "NAME line has no description"}}
+			}
+		} else {
+			nm, nd, ndLine := mdocName(lines[i+1:])
+			if nd == "" {
+				return []Problem{{File: name, Line: lineNum, Message: "NAME section has no .Nd description"}}
+			}
+			whatis, lineNum = nm+" - "+nd, i+1+ndLine
+		}
+		if length := utf8.RuneCountInString(whatisEscapes.Replace(whatis)); length > maxWhatisLength {
+			message := fmt.Sprintf("NAME line is %d characters long, more than %d", length, maxWhatisLength)
+			return []Problem{{File: name, Line: lineNum, Message: message}}
+		}
+		return nil
+	}
+	return []Problem{{File: name, Line: 1, Message: "no NAME section"}}
+}
+
+// mdocName returns the arguments of the .Nm and .Nd macros of the mdoc
+// NAME section that lines start in, and the line number in lines of .Nd.
+func mdocName(lines []string) (nm string, nd string, ndLine int) {
+	for j := 0; j < len(lines) && !strings.HasPrefix(lines[j], ".Sh "); j++ {
+		if strings.HasPrefix(lines[j], ".Nm ") {
+			nm = strings.TrimPrefix(lines[j], ".Nm ")
+		}
+		if strings.HasPrefix(lines[j], ".Nd ") {
+			nd, ndLine = strings.TrimPrefix(lines[j], ".Nd "), j+1
+		}
+	}
+	return nm, nd, ndLine
+}
+
+// markdownLinkRegex matches the target of markdown links and images.
+var markdownLinkRegex = regexp.MustCompile(`\]\(([^)\s]+)\)`)
+
+// checkLinks reports the relative links of the markdown page doc to files
+// that are not in files.  Links to URLs, anchors and absolute paths are
+// not checked.
+func checkLinks(name string, doc string, files map[string][]byte) []Problem {
+	var problems []Problem
+	fence := false
+	for i, line := range strings.Split(doc, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fence = !fence
+		}
+		if fence {
+			continue
+		}
+		for _, match := range markdownLinkRegex.FindAllStringSubmatch(line, -1) {
+			target := match[1]
+			if strings.Contains(target, ":") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "/") {
+				continue
+			}
+			if hash := strings.IndexByte(target, '#'); hash >= 0 {
+				target = target[:hash]
+			}
+			file := filepath.FromSlash(path.Join(path.Dir(filepath.ToSlash(name)), target))
+			if _, ok := files[file]; !ok {
+				problems = append(problems, Problem{File: name, Line: i + 1, Message: "broken link to " + match[1]})
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,93 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDocs(t *testing.T) {
+	cmd := &cobra.Command{Use: "val", Short: "Validate things"}
+	cmd.AddCommand(&cobra.Command{Use: "bare", Run: func(cmd *cobra.Command, args []string) {}})
+	cmd.AddCommand(&cobra.Command{Use: "long", Short: strings.Repeat("very ", 16) + "long", Run: func(cmd *cobra.Command, args []string) {}})
+
+	for _, tmpl := range []string{"troff", "mdoc"} {
+		problems, err := ValidateDocs(cmd, &Options{Gzip: true, AliasPages: true}, tmpl)
+		assert.NoError(t, err)
+		if assert.Len(t, problems, 2, tmpl) {
+			assert.Equal(t, "val-bare.1.gz", problems[0].File)
+			assert.Contains(t, problems[0].Message, "no")
+			assert.Equal(t, "val-long.1.gz", problems[1].File)
+			assert.Contains(t, problems[1].Message, "more than 80")
+		}
+	}
+
+	problems, err := ValidateDocs(cmd, &Options{}, "markdown")
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	problems, err = ValidateDocs(cmd, &Options{LinkHandler: func(name string) string { return "missing/" + name }}, "markdown")
+	assert.NoError(t, err)
+	assert.Len(t, problems, 8)
+	assert.Equal(t, "val.md:11: broken link to missing/val_bare.md", problems[0].String())
+}
+
+func TestCheckFonts(t *testing.T) {
+	doc := ".TH X\n\\fBbold\\fR and \\fIitalic\\fP\n\\fBwrapped\nline\\fR\n.PP\n\\f(CWcode\n.SH NAME\n\\\\fBescaped\n\\f[B]open\n"
+	problems := checkFonts("x.1", doc)
+	assert.Equal(t, []Problem{
+		{File: "x.1", Line: 6, Message: `font \f(CW is not reset`},
+		{File: "x.1", Line: 9, Message: `font \f[B] is not reset`},
+	}, problems)
+}
+
+func TestCheckLinks(t *testing.T) {
+	files := map[string][]byte{"a.md": nil, "sub/b.md": nil}
+	doc := "[a](a.md) [b](sub/b.md#flags) [c](c.md)\n[web](https://example.com) [top](#top) [abs](/x.md)\n```\n[d](d.md)\n```\n"
+	assert.Equal(t, []Problem{{File: "a.md", Line: 1, Message: "broken link to c.md"}}, checkLinks("a.md", doc, files))
+	assert.Len(t, checkLinks("sub/b.md", doc, files), 3)
+}
+
+func TestStrict(t *testing.T) {
+	cmd := &cobra.Command{Use: "strict", Short: "Be strict", Long: "Be strict about the docs."}
+	sub := &cobra.Command{Use: "sub", Short: "A sub command", Run: func(cmd *cobra.Command, args []string) {}}
+	sub.Flags().String("documented", "", "a documented flag")
+	sub.Flags().String("bare", "", "")
+	sub.Flags().String("secret", "", "")
+	assert.NoError(t, sub.Flags().MarkHidden("secret"))
+	cmd.AddCommand(sub)
+	cmd.AddCommand(&cobra.Command{Use: "internal", Hidden: true, Run: func(cmd *cobra.Command, args []string) {}})
+
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs}, "loose", "troff"))
+
+	err := GenerateDocs(cmd, &Options{Fs: fs, Strict: true}, "strict", "troff")
+	assert.ErrorIs(t, err, ErrIncompleteDocs)
+	assert.EqualError(t, err, "incomplete documentation:\nstrict sub: flag --bare has no usage\nstrict sub: no Long description")
+	ok, _ := afero.Exists(fs, "strict/strict.1")
+	assert.False(t, ok, "nothing is written")
+
+	err = GenerateDocs(cmd, &Options{Fs: fs, Strict: true, IncludeHidden: true, SingleFile: "all.md"}, "strict", "markdown")
+	assert.EqualError(t, err, "incomplete documentation:\nstrict internal: no Long description\nstrict internal: no Short description\n"+
+		"strict sub: flag --bare has no usage\nstrict sub: flag --secret has no usage\nstrict sub: no Long description")
+
+	sub.Long = "A sub command, documented."
+	sub.Flags().Lookup("bare").Usage = "a bare flag"
+	assert.NoError(t, GenerateDocs(cmd, &Options{Fs: fs, Strict: true}, "strict", "troff"))
+}
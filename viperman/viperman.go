@@ -0,0 +1,78 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package viperman fills in cobraman documentation from the configuration
+// registered with a github.com/spf13/viper instance.
+package viperman
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alecsammon/cobraman"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// EnvVars returns an entry for every key known to v (keys bound with BindEnv,
+// BindPFlag, SetDefault or read from a config file) for use in
+// cobraman.Options.EnvVars.
+//
+// Viper does not expose its environment settings so the prefix and key
+// replacer given to SetEnvPrefix and SetEnvKeyReplacer have to be passed in
+// again, replacer may be nil.  Names given explicitly to BindEnv can not be
+// discovered.  Keys that match the name of a flag of cmd or one of its
+// sub-commands are tied to that flag.
+func EnvVars(v *viper.Viper, cmd *cobra.Command, prefix string, replacer *strings.Replacer) []cobraman.EnvVar {
+	flags := make(map[string]bool)
+	collectFlags(cmd, flags)
+
+	keys := v.AllKeys()
+	sort.Strings(keys)
+
+	envVars := make([]cobraman.EnvVar, 0, len(keys))
+	for _, key := range keys {
+		env := cobraman.EnvVar{Name: envName(key, prefix, replacer)}
+		if flags[key] {
+			env.Flag = key
+		} else {
+			env.Description = "Sets the " + key + " configuration value."
+		}
+		envVars = append(envVars, env)
+	}
+
+	return envVars
+}
+
+// envName mirrors how viper maps a key to an environment variable.
+func envName(key, prefix string, replacer *strings.Replacer) string {
+	if replacer != nil {
+		key = replacer.Replace(key)
+	}
+	if prefix != "" {
+		key = prefix + "_" + key
+	}
+	return strings.ToUpper(key)
+}
+
+func collectFlags(cmd *cobra.Command, flags map[string]bool) {
+	add := func(flag *pflag.Flag) {
+		flags[flag.Name] = true
+	}
+	cmd.Flags().VisitAll(add)
+	cmd.PersistentFlags().VisitAll(add)
+	for _, c := range cmd.Commands() {
+		collectFlags(c, flags)
+	}
+}
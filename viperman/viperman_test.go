@@ -0,0 +1,55 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package viperman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alecsammon/cobraman"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvVars(t *testing.T) {
+	cmd := &cobra.Command{Use: "foo"}
+	cmd2 := &cobra.Command{Use: "bar", Run: func(cmd *cobra.Command, args []string) {}}
+	cmd2.Flags().String("log-level", "info", "how much to log")
+	cmd.AddCommand(cmd2)
+
+	v := viper.New()
+	assert.NoError(t, v.BindPFlag("log-level", cmd2.Flags().Lookup("log-level")))
+	assert.NoError(t, v.BindEnv("token"))
+
+	envVars := EnvVars(v, cmd, "foo", strings.NewReplacer("-", "_"))
+	assert.Equal(t, []cobraman.EnvVar{
+		{Name: "FOO_LOG_LEVEL", Flag: "log-level"},
+		{Name: "FOO_TOKEN", Description: "Sets the token configuration value."},
+	}, envVars)
+
+	envVars = EnvVars(v, cmd, "", nil)
+	assert.Equal(t, "LOG-LEVEL", envVars[0].Name)
+
+	buf := new(bytes.Buffer)
+	opts := cobraman.Options{EnvVars: EnvVars(v, cmd, "foo", strings.NewReplacer("-", "_"))}
+	assert.NoError(t, cobraman.GenerateOnePage(cmd2, &opts, "troff", buf))
+	assert.Regexp(t, ".SH ENVIRONMENT\n.TP\n.fBFOO\\\\_LOG\\\\_LEVEL.fP\nhow much to log \\(same as .fB\\\\-\\\\-log\\\\-level.fP\\)\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, cobraman.GenerateOnePage(cmd, &opts, "troff", buf))
+	assert.NotRegexp(t, "FOO\\\\_LOG\\\\_LEVEL", buf.String())
+	assert.Regexp(t, "FOO\\\\_TOKEN", buf.String())
+}
@@ -0,0 +1,170 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// watchInterval is how often --watch looks for changed files.
+var watchInterval = 500 * time.Millisecond
+
+// fileState is what --watch compares to find changed files.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// addWatchedCommand adds c, a subcommand generating docs, to the doc tool
+// with the --watch and --watch-path flags, running its RunE again on
+// changes when --watch is given.
+func (dg *DocGenTool) addWatchedCommand(c *cobra.Command) {
+	c.Flags().BoolVar(&dg.watch, dg.config.WatchFlag, false, "Run again each time a watched file changes, until interrupted")
+	c.Flags().StringSliceVar(&dg.watchPaths, dg.config.WatchPathFlag, []string{"."},
+		"Files and directories --"+dg.config.WatchFlag+" watches")
+	run := c.RunE
+	c.RunE = func(myCmd *cobra.Command, args []string) error {
+		if !dg.watch {
+			return run(myCmd, args)
+		}
+		return dg.watchLoop(myCmd, func() error {
+			if len(dg.config.WatchCommand) > 0 {
+				return dg.runWatchCommand(myCmd, args)
+			}
+			if err := dg.readConfigFile(myCmd.Flags().Changed(dg.config.ConfigFlag)); err != nil {
+				return err
+			}
+			return run(myCmd, args)
+		})
+	}
+	dg.docCmd.AddCommand(c)
+}
+
+// watchLoop calls run, then again each time a file in the paths given with
+// --watch-path changes, until the context of myCmd is done.  Errors of run
+// are reported to the error output of myCmd and do not stop the loop.
+func (dg *DocGenTool) watchLoop(myCmd *cobra.Command, run func() error) error {
+	ctx := cmdContext(myCmd)
+	last, err := dg.watchRun(myCmd, run)
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, err := snapshot(dg.watchPaths)
+		if err != nil {
+			return err
+		}
+		if !changed(last, current) {
+			continue
+		}
+		if last, err = dg.watchRun(myCmd, run); err != nil {
+			return err
+		}
+	}
+}
+
+// watchRun calls run, reports its error to the error output of myCmd and
+// says what is watched.  It returns the snapshot of the watched paths taken
+// after run, so the files it writes into them are not taken as changes.
+func (dg *DocGenTool) watchRun(myCmd *cobra.Command, run func() error) (map[string]fileState, error) {
+	if err := run(); err != nil {
+		if _, err := fmt.Fprintln(myCmd.ErrOrStderr(), "Error:", err); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := fmt.Fprintf(myCmd.ErrOrStderr(), "watching %s for changes\n", strings.Join(dg.watchPaths, ", ")); err != nil {
+		return nil, err
+	}
+	return snapshot(dg.watchPaths)
+}
+
+// runWatchCommand runs ToolConfig.WatchCommand with the subcommand, flags
+// and args of myCmd, without the watch flags.
+func (dg *DocGenTool) runWatchCommand(myCmd *cobra.Command, args []string) error {
+	cmdArgs := append([]string{}, dg.config.WatchCommand[1:]...)
+	cmdArgs = append(cmdArgs, strings.Fields(strings.TrimPrefix(myCmd.CommandPath(), dg.docCmd.CommandPath()))...)
+	myCmd.Flags().Visit(func(flag *pflag.Flag) {
+		if flag.Name == dg.config.WatchFlag || flag.Name == dg.config.WatchPathFlag {
+			return
+		}
+		value := flag.Value.String()
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			value = strings.Join(slice.GetSlice(), ",")
+		}
+		cmdArgs = append(cmdArgs, "--"+flag.Name+"="+value)
+	})
+	cmdArgs = append(cmdArgs, args...)
+
+	c := exec.CommandContext(myCmd.Context(), dg.config.WatchCommand[0], cmdArgs...)
+	c.Stdout = myCmd.OutOrStdout()
+	c.Stderr = myCmd.ErrOrStderr()
+	return c.Run()
+}
+
+// snapshot returns the state of the files in paths, walking into
+// directories.  Missing paths are left out.
+func snapshot(paths []string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				if path != root && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// changed reports whether a file was added, removed or changed between the
+// snapshots before and after.
+func changed(before map[string]fileState, after map[string]fileState) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for path, state := range after {
+		if old, ok := before[path]; !ok || old != state {
+			return true
+		}
+	}
+	return false
+}
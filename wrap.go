@@ -0,0 +1,175 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultLineWidth is the width Options.WrapLines wraps at by default.
+const defaultLineWidth = 78
+
+// noFillRegions maps the macros starting a region whose lines must be kept
+// as they are to the macro ending it.
+var noFillRegions = map[string]string{
+	".EX": ".EE",
+	".nf": ".fi",
+	".TS": ".TE",
+	".Bd": ".Ed",
+}
+
+// nextLineMacros take the line after them as their argument when they are
+// called without one, like the tag of .TP.
+var nextLineMacros = map[string]bool{
+	".TP": true, ".TQ": true, ".SH": true, ".SS": true, ".SM": true, ".SB": true,
+	".B": true, ".I": true, ".BR": true, ".BI": true, ".IB": true, ".RB": true, ".RI": true, ".IR": true,
+}
+
+// wrapTroff breaks the text lines of the troff or mdoc page doc at spaces
+// so no line is longer than width.  Macro lines, no-fill regions and lines
+// that are the argument of the macro before are not wrapped.
+func wrapTroff(doc string, width int) string {
+	var out []string
+	end := ""
+	argument := false
+	for _, line := range strings.Split(doc, "\n") {
+		switch {
+		case end != "":
+			if strings.HasPrefix(line, end) {
+				end = ""
+			}
+			out = append(out, line)
+		case strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'"):
+			fields := strings.Fields(line)
+			end = noFillRegions[fields[0]]
+			argument = nextLineMacros[fields[0]] && len(fields) == 1
+			out = append(out, line)
+		case argument || strings.HasPrefix(line, " "):
+			// A leading space breaks the line in troff, keep it as it is.
+			argument = false
+			out = append(out, line)
+		default:
+			out = append(out, wrapTroffLine(line, width)...)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// wrapTroffLine breaks line at the spaces that are not escaped.  Words
+// starting a line after a break are protected with \& if they start with a
+// control character.
+func wrapTroffLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	var lines []string
+	cur := ""
+	for _, word := range troffWords(line) {
+		if cur != "" && utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, cur)
+			cur = protectLines(word)
+			continue
+		}
+		if cur != "" {
+			cur += " "
+		}
+		cur += word
+	}
+	return append(lines, cur)
+}
+
+// troffWords splits line at the spaces that are not escaped with a
+// backslash.
+func troffWords(line string) []string {
+	var words []string
+	start := 0
+	escaped := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case line[i] == '\\':
+			escaped = true
+		case line[i] == ' ':
+			if i > start {
+				words = append(words, line[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(line) {
+		words = append(words, line[start:])
+	}
+	return words
+}
+
+// wrapMarkdown breaks the lines of the markdown page doc with wrapLine so
+// no line is longer than width.  Code blocks, tables, headings and HTML are
+// not wrapped.
+func wrapMarkdown(doc string, width int) string {
+	var out []string
+	blocks := markdownBlocks{blank: true}
+	for _, line := range strings.Split(doc, "\n") {
+		if blocks.verbatim(line) {
+			out = append(out, line)
+			continue
+		}
+		out = append(out, wrapLine(line, width)...)
+	}
+	return strings.Join(out, "\n")
+}
+
+// markdownBlocks tracks the code blocks of the lines of a markdown page.
+type markdownBlocks struct {
+	fence    string
+	indented bool
+	blank    bool
+}
+
+// verbatim reports whether line, the next line of the page, is not to be
+// wrapped.
+func (m *markdownBlocks) verbatim(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	code := m.code(line, trimmed)
+	m.blank = trimmed == ""
+	if !m.blank && !isCodeIndent(line) {
+		m.indented = false
+	}
+	return code || trimmed == "" || atxHeading.MatchString(line) || strings.HasPrefix(trimmed, "|") ||
+		strings.HasPrefix(trimmed, "<") || strings.HasPrefix(trimmed, "[//]:")
+}
+
+// code reports whether line is part of a code block.
+func (m *markdownBlocks) code(line string, trimmed string) bool {
+	switch {
+	case m.fence != "":
+		if strings.HasPrefix(trimmed, m.fence) {
+			m.fence = ""
+		}
+	case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+		m.fence = trimmed[:3]
+	case (m.blank || m.indented) && isCodeIndent(line):
+		m.indented = true
+	default:
+		return false
+	}
+	return true
+}
+
+// isCodeIndent reports whether line is indented enough for a code block.
+func isCodeIndent(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
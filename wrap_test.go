@@ -0,0 +1,75 @@
+// Copyright © 2018 Ray Johnson <ray.johnson@gmail.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cobraman
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapTroff(t *testing.T) {
+	cases := [][]string{
+		{"one two three", "one two\nthree"},
+		{"one two .three", "one two\n\\&.three"},
+		{"one\\ two three", "one\\ two\nthree"},
+		{".SH ONE TWO THREE", ".SH ONE TWO THREE"},
+		{".TP\none two three\nfour five six", ".TP\none two three\nfour five\nsix"},
+		{".EX\none two three\n.EE\none two three", ".EX\none two three\n.EE\none two\nthree"},
+		{" one two three", " one two three"},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], wrapTroff(cases[i][0], 10))
+	}
+}
+
+func TestWrapMarkdown(t *testing.T) {
+	cases := [][]string{
+		{"one two three", "one two\nthree"},
+		{"* one two three", "* one two\n  three"},
+		{"## one two three", "## one two three"},
+		{"```\none two three\n```", "```\none two three\n```"},
+		{"text\n\n    one two three", "text\n\n    one two three"},
+		{"| one | two | three |", "| one | two | three |"},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		assert.Equal(t, cases[i][1], wrapMarkdown(cases[i][0], 10))
+	}
+}
+
+func TestWrapLinesOption(t *testing.T) {
+	buf := new(bytes.Buffer)
+
+	cmd := &cobra.Command{Use: "foo", Long: strings.Repeat("word ", 40)}
+	cmd.Flags().String("name", "", strings.Repeat("usage ", 20))
+
+	for _, name := range []string{"troff", "mdoc", "markdown"} {
+		buf.Reset()
+		assert.NoError(t, GenerateOnePage(cmd, &Options{WrapLines: true, LineWidth: 40}, name, buf))
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if !strings.HasPrefix(line, ".") && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "[//]:") {
+				assert.LessOrEqual(t, len(line), 40, name+": "+line)
+			}
+		}
+	}
+
+	buf.Reset()
+	assert.NoError(t, GenerateOnePage(cmd, &Options{}, "troff", buf))
+	assert.Regexp(t, strings.Repeat("word ", 39), buf.String())
+}